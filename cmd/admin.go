@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+
+	"go.pixelfactory.io/needle/internal/infra/coredns"
+	"go.pixelfactory.io/needle/internal/infra/http/admin"
+)
+
+// newAdminAuth returns the admin API authentication and the admin listener
+// tls.Config, nil when the listener serves plain HTTP.
+func newAdminAuth() (*admin.Auth, *tls.Config, error) {
+	if err := validateAdminAuth(); err != nil {
+		return nil, nil, err
+	}
+
+	opts := []admin.AuthOption{admin.WithTokens(adminTokens...)}
+	if adminClientCA != "" {
+		opts = append(opts, admin.WithClientCertificates())
+	}
+
+	var tlsConfig *tls.Config
+	if adminTLSCert != "" {
+		var err error
+		tlsConfig, err = admin.TLSConfig(adminTLSCert, adminTLSKey, adminClientCA)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return admin.NewAuth(opts...), tlsConfig, nil
+}
+
+// validateAdminAuth refuses an unauthenticated admin API reachable from the network.
+func validateAdminAuth() error {
+	if adminClientCA != "" && adminTLSCert == "" {
+		return errors.New("admin-client-ca requires admin-tls-cert and admin-tls-key")
+	}
+
+	if len(adminTokens) > 0 || adminClientCA != "" {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(adminAddr)
+	if err != nil {
+		return errors.Wrap(err, "admin-addr")
+	}
+
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return errors.Errorf("admin-addr %s is not a loopback address, set admin-tokens or admin-client-ca", adminAddr)
+	}
+
+	return nil
+}
+
+// hostsBlocklists reports the hosts file of the generated Corefile.
+func hostsBlocklists(_ context.Context) ([]admin.Blocklist, error) {
+	if !viper.GetBool("coredns") || viper.GetString("coredns-corefile") != "" {
+		return []admin.Blocklist{}, nil
+	}
+
+	path := viper.GetString("coredns-hosts-file")
+	entries, err := coredns.CountHosts(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	updatedAt := info.ModTime()
+
+	return []admin.Blocklist{{Name: "hosts", Source: path, Entries: entries, UpdatedAt: &updatedAt}}, nil
+}
+
+// adminRequest creates a request to the admin API of a running needle,
+// authenticated with the first admin token.
+func adminRequest(ctx context.Context, method, path string) (*http.Client, *http.Request, error) {
+	client := http.DefaultClient
+	scheme := "http"
+
+	if certFile := viper.GetString("admin-tls-cert"); certFile != "" {
+		pemCerts, err := os.ReadFile(certFile)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// the admin certificate is trusted, it may be self-signed
+		roots := x509.NewCertPool()
+		roots.AppendCertsFromPEM(pemCerts)
+		client = &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: roots},
+		}}
+		scheme = "https"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, scheme+"://"+viper.GetString("admin-addr")+path, http.NoBody)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if tokens := viper.GetStringSlice("admin-tokens"); len(tokens) > 0 {
+		req.Header.Set("Authorization", "Bearer "+tokens[0])
+	}
+
+	return client, req, nil
+}
@@ -0,0 +1,285 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"go.pixelfactory.io/pkg/observability/log"
+
+	"go.pixelfactory.io/needle/internal/app/blocklist"
+	"go.pixelfactory.io/needle/internal/app/category"
+	"go.pixelfactory.io/needle/internal/app/policy"
+	"go.pixelfactory.io/needle/internal/infra/coredns"
+	"go.pixelfactory.io/needle/internal/infra/fetcher"
+	"go.pixelfactory.io/needle/internal/infra/http/admin"
+	"go.pixelfactory.io/needle/internal/infra/http/blockpage"
+)
+
+// newBlocklists returns the manager of --blocklists and of enabled
+// categories, nil when none is set.
+func newBlocklists(
+	logger log.Logger, dataset *category.Dataset, categories []category.Category,
+) (*blocklist.Manager, error) {
+	opts, err := blocklistOptions(dataset, categories)
+	if err != nil || opts == nil {
+		return nil, err
+	}
+
+	return blocklist.New(append(opts,
+		blocklist.WithLogger(logger),
+		blocklist.WithFetcher(fetcher.New()),
+	)...)
+}
+
+// blocklistOptions parses --blocklists and --blocklist-* flags, enabled
+// categories of dataset are lists named after them. It is nil when neither a
+// source nor a category is set.
+func blocklistOptions(dataset *category.Dataset, categories []category.Category) ([]blocklist.Option, error) {
+	if len(blocklistSources) == 0 && len(categories) == 0 {
+		return nil, nil
+	}
+
+	sources := make([]blocklist.Source, 0, len(blocklistSources))
+	for _, s := range blocklistSources {
+		source, err := blocklist.ParseSource(s)
+		if err != nil {
+			return nil, errors.Wrap(err, "blocklists")
+		}
+		if slices.Contains(categories, category.Category(source.Name)) {
+			return nil, errors.Errorf("blocklists %q is also an enabled category", source.Name)
+		}
+		sources = append(sources, source)
+	}
+
+	lists := make([]blocklist.List, 0, len(categories))
+	for _, c := range categories {
+		lists = append(lists, dataset.List(c))
+	}
+
+	allow := make([]blocklist.Rule, 0, len(blocklistAllow))
+	for _, s := range blocklistAllow {
+		rule, err := blocklist.ParseRule(s)
+		if err != nil {
+			return nil, errors.Wrap(err, "blocklist-allow")
+		}
+		allow = append(allow, rule)
+	}
+
+	return []blocklist.Option{
+		blocklist.WithSources(sources...),
+		blocklist.WithLists(lists...),
+		blocklist.WithAllow(allow...),
+		blocklist.WithRefresh(blocklistRefresh),
+		blocklist.WithMaxShrink(blocklistMaxShrink),
+	}, nil
+}
+
+// blockModes parses --coredns-block-mode and --coredns-list-modes.
+func blockModes(mode string, listModes []string) (coredns.Mode, map[string]coredns.Mode, error) {
+	m, err := coredns.ParseMode(mode)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "coredns-block-mode")
+	}
+
+	modes := make(map[string]coredns.Mode, len(listModes))
+	for _, s := range listModes {
+		list, mode, ok := strings.Cut(s, "=")
+		if !ok || list == "" {
+			return "", nil, errors.Errorf("coredns-list-modes %q must be written list=mode", s)
+		}
+
+		modes[list], err = coredns.ParseMode(mode)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "coredns-list-modes")
+		}
+	}
+
+	return m, modes, nil
+}
+
+// clientBlocker blocks the names of the blocklists enforced for the group
+// of each client.
+type clientBlocker struct {
+	lists  *blocklist.Manager
+	policy *policy.Policy
+}
+
+// Match implements coredns.Blocker.
+func (b clientBlocker) Match(client net.IP, name string) ([]string, bool) {
+	if b.lists == nil {
+		return nil, false
+	}
+
+	lists, ok := b.lists.Match(name)
+	if !ok {
+		return nil, false
+	}
+	return b.policy.Filter(client, name, lists)
+}
+
+// unblockers allows a domain with each Unblocker, in the hosts file and in the blocklists.
+type unblockers []blockpage.Unblocker
+
+// Allow implements blockpage.Unblocker.
+func (u unblockers) Allow(ctx context.Context, name string, d time.Duration) error {
+	for _, unblocker := range u {
+		if err := unblocker.Allow(ctx, name, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newBlocklistsFunc reports the hosts file of the generated Corefile and the
+// sources of --blocklists.
+func newBlocklistsFunc(lists *blocklist.Manager) admin.BlocklistsFunc {
+	return func(ctx context.Context) ([]admin.Blocklist, error) {
+		result, err := hostsBlocklists(ctx)
+		if err != nil || lists == nil {
+			return result, err
+		}
+
+		for _, status := range lists.Statuses() {
+			list := admin.Blocklist{
+				Name:    status.Name,
+				Source:  status.Location,
+				Entries: status.Entries,
+				Error:   status.Error,
+			}
+			if list.Source == "" {
+				list.Source = "categories"
+			}
+			if !status.UpdatedAt.IsZero() {
+				list.UpdatedAt = &status.UpdatedAt
+			}
+			result = append(result, list)
+		}
+		return result, nil
+	}
+}
+
+// groupSchedule returns ", schedule name" for a group with a schedule.
+func groupSchedule(p *policy.Policy, group string) string {
+	for _, g := range p.Groups() {
+		if g.Name == group && g.Schedule != nil {
+			return ", schedule " + g.Schedule.Name
+		}
+	}
+	return ""
+}
+
+// blockedBy names the lists blocking domain for client, blocked is false
+// when it is not blocked. The hosts file blocks domains for every client.
+func blockedBy(
+	ctx context.Context, blocker clientBlocker, hosts *coredns.Hosts, client net.IP, domain string,
+) (string, bool, error) {
+	if names, ok := blocker.Match(client, domain); ok {
+		by := "blocklists " + strings.Join(names, ", ")
+		if group := blocker.policy.Group(client); group != "" {
+			by += " (group " + group + groupSchedule(blocker.policy, group) + ")"
+		}
+		return by, true, nil
+	}
+
+	if hosts != nil {
+		_, ok, err := hosts.Lookup(ctx, domain)
+		if err != nil || !ok {
+			return "", false, err
+		}
+		return "hosts file " + hosts.Path(), true, nil
+	}
+
+	return "", false, nil
+}
+
+// newBlocklistsCmd create blocklists command and its subcommands.
+func newBlocklistsCmd() *cobra.Command {
+	blocklistsCmd := &cobra.Command{
+		Use:   "blocklists",
+		Short: "Manage the blocklists of a running needle",
+	}
+
+	blocklistsCmd.AddCommand(&cobra.Command{
+		Use:   "diff",
+		Short: "Show the domains added and removed by the last blocklists update changing them",
+		Args:  cobra.NoArgs,
+		RunE:  blocklistsDiff,
+	})
+
+	blocklistsCmd.AddCommand(&cobra.Command{
+		Use:   "rollback",
+		Short: "Restore the blocklists replaced by the last update",
+		Long: "Restore the blocklists replaced by the last update that changed blocked domains, " +
+			"rolling back twice restores the update. Sources are fetched again on the next refresh.",
+		Args: cobra.NoArgs,
+		RunE: rollbackBlocklists,
+	})
+
+	return blocklistsCmd
+}
+
+func blocklistsDiff(cmd *cobra.Command, _ []string) error {
+	client, req, err := adminRequest(cmd.Context(), http.MethodGet, "/api/v1/blocklists/diff")
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("diff failed: %s", resp.Status)
+	}
+
+	var diff admin.BlocklistDiff
+	if err := json.NewDecoder(resp.Body).Decode(&diff); err != nil {
+		return errors.Wrap(err, "diff failed")
+	}
+
+	out := cmd.OutOrStdout()
+	for _, domain := range diff.Added {
+		fmt.Fprintf(out, "+ %s\n", domain)
+	}
+	for _, domain := range diff.Removed {
+		fmt.Fprintf(out, "- %s\n", domain)
+	}
+
+	update := "update"
+	if diff.Rollback {
+		update = "rollback"
+	}
+	fmt.Fprintf(out, "%d domains added, %d removed by the %s of %s\n",
+		diff.AddedCount, diff.RemovedCount, update, diff.Time.Local().Format("2006-01-02 15:04:05"))
+	return nil
+}
+
+func rollbackBlocklists(cmd *cobra.Command, _ []string) error {
+	client, req, err := adminRequest(cmd.Context(), http.MethodPost, "/api/v1/blocklists/rollback")
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return errors.Errorf("rollback failed: %s", resp.Status)
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), "Blocklists rolled back")
+	return nil
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"go.pixelfactory.io/needle/internal/app/pki"
+)
+
+// newCACmd create ca command and its subcommands.
+func newCACmd() *cobra.Command {
+	caCmd := &cobra.Command{
+		Use:   "ca",
+		Short: "Manage the certificate authority",
+	}
+
+	caCmd.AddCommand(&cobra.Command{
+		Use:   "reissue",
+		Short: "Re-issue cached certificates not signed by the active CA",
+		Long: "Re-issue every cached certificate signed by a retired CA, or no longer valid, with the CA " +
+			"given by --ca and --ca-key. Needle must be stopped while re-issuing.",
+		Args: cobra.NoArgs,
+		RunE: reissue,
+	})
+
+	return caCmd
+}
+
+func reissue(cmd *cobra.Command, _ []string) error {
+	certFactory, err := newFactory()
+	if err != nil {
+		return err
+	}
+
+	client, err := newStormClient(viper.GetString("db-file"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := client.Close(); err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "an error occurred while closing *storm.DB client: %v\n", err)
+		}
+	}()
+
+	repo, err := newRepository(client)
+	if err != nil {
+		return err
+	}
+
+	names, err := pki.New(repo, certFactory).Reissue(cmd.Context())
+	for _, name := range names {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s re-issued\n", name)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "%d certificates re-issued\n", len(names))
+	return nil
+}
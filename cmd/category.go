@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"github.com/pkg/errors"
+
+	"go.pixelfactory.io/needle/internal/app/category"
+	"go.pixelfactory.io/needle/internal/infra/querylog"
+)
+
+// newCategories loads --category-datasets and parses --categories, the
+// dataset is nil when no dataset is set.
+func newCategories() (*category.Dataset, []category.Category, error) {
+	enabled, err := enabledCategories()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(categoryDatasets) == 0 {
+		if len(enabled) > 0 {
+			return nil, nil, errors.New("categories requires category-datasets")
+		}
+		return nil, nil, nil
+	}
+
+	dataset, err := category.Open(categoryDatasets...)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "category-datasets")
+	}
+	return dataset, enabled, nil
+}
+
+// enabledCategories parses --categories.
+func enabledCategories() ([]category.Category, error) {
+	result := make([]category.Category, 0, len(categoryNames))
+	for _, s := range categoryNames {
+		c, err := category.Parse(s)
+		if err != nil {
+			return nil, errors.Wrap(err, "categories")
+		}
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+// newClassify tags query log entries with the company owning their domain
+// and its categories.
+func newClassify(dataset *category.Dataset) querylog.ClassifyFunc {
+	return func(domain string) (string, []string) {
+		tag, ok := dataset.Lookup(domain)
+		if !ok {
+			return "", nil
+		}
+
+		var categories []string
+		for _, c := range tag.Categories {
+			categories = append(categories, string(c))
+		}
+		return tag.Entity, categories
+	}
+}
@@ -0,0 +1,474 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"go.pixelfactory.io/needle/internal/app/blocklist"
+	"go.pixelfactory.io/needle/internal/app/category"
+	"go.pixelfactory.io/needle/internal/app/policy"
+	"go.pixelfactory.io/needle/internal/app/schedule"
+	"go.pixelfactory.io/needle/internal/infra/leases"
+)
+
+// configSections maps keys of the sectioned configuration file to flag names.
+// Flag names are also accepted as top level keys and take precedence.
+var configSections = map[string]string{
+	"tls.ca":                       "ca",
+	"tls.ca-key":                   "ca-key",
+	"tls.ca-trusted":               "ca-trusted",
+	"tls.blocked-only":             "tls-blocked-only",
+	"storage.db-file":              "db-file",
+	"storage.db-key-file":          "db-key-file",
+	"storage.db-keys":              "db-keys",
+	"http.port":                    "http-port",
+	"http.https-port":              "https-port",
+	"http.server-timeout":          "server-timeout",
+	"http.server-shutdown-timeout": "server-shutdown-timeout",
+	"http.stub-rules":              "stub-rules",
+	"http.surrogates":              "surrogates",
+	"http.surrogates-dir":          "surrogates-dir",
+	"http.block-page":              "block-page",
+	"http.unblock-passphrase":      "unblock-passphrase",
+	"http.unblock-duration":        "unblock-duration",
+	"admin.addr":                   "admin-addr",
+	"admin.tokens":                 "admin-tokens",
+	"admin.tls-cert":               "admin-tls-cert",
+	"admin.tls-key":                "admin-tls-key",
+	"admin.client-ca":              "admin-client-ca",
+	"dns.enabled":                  "coredns",
+	"dns.port":                     "coredns-port",
+	"dns.hosts-file":               "coredns-hosts-file",
+	"dns.upstreams":                "coredns-upstreams",
+	"dns.corefile":                 "coredns-corefile",
+	"dns.metrics-addr":             "coredns-metrics-addr",
+	"dns.block-mode":               "coredns-block-mode",
+	"dns.list-modes":               "coredns-list-modes",
+	"dns.cname-check":              "coredns-cname-check",
+	"blocklist.sources":            "blocklists",
+	"blocklist.allow":              "blocklist-allow",
+	"blocklist.refresh":            "blocklist-refresh",
+	"blocklist.max-shrink":         "blocklist-max-shrink",
+	"groups.clients":               "group-clients",
+	"groups.blocklists":            "group-blocklists",
+	"groups.allow":                 "group-allow",
+	"groups.dhcp-leases":           "dhcp-leases",
+	"groups.schedules":             "group-schedules",
+	"blocklist.schedules":          "blocklist-schedules",
+	"categories.datasets":          "category-datasets",
+	"categories.enabled":           "categories",
+	"schedules.windows":            "schedules",
+	"schedules.timezone":           "schedule-timezone",
+	"querylog.dir":                 "querylog-dir",
+	"querylog.retention":           "querylog-retention",
+	"querylog.max-size":            "querylog-max-size",
+	"lan.addresses":                "lan-addresses",
+	"lan.interfaces":               "lan-interfaces",
+	"tracing.endpoint":             "tracing-endpoint",
+	"tracing.sample-ratio":         "tracing-sample-ratio",
+}
+
+var logLevels = []string{"debug", "info", "warn", "error", "fatal", "panic"}
+
+// flagDefaults holds the values of flags before the environment and the
+// config file are applied, restored on reload when they are no longer set.
+var flagDefaults map[string][]string
+
+// readConfig reads the --config file, then updates flags which were not set
+// on the command line from the environment and the config file. Flags which
+// are no longer set in either, such as keys removed from the config file,
+// get their default value back.
+func readConfig(flags *pflag.FlagSet) error {
+	// sectioned keys of the config file by flag name
+	sections := map[string]string{}
+	if file := viper.GetString("config"); file != "" {
+		viper.SetConfigFile(file)
+		if err := viper.ReadInConfig(); err != nil {
+			return errors.Wrap(err, "unable to read config file")
+		}
+
+		for section, flag := range configSections {
+			if viper.InConfig(section) {
+				sections[flag] = section
+			}
+		}
+	}
+
+	if flagDefaults == nil {
+		flagDefaults = map[string][]string{}
+		flags.VisitAll(func(f *pflag.Flag) {
+			if sv, ok := f.Value.(pflag.SliceValue); ok {
+				flagDefaults[f.Name] = slices.Clone(sv.GetSlice())
+			} else {
+				flagDefaults[f.Name] = []string{f.Value.String()}
+			}
+		})
+	}
+
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed {
+			return
+		}
+
+		// flag names take precedence over sectioned keys
+		key := f.Name
+		if !viper.IsSet(key) {
+			key = sections[f.Name]
+		}
+
+		sv, isSlice := f.Value.(pflag.SliceValue)
+		switch {
+		case key == "" && isSlice:
+			err = sv.Replace(flagDefaults[f.Name])
+		case key == "":
+			err = f.Value.Set(flagDefaults[f.Name][0])
+		case isSlice:
+			err = sv.Replace(viper.GetStringSlice(key))
+		default:
+			err = f.Value.Set(viper.GetString(key))
+		}
+		err = errors.Wrapf(err, "invalid value for %s", f.Name)
+	})
+
+	return err
+}
+
+// newConfigCmd create config command and its subcommands.
+func newConfigCmd() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect needle configuration",
+	}
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "validate",
+		Short: "Validate the configuration and print the effective configuration",
+		Args:  cobra.NoArgs,
+		RunE:  validate,
+	})
+
+	return configCmd
+}
+
+func validate(cmd *cobra.Command, _ []string) error {
+	out, err := yaml.Marshal(effectiveConfig(cmd.Root().PersistentFlags()))
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(cmd.OutOrStdout(), string(out))
+
+	problems := validateConfig()
+	for _, problem := range problems {
+		fmt.Fprintf(cmd.ErrOrStderr(), "invalid configuration: %v\n", problem)
+	}
+
+	if len(problems) > 0 {
+		return errors.Errorf("%d configuration problems", len(problems))
+	}
+
+	fmt.Fprintln(cmd.ErrOrStderr(), "configuration is valid")
+	return nil
+}
+
+// effectiveConfig returns the merged configuration in the sectioned layout, secrets are masked.
+func effectiveConfig(flags *pflag.FlagSet) map[string]any {
+	sectionOf := make(map[string]string, len(configSections))
+	for section, flag := range configSections {
+		sectionOf[flag] = section
+	}
+
+	config := map[string]any{}
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Name == "config" {
+			return
+		}
+
+		var value any = viper.Get(f.Name)
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			value = sv.GetSlice()
+		}
+
+		if f.Name == "unblock-passphrase" && viper.GetString(f.Name) != "" {
+			value = "********"
+		}
+
+		if f.Name == "admin-tokens" {
+			masked := make([]string, len(viper.GetStringSlice(f.Name)))
+			for i := range masked {
+				masked[i] = "********"
+			}
+			value = masked
+		}
+
+		if f.Name == "db-keys" {
+			var masked []string
+			for _, key := range viper.GetStringSlice(f.Name) {
+				id, _, _ := strings.Cut(key, ":")
+				masked = append(masked, id+":********")
+			}
+			value = masked
+		}
+
+		section, key, ok := strings.Cut(sectionOf[f.Name], ".")
+		if !ok {
+			config[f.Name] = value
+			return
+		}
+
+		sub, ok := config[section].(map[string]any)
+		if !ok {
+			sub = map[string]any{}
+			config[section] = sub
+		}
+		sub[key] = value
+	})
+
+	return config
+}
+
+// validateConfig checks paths exist, ports are valid and the CA pair matches.
+func validateConfig() []error {
+	var problems []error
+	check := func(err error) {
+		if err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	if !slices.Contains(logLevels, logLevel) {
+		check(errors.Errorf("log-level %q must be one of %s", logLevel, strings.Join(logLevels, ", ")))
+	}
+
+	check(validateCA(caFile, caKeyFile))
+	for _, file := range caTrustedFiles {
+		_, err := loadCertificates(file)
+		check(errors.Wrap(err, "ca-trusted"))
+	}
+
+	check(validateFile("db-file directory", filepath.Dir(dbFile)))
+	if dbKeyFile != "" {
+		check(validateFile("db-key-file", dbKeyFile))
+	}
+	_, err := newKeyring()
+	check(errors.Wrap(err, "db-keys"))
+
+	check(validatePort("http-port", httpPort))
+	check(validatePort("https-port", httpsPort))
+	check(validateDuration("server-timeout", httpServerTimeout))
+	check(validateDuration("server-shutdown-timeout", httpServerShutdownTimeout))
+	_, err = stubOptions()
+	check(err)
+	if surrogatesEnabled && surrogatesDir != "" {
+		check(validateFile("surrogates-dir", surrogatesDir))
+	}
+	if unblockPassphrase != "" {
+		check(validateDuration("unblock-duration", unblockDuration))
+	}
+
+	if adminAddr != "" {
+		_, port, err := net.SplitHostPort(adminAddr)
+		check(errors.Wrap(err, "admin-addr"))
+		if err == nil {
+			check(validatePort("admin-addr", port))
+			check(validateAdminAuth())
+		}
+		if adminTLSCert != "" {
+			check(validateFile("admin-tls-cert", adminTLSCert))
+			check(validateFile("admin-tls-key", adminTLSKey))
+		}
+		if adminClientCA != "" {
+			check(validateFile("admin-client-ca", adminClientCA))
+		}
+	}
+
+	if queryLogDir != "" {
+		check(validateDuration("querylog-retention", queryLogRetention))
+		if queryLogMaxSize <= 0 {
+			check(errors.New("querylog-max-size must be positive"))
+		}
+	}
+
+	dataset, categories, err := newCategories()
+	check(err)
+	_, err = blocklistOptions(dataset, categories)
+	check(err)
+	if blocklistRefresh < 0 {
+		check(errors.New("blocklist-refresh must not be negative"))
+	}
+	if blocklistMaxShrink < 0 || blocklistMaxShrink >= 1 {
+		check(errors.Errorf("blocklist-max-shrink %v must be at least 0 and below 1", blocklistMaxShrink))
+	}
+	if tlsBlockedOnly && len(blocklistSources) == 0 && len(categoryNames) == 0 && (!corednsEnabled || corednsCoreFile != "") {
+		check(errors.New("tls-blocked-only requires blocklists or coredns with the generated Corefile"))
+	}
+
+	schedules, err := newSchedules()
+	check(err)
+	check(validateGroups(schedules))
+
+	_, err = lanAddresses(lanAddrs)
+	check(err)
+
+	if tracingEndpoint != "" {
+		u, err := url.Parse(tracingEndpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			check(errors.Errorf("tracing-endpoint %q must be an http or https URL", tracingEndpoint))
+		}
+	}
+	if tracingSampleRatio < 0 || tracingSampleRatio > 1 {
+		check(errors.Errorf("tracing-sample-ratio %v must be between 0 and 1", tracingSampleRatio))
+	}
+
+	if corednsEnabled {
+		check(validatePort("coredns-port", strconv.Itoa(corednsPort)))
+		if corednsCoreFile != "" {
+			check(validateFile("coredns-corefile", corednsCoreFile))
+		} else {
+			check(validateFile("coredns-hosts-file", corednsHostsFile))
+		}
+		if len(corednsUpstreams) == 0 {
+			check(errors.New("coredns-upstreams must not be empty"))
+		}
+		check(validateBlockModes())
+	}
+
+	return problems
+}
+
+// validateBlockModes checks modes are valid and name configured blocklists.
+func validateBlockModes() error {
+	_, modes, err := blockModes(corednsBlockMode, corednsListModes)
+	if err != nil {
+		return err
+	}
+
+	for list := range modes {
+		if !isBlocklist(list) {
+			return errors.Errorf("coredns-list-modes %q is not a blocklist", list)
+		}
+	}
+	return nil
+}
+
+// validateGroups checks client groups and list schedules are valid, name
+// configured blocklists and that the lease file can be read.
+func validateGroups(schedules map[string]*schedule.Schedule) error {
+	groups, err := policyGroups(schedules)
+	if err != nil {
+		return err
+	}
+	lists, err := listSchedules(schedules)
+	if err != nil {
+		return err
+	}
+	for list := range lists {
+		if !isBlocklist(list) {
+			return errors.Errorf("blocklist-schedules %q is not a blocklist", list)
+		}
+	}
+
+	opts := []policy.Option{policy.WithGroups(groups...)}
+	if dhcpLeases != "" {
+		f, err := os.Open(dhcpLeases)
+		if err != nil {
+			return errors.Wrap(err, "dhcp-leases")
+		}
+		defer f.Close()
+
+		macs, err := leases.Parse(f)
+		if err != nil {
+			return errors.Wrap(err, "dhcp-leases")
+		}
+		opts = append(opts, policy.WithLeases(macs))
+	}
+
+	if _, err := policy.New(opts...); err != nil {
+		return errors.Wrap(err, "group-clients")
+	}
+
+	for _, g := range groups {
+		for _, list := range g.Lists {
+			if !isBlocklist(list) {
+				return errors.Errorf("group-blocklists %q is not a blocklist", list)
+			}
+		}
+	}
+	return nil
+}
+
+// isBlocklist reports whether list names a source of --blocklists or an
+// enabled category.
+func isBlocklist(list string) bool {
+	categories, err := enabledCategories()
+	if err == nil && slices.Contains(categories, category.Category(list)) {
+		return true
+	}
+
+	for _, s := range blocklistSources {
+		source, err := blocklist.ParseSource(s)
+		if err == nil && source.Name == list {
+			return true
+		}
+	}
+	return false
+}
+
+func validateFile(name, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return errors.Wrap(err, name)
+	}
+	return nil
+}
+
+func validatePort(name, port string) error {
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 1 || p > 65535 {
+		return errors.Errorf("%s %q is not a valid port", name, port)
+	}
+	return nil
+}
+
+func validateDuration(name string, d time.Duration) error {
+	if d <= 0 {
+		return errors.Errorf("%s must be positive", name)
+	}
+	return nil
+}
+
+func validateCA(certFile, keyFile string) error {
+	rootCA, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return errors.Wrap(err, "ca and ca-key")
+	}
+
+	ca, err := x509.ParseCertificate(rootCA.Certificate[0])
+	if err != nil {
+		return errors.Wrap(err, "ca")
+	}
+
+	if !ca.IsCA {
+		return errors.Errorf("ca %s is not a CA certificate", certFile)
+	}
+
+	if time.Now().After(ca.NotAfter) {
+		return errors.Errorf("ca %s expired on %s", certFile, ca.NotAfter.Format(time.RFC3339))
+	}
+
+	return nil
+}
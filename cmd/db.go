@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"go.pixelfactory.io/needle/internal/app/pki"
+	"go.pixelfactory.io/needle/internal/infra/boltdb"
+	"go.pixelfactory.io/needle/internal/infra/encryption"
+)
+
+var (
+	checkRepair  bool
+	checkDelete  bool
+	checkCompact bool
+)
+
+// newDBCmd create db command and its subcommands.
+func newDBCmd() *cobra.Command {
+	dbCmd := &cobra.Command{
+		Use:   "db",
+		Short: "Manage the certificate cache DB",
+	}
+
+	dbCmd.AddCommand(&cobra.Command{
+		Use:   "rekey",
+		Short: "Encrypt all private keys with the active key",
+		Long: "Re-encrypt private keys stored in plaintext or with a rotated key using the first key " +
+			"of --db-keys/--db-key-file. Needle must be stopped while rekeying.",
+		Args: cobra.NoArgs,
+		RunE: rekey,
+	})
+
+	dbCmd.AddCommand(&cobra.Command{
+		Use:   "backup FILE",
+		Short: "Download a snapshot of the cache DB from a running needle",
+		Args:  cobra.ExactArgs(1),
+		RunE:  backup,
+	})
+
+	checkCmd := &cobra.Command{
+		Use:   "check",
+		Short: "Verify every certificate stored in the cache DB",
+		Long: "Verify that stored certificates parse, match their private key and are signed by the CA. " +
+			"Needle must be stopped while checking.",
+		Args: cobra.NoArgs,
+		RunE: check,
+	}
+	checkCmd.Flags().BoolVar(&checkRepair, "repair", false, "Re-issue broken certificates")
+	checkCmd.Flags().BoolVar(&checkDelete, "delete", false, "Delete broken certificates")
+	checkCmd.Flags().BoolVar(&checkCompact, "compact", false, "Compact the cache DB file")
+	checkCmd.MarkFlagsMutuallyExclusive("repair", "delete")
+	dbCmd.AddCommand(checkCmd)
+
+	return dbCmd
+}
+
+func rekey(cmd *cobra.Command, _ []string) error {
+	keyring, err := newKeyring()
+	if err != nil {
+		return err
+	}
+	if keyring.Empty() {
+		return errors.Wrap(encryption.ErrNoKeys, "set --db-keys or --db-key-file")
+	}
+
+	client, err := newStormClient(viper.GetString("db-file"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := client.Close(); err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "an error occurred while closing *storm.DB client: %v\n", err)
+		}
+	}()
+
+	count, err := encryption.NewRepository(boltdb.New(client), keyring).Rekey(cmd.Context())
+	var unreadable pki.EntryErrors
+	if err != nil && !errors.As(err, &unreadable) {
+		return err
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "%d private keys encrypted with key %q\n", count, keyring.Active())
+	if len(unreadable) > 0 {
+		for _, name := range unreadable.Names() {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s: %v\n", name, unreadable[name])
+		}
+		return errors.Errorf("%d private keys do not decrypt, see needle db check --repair", len(unreadable))
+	}
+	return nil
+}
+
+func backup(cmd *cobra.Command, args []string) error {
+	client, req, err := adminRequest(cmd.Context(), http.MethodGet, "/api/v1/db/backup")
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("backup failed: %s", resp.Status)
+	}
+
+	tmp := args[0] + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	n, err := io.Copy(f, resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, args[0]); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "%d bytes written to %s\n", n, args[0])
+	return nil
+}
+
+func check(cmd *cobra.Command, _ []string) error {
+	certFactory, err := newFactory()
+	if err != nil {
+		return err
+	}
+
+	cas, err := certFactory.Roots()
+	if err != nil {
+		return err
+	}
+
+	roots := x509.NewCertPool()
+	for _, ca := range cas {
+		roots.AddCert(ca)
+	}
+
+	mode := pki.CheckOnly
+	switch {
+	case checkRepair:
+		mode = pki.CheckRepair
+	case checkDelete:
+		mode = pki.CheckDelete
+	}
+
+	dbFile := viper.GetString("db-file")
+	client, err := newStormClient(dbFile)
+	if err != nil {
+		return err
+	}
+
+	var results []pki.CheckResult
+	repo, err := newRepository(client)
+	if err == nil {
+		results, err = pki.New(repo, certFactory).Check(cmd.Context(), roots, mode)
+	}
+	if closeErr := client.Close(); err == nil {
+		err = closeErr
+	}
+
+	// results are partial when a repair or delete failed
+	broken := 0
+	for _, result := range results {
+		if result.Err == nil {
+			continue
+		}
+		broken++
+		fmt.Fprintf(cmd.OutOrStdout(), "%s: %v %s\n", result.Name, result.Err, result.Action)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "%d certificates checked, %d broken\n", len(results), broken)
+
+	if checkCompact {
+		if err := boltdb.Compact(dbFile); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "%s compacted\n", dbFile)
+	}
+
+	if broken > 0 && mode == pki.CheckOnly {
+		return errors.Errorf("%d broken certificates", broken)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"go.pixelfactory.io/pkg/observability/log"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// leveledLogger drops the entries of a debug logger below a level which can
+// change while needle runs, loggers are handed to every component at startup.
+type leveledLogger struct {
+	log.Logger
+	level zap.AtomicLevel
+}
+
+// newLogger returns a logger at level and the level, shared by the loggers
+// derived from it with With.
+func newLogger(level string) (log.Logger, zap.AtomicLevel, error) {
+	lvl, err := zap.ParseAtomicLevel(level)
+	if err != nil {
+		return nil, lvl, err
+	}
+	return leveledLogger{Logger: log.New(log.WithLevel("debug")), level: lvl}, lvl, nil
+}
+
+// Debug implements the log.Logger interface.
+func (l leveledLogger) Debug(msg string, f ...zap.Field) {
+	if l.level.Enabled(zapcore.DebugLevel) {
+		l.Logger.Debug(msg, f...)
+	}
+}
+
+// Info implements the log.Logger interface.
+func (l leveledLogger) Info(msg string, f ...zap.Field) {
+	if l.level.Enabled(zapcore.InfoLevel) {
+		l.Logger.Info(msg, f...)
+	}
+}
+
+// Warn implements the log.Logger interface.
+func (l leveledLogger) Warn(msg string, f ...zap.Field) {
+	if l.level.Enabled(zapcore.WarnLevel) {
+		l.Logger.Warn(msg, f...)
+	}
+}
+
+// Error implements the log.Logger interface.
+func (l leveledLogger) Error(msg string, f ...zap.Field) {
+	if l.level.Enabled(zapcore.ErrorLevel) {
+		l.Logger.Error(msg, f...)
+	}
+}
+
+// With implements the log.Logger interface.
+func (l leveledLogger) With(f ...zap.Field) log.Logger {
+	return leveledLogger{Logger: l.Logger.With(f...), level: l.level}
+}
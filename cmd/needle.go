@@ -1,39 +1,103 @@
 package cmd
 
 import (
+	"context"
 	"crypto/tls"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
 	"time"
 
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/cobra"
 	"go.pixelfactory.io/pkg/observability/log"
 	"go.pixelfactory.io/pkg/observability/log/fields"
-	"go.pixelfactory.io/pkg/server"
 	"go.pixelfactory.io/pkg/version"
 
 	"go.pixelfactory.io/needle/internal/app/factory"
 	"go.pixelfactory.io/needle/internal/app/pki"
 	"go.pixelfactory.io/needle/internal/infra/boltdb"
 	"go.pixelfactory.io/needle/internal/infra/coredns"
+	"go.pixelfactory.io/needle/internal/infra/health"
 	"go.pixelfactory.io/needle/internal/infra/http"
+	"go.pixelfactory.io/needle/internal/infra/http/admin"
+	"go.pixelfactory.io/needle/internal/infra/http/blockpage"
+	"go.pixelfactory.io/needle/internal/infra/http/dashboard"
 	"go.pixelfactory.io/needle/internal/infra/http/handlers"
+	"go.pixelfactory.io/needle/internal/infra/http/middleware"
+	"go.pixelfactory.io/needle/internal/infra/http/surrogate"
+	"go.pixelfactory.io/needle/internal/infra/lanaddr"
+	"go.pixelfactory.io/needle/internal/infra/metrics"
+	"go.pixelfactory.io/needle/internal/infra/querylog"
+	"go.pixelfactory.io/needle/internal/infra/stats"
+	"go.pixelfactory.io/needle/internal/infra/supervisor"
+	"go.pixelfactory.io/needle/internal/infra/tracing"
 )
 
 var (
+	configFile                string
 	logLevel                  string
 	caFile                    string
 	caKeyFile                 string
+	caTrustedFiles            []string
 	dbFile                    string
+	dbKeyFile                 string
+	dbKeys                    []string
 	httpPort                  string
 	httpsPort                 string
 	httpServerTimeout         time.Duration
 	httpServerShutdownTimeout time.Duration
+	stubRules                 []string
+	surrogatesEnabled         bool
+	surrogatesDir             string
+	blockPageEnabled          bool
+	unblockPassphrase         string
+	unblockDuration           time.Duration
+	tlsBlockedOnly            bool
+	blocklistSources          []string
+	blocklistAllow            []string
+	blocklistRefresh          time.Duration
+	blocklistMaxShrink        float64
+	groupClients              []string
+	groupBlocklists           []string
+	groupAllow                []string
+	dhcpLeases                string
+	groupSchedules            []string
+	blocklistSchedules        []string
+	categoryDatasets          []string
+	categoryNames             []string
+	scheduleWindows           []string
+	scheduleTimezone          string
 	corednsEnabled            bool
 	corednsPort               int
 	corednsHostsFile          string
 	corednsUpstreams          []string
 	corednsCoreFile           string
+	corednsMetricsAddr        string
+	corednsBlockMode          string
+	corednsListModes          []string
+	corednsCNAMECheck         bool
+	adminAddr                 string
+	adminTokens               []string
+	adminTLSCert              string
+	adminTLSKey               string
+	adminClientCA             string
+	tracingEndpoint           string
+	tracingSampleRatio        float64
+	queryLogDir               string
+	queryLogRetention         time.Duration
+	queryLogMaxSize           int64
+	lanAddrs                  []string
+	lanInterfaces             []string
 )
 
+// caExpiryThreshold fails readiness when the active CA expires sooner.
+const caExpiryThreshold = 30 * 24 * time.Hour
+
 var needleCmd = &cobra.Command{
 	Use:   "needle",
 	Short: "needle",
@@ -42,6 +106,11 @@ var needleCmd = &cobra.Command{
 
 // NewNeedleCmd create new needleCmd.
 func NewNeedleCmd() (*cobra.Command, error) {
+	needleCmd.PersistentFlags().StringVar(&configFile, "config", "", "Config file path (YAML or TOML)")
+	if err := bindFlag("config"); err != nil {
+		return nil, err
+	}
+
 	needleCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Log level (debug, info, warn, error, fatal, panic)")
 	if err := bindFlag("log-level"); err != nil {
 		return nil, err
@@ -57,11 +126,29 @@ func NewNeedleCmd() (*cobra.Command, error) {
 		return nil, err
 	}
 
+	needleCmd.PersistentFlags().StringSliceVar(
+		&caTrustedFiles, "ca-trusted", nil, "Retired or upcoming CA Certificate paths trusted during a CA rotation")
+	if err := bindFlag("ca-trusted"); err != nil {
+		return nil, err
+	}
+
 	needleCmd.PersistentFlags().StringVar(&dbFile, "db-file", "data/cache.db", "Cache DB path")
 	if err := bindFlag("db-file"); err != nil {
 		return nil, err
 	}
 
+	needleCmd.PersistentFlags().StringVar(
+		&dbKeyFile, "db-key-file", "", "File holding id:secret keys used to encrypt private keys, first is active")
+	if err := bindFlag("db-key-file"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringSliceVar(
+		&dbKeys, "db-keys", nil, "id:secret keys used to encrypt private keys, first is active")
+	if err := bindFlag("db-keys"); err != nil {
+		return nil, err
+	}
+
 	needleCmd.PersistentFlags().StringVar(&httpPort, "http-port", "80", "HTTP port")
 	if err := bindFlag("http-port"); err != nil {
 		return nil, err
@@ -83,6 +170,137 @@ func NewNeedleCmd() (*cobra.Command, error) {
 		return nil, err
 	}
 
+	needleCmd.PersistentFlags().StringSliceVar(
+		&stubRules, "stub-rules", nil, "selector=stub rules choosing blocked responses, such as ext:.js=empty")
+	if err := bindFlag("stub-rules"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().BoolVar(
+		&surrogatesEnabled, "surrogates", true, "Serve no-op surrogates of blocked tracker scripts")
+	if err := bindFlag("surrogates"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringVar(
+		&surrogatesDir, "surrogates-dir", "", "Directory of scripts replacing built-in surrogates of the same name")
+	if err := bindFlag("surrogates-dir"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().BoolVar(
+		&blockPageEnabled, "block-page", true, "Serve a block page to navigations to blocked domains")
+	if err := bindFlag("block-page"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringVar(
+		&unblockPassphrase, "unblock-passphrase", "", "Passphrase allowing a domain from the block page, empty to disable")
+	if err := bindFlag("unblock-passphrase"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().DurationVar(
+		&unblockDuration, "unblock-duration", 10*time.Minute, "How long a domain allowed from the block page resolves")
+	if err := bindFlag("unblock-duration"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().BoolVar(
+		&tlsBlockedOnly, "tls-blocked-only", false, "Only issue certificates for blocked server names")
+	if err := bindFlag("tls-blocked-only"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringSliceVar(
+		&blocklistSources, "blocklists", nil, "name=location lists of blocked domains, files or http(s) URLs")
+	if err := bindFlag("blocklists"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringSliceVar(
+		&blocklistAllow, "blocklist-allow", nil, "Domains excepted from blocklists, ||domain^ also excepts subdomains")
+	if err := bindFlag("blocklist-allow"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().DurationVar(
+		&blocklistRefresh, "blocklist-refresh", 24*time.Hour, "Interval between blocklists updates, 0 disables them")
+	if err := bindFlag("blocklist-refresh"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().Float64Var(
+		&blocklistMaxShrink, "blocklist-max-shrink", 0.5,
+		"Reject updates removing more than this ratio of a blocklist entries, 0 disables the check")
+	if err := bindFlag("blocklist-max-shrink"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringSliceVar(
+		&groupClients, "group-clients", nil, "group=client members of client groups, IP, CIDR or MAC addresses")
+	if err := bindFlag("group-clients"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringSliceVar(
+		&groupBlocklists, "group-blocklists", nil,
+		"group=list blocklists enforced for a client group, all when unset, none with group=none")
+	if err := bindFlag("group-blocklists"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringSliceVar(
+		&groupAllow, "group-allow", nil, "group=domain domains excepted from blocklists for a client group")
+	if err := bindFlag("group-allow"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringVar(
+		&dhcpLeases, "dhcp-leases", "", "dnsmasq or ISC dhcpd lease file, required by MAC address clients")
+	if err := bindFlag("dhcp-leases"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringArrayVar(
+		&scheduleWindows, "schedules", nil,
+		"name=days hh:mm-hh:mm weekly windows, such as school-nights=sun-thu 21:00-07:00, repeated for several windows")
+	if err := bindFlag("schedules"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringVar(
+		&scheduleTimezone, "schedule-timezone", "", "IANA time zone of schedules, such as Europe/Paris, local time when empty")
+	if err := bindFlag("schedule-timezone"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringSliceVar(
+		&groupSchedules, "group-schedules", nil, "group=schedule client groups only applying during a schedule")
+	if err := bindFlag("group-schedules"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringSliceVar(
+		&blocklistSchedules, "blocklist-schedules", nil, "list=schedule blocklists only enforced during a schedule")
+	if err := bindFlag("blocklist-schedules"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringSliceVar(
+		&categoryDatasets, "category-datasets", nil,
+		"Files classifying domains by category and company: Disconnect services or entities JSON, or text")
+	if err := bindFlag("category-datasets"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringSliceVar(
+		&categoryNames, "categories", nil,
+		"Categories blocked as blocklists named after them: ads, analytics, social, malware, adult or telemetry")
+	if err := bindFlag("categories"); err != nil {
+		return nil, err
+	}
+
 	needleCmd.PersistentFlags().BoolVar(&corednsEnabled, "coredns", false, "Enable embedded CoreDNS")
 	if err := bindFlag("coredns"); err != nil {
 		return nil, err
@@ -109,12 +327,120 @@ func NewNeedleCmd() (*cobra.Command, error) {
 		return nil, err
 	}
 
+	needleCmd.PersistentFlags().StringVar(
+		&corednsMetricsAddr, "coredns-metrics-addr", "localhost:9153", "CoreDNS metrics listen address")
+	if err := bindFlag("coredns-metrics-addr"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringVar(
+		&corednsBlockMode, "coredns-block-mode", string(coredns.ModeAddress),
+		"Answer to queries for blocklisted names: address, null, nxdomain, nodata or refused")
+	if err := bindFlag("coredns-block-mode"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringSliceVar(
+		&corednsListModes, "coredns-list-modes", nil, "list=mode answers overriding coredns-block-mode for a blocklist")
+	if err := bindFlag("coredns-list-modes"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().BoolVar(
+		&corednsCNAMECheck, "coredns-cname-check", true,
+		"Block queries answered with a CNAME chain leading to a blocklisted name")
+	if err := bindFlag("coredns-cname-check"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringVar(
+		&adminAddr, "admin-addr", "127.0.0.1:8081", "Admin server listen address, empty to disable")
+	if err := bindFlag("admin-addr"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringSliceVar(
+		&adminTokens, "admin-tokens", nil, "Bearer tokens accepted by the admin API")
+	if err := bindFlag("admin-tokens"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringVar(&adminTLSCert, "admin-tls-cert", "", "Admin server TLS certificate path")
+	if err := bindFlag("admin-tls-cert"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringVar(&adminTLSKey, "admin-tls-key", "", "Admin server TLS key path")
+	if err := bindFlag("admin-tls-key"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringVar(
+		&adminClientCA, "admin-client-ca", "", "CA certificate path verifying admin API client certificates")
+	if err := bindFlag("admin-client-ca"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringVar(
+		&queryLogDir, "querylog-dir", "data/querylog", "Query log directory, empty to disable")
+	if err := bindFlag("querylog-dir"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().DurationVar(
+		&queryLogRetention, "querylog-retention", 7*24*time.Hour, "How long query log entries are kept")
+	if err := bindFlag("querylog-retention"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().Int64Var(
+		&queryLogMaxSize, "querylog-max-size", 100, "Query log size in MiB above which the oldest entries are deleted")
+	if err := bindFlag("querylog-max-size"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringSliceVar(
+		&lanAddrs, "lan-addresses", nil,
+		"Needle addresses answered for blocklisted names and added to certificates, detected when empty")
+	if err := bindFlag("lan-addresses"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringSliceVar(
+		&lanInterfaces, "lan-interfaces", nil, "Network interfaces needle addresses are detected on, all when empty")
+	if err := bindFlag("lan-interfaces"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().StringVar(
+		&tracingEndpoint, "tracing-endpoint", "", "OTLP/HTTP collector URL spans are exported to, empty to disable")
+	if err := bindFlag("tracing-endpoint"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentFlags().Float64Var(&tracingSampleRatio, "tracing-sample-ratio", 1, "Ratio of traces sampled")
+	if err := bindFlag("tracing-sample-ratio"); err != nil {
+		return nil, err
+	}
+
+	needleCmd.PersistentPreRunE = func(cmd *cobra.Command, _ []string) error {
+		return readConfig(cmd.Root().PersistentFlags())
+	}
+
+	needleCmd.AddCommand(newDBCmd())
+	needleCmd.AddCommand(newCACmd())
+	needleCmd.AddCommand(newConfigCmd())
+	needleCmd.AddCommand(newBlocklistsCmd())
+
 	return needleCmd, nil
 }
 
-func start(_ *cobra.Command, _ []string) error {
+func start(cmd *cobra.Command, _ []string) error {
 	// Setup logger
-	logger := log.New(log.WithLevel(logLevel))
+	logger, level, err := newLogger(logLevel)
+	if err != nil {
+		return errors.Wrap(err, "log-level")
+	}
 	logger = logger.With(fields.Service("needle", version.REVISION))
 	defer func() {
 		err := logger.Sync()
@@ -135,30 +461,24 @@ func start(_ *cobra.Command, _ []string) error {
 		fields.String("server-shutdown-timeout", httpServerShutdownTimeout.String()),
 	)
 
-	if corednsEnabled {
-		dnsServer := coredns.NewCoreDNSServer(
-			coredns.WithLogger(logger),
-			coredns.WithPort(corednsPort),
-			coredns.WithHostsFile(corednsHostsFile),
-			coredns.WithUpstreams(corednsUpstreams),
-			coredns.WithCoreFile(corednsCoreFile),
+	// Setup tracing, spans are flushed once every server is stopped
+	if tracingEndpoint != "" {
+		tp, err := tracing.New(
+			cmd.Context(),
+			tracing.WithEndpoint(tracingEndpoint),
+			tracing.WithService("needle", version.REVISION),
+			tracing.WithSampleRatio(tracingSampleRatio),
 		)
-
-		// Start CoreDNS Server
-		go func() {
-			if err := dnsServer.Run(); err != nil {
-				logger.Error("failed to run CoreDNS server", fields.Error(err))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), httpServerShutdownTimeout)
+			defer cancel()
+			if err := tp.Shutdown(ctx); err != nil {
+				logger.Error("an error occurred while flushing spans", fields.Error(err))
 			}
 		}()
-
-		logger.Debug("CoreDNS Server started")
-		logger.Debug(
-			"CoreDNS Configuration",
-			fields.Int("port", corednsPort),
-			fields.String("hostsfile", corednsHostsFile),
-			fields.Strings("upstreams", corednsUpstreams),
-			fields.String("corefile", corednsCoreFile),
-		)
 	}
 
 	// Setup tls certificate service
@@ -167,7 +487,12 @@ func start(_ *cobra.Command, _ []string) error {
 		return err
 	}
 
-	// Setup BoltDB repository
+	trustedCAs, err := loadCertificates(caTrustedFiles...)
+	if err != nil {
+		return err
+	}
+
+	// Setup BoltDB repository, closed once every server is stopped
 	client, err := newStormClient(dbFile)
 	if err != nil {
 		return err
@@ -179,71 +504,429 @@ func start(_ *cobra.Command, _ []string) error {
 		}
 	}()
 
-	// Setup PKI service
+	repo, err := newRepository(client)
+	if err != nil {
+		return err
+	}
+
+	// Setup metrics, CoreDNS metrics are registered with the default registry
+	m := metrics.New(
+		metrics.WithGatherer(prometheus.DefaultGatherer),
+		metrics.WithStoredCount(func() (int, error) {
+			return boltdb.Count(client)
+		}),
+	)
+
+	// Setup PKI service, needle addresses are detected again when the
+	// DHCP lease changes and added to certificates issued from then on
+	certFactory := factory.New(rootCA, trustedCAs...)
+	lan, err := newLANWatcher(logger, certFactory)
+	if err != nil {
+		return err
+	}
+	certFactory.SetAddresses(lan.Addresses()...)
 	pkiSvc := pki.New(
-		boltdb.New(client),
-		factory.New(rootCA),
+		tracing.NewRepository(m.NewRepository(repo)),
+		tracing.NewFactory(m.NewFactory(certFactory)),
 	)
 
+	// Blocklists are compiled before serving, sources failing to load are
+	// logged. Enabled categories of the datasets are blocklists too.
+	dataset, categories, err := newCategories()
+	if err != nil {
+		return err
+	}
+	lists, err := newBlocklists(logger, dataset, categories)
+	if err != nil {
+		return err
+	}
+	if lists != nil {
+		_ = lists.Load(cmd.Context())
+	}
+
+	// Client groups enforce their own blocklists and allow rules, groups and
+	// blocklists with a schedule only apply during it
+	schedules, err := newSchedules()
+	if err != nil {
+		return err
+	}
+	groups, leaseWatcher, err := newPolicy(logger, schedules)
+	if err != nil {
+		return err
+	}
+	blocker := clientBlocker{lists: lists, policy: groups}
+
+	// Domains are blocked in the hosts file of the generated Corefile and
+	// in the blocklists, the block page allows them in both
+	var hosts *coredns.Hosts
+	var allows *coredns.TemporaryAllows
+	var unblocker unblockers
+	if corednsEnabled && corednsCoreFile == "" {
+		hosts = coredns.NewHosts(corednsHostsFile)
+		allows = coredns.NewTemporaryAllows(logger, hosts)
+		unblocker = append(unblocker, allows)
+	}
+	if lists != nil {
+		unblocker = append(unblocker, lists)
+	}
+
 	// Setup certificate handler and tls.Config
-	certHandler := handlers.NewTLSHandler(logger, pkiSvc)
+	var tlsOpts []handlers.TLSOption
+	if tlsBlockedOnly {
+		tlsOpts = append(tlsOpts, handlers.WithServerNames(func(client net.IP, name string) bool {
+			_, blocked, err := blockedBy(context.Background(), blocker, hosts, client, name)
+			return err == nil && blocked
+		}))
+	}
+	certHandler := handlers.NewTLSHandler(logger, tracing.NewService(m.NewService(pkiSvc)), tlsOpts...)
 	tlsConfig := &tls.Config{
 		MinVersion:     tls.VersionTLS12,
-		GetCertificate: certHandler,
+		GetCertificate: m.InstrumentGetCertificate(certHandler),
 		NextProtos:     []string{"h2", "http/1.1"},
 	}
 
 	// Setup http handler
+	stubOpts, err := stubOptions()
+	if err != nil {
+		return err
+	}
+
+	defaultHandler := handlers.NewDefaultHandler(stubOpts...)
+	if surrogatesEnabled {
+		surrogates, err := surrogate.New(surrogate.WithLogger(logger), surrogate.WithDir(surrogatesDir))
+		if err != nil {
+			return err
+		}
+		defaultHandler = surrogates.Handler(defaultHandler)
+	}
+	if blockPageEnabled {
+		defaultHandler = newBlockPage(logger, blocker, hosts, unblocker).Handler(defaultHandler)
+	}
+
 	routes := []http.Route{
 		{
 			Path:    "/install-root-ca",
-			Handler: handlers.NewCAHandler(caFile),
+			Handler: handlers.NewCAHandler(certFactory.Roots),
 		},
 		{
 			Path:    "/",
-			Handler: handlers.NewDefaultHandler(),
+			Handler: defaultHandler,
 		},
 	}
 
+	// Blocked traffic statistics for the dashboard, also counted by the
+	// company owning domains
+	var statsOpts []stats.Option
+	if dataset != nil {
+		statsOpts = append(statsOpts, stats.WithEntities(dataset.Entity))
+	}
+	traffic := stats.New(statsOpts...)
+
+	// Persistent log of blocked requests and DNS queries
+	var queryLog *querylog.Log
+	if queryLogDir != "" {
+		opts := []querylog.Option{
+			querylog.WithDir(queryLogDir),
+			querylog.WithRetention(queryLogRetention),
+			querylog.WithMaxSize(queryLogMaxSize << 20),
+			querylog.WithLogger(logger),
+		}
+		if dataset != nil {
+			opts = append(opts, querylog.WithClassify(newClassify(dataset)))
+		}
+		queryLog, err = querylog.Open(opts...)
+		if err != nil {
+			return err
+		}
+	}
+
 	router := http.NewRouter(logger, routes...)
+	router.Use(middleware.Metrics(m), middleware.Metrics(traffic))
+	if queryLog != nil {
+		router.Use(middleware.Metrics(queryLog))
+	}
 
-	tlsSrv, err := server.NewServer(
-		server.WithName("needle-tls"),
-		server.WithLogger(logger),
-		server.WithRouter(router),
-		server.WithPort(httpsPort),
-		server.WithHTTPServerTimeout(httpServerTimeout),
-		server.WithHTTPServerShutdownTimeout(httpServerShutdownTimeout),
-		server.WithTLSConfig(tlsConfig),
+	httpSrv := http.NewServer(
+		http.WithName("needle-http"),
+		http.WithLogger(logger),
+		http.WithRouter(router),
+		http.WithPort(httpPort),
+		http.WithHTTPServerTimeout(httpServerTimeout),
 	)
-	if err != nil {
-		return err
+
+	tlsSrv := http.NewServer(
+		http.WithName("needle-tls"),
+		http.WithLogger(logger),
+		http.WithRouter(router),
+		http.WithPort(httpsPort),
+		http.WithHTTPServerTimeout(httpServerTimeout),
+		http.WithTLSConfig(tlsConfig),
+	)
+
+	// Components are shut down in this order
+	components := []supervisor.Component{
+		{Name: "needle-http", Serve: httpSrv.ListenAndServe, Shutdown: httpSrv.Shutdown},
+		{Name: "needle-tls", Serve: tlsSrv.ListenAndServe, Shutdown: tlsSrv.Shutdown},
+	}
+
+	var dnsServer *coredns.DNSServer
+	if corednsEnabled {
+		dnsOpts := []coredns.Option{
+			coredns.WithLogger(logger),
+			coredns.WithPort(corednsPort),
+			coredns.WithHostsFile(corednsHostsFile),
+			coredns.WithUpstreams(corednsUpstreams),
+			coredns.WithCoreFile(corednsCoreFile),
+			coredns.WithMetricsAddr(corednsMetricsAddr),
+		}
+		if queryLog != nil {
+			dnsOpts = append(dnsOpts, coredns.WithQueryLog(queryLog))
+		}
+		if lists != nil {
+			mode, listModes, err := blockModes(corednsBlockMode, corednsListModes)
+			if err != nil {
+				return err
+			}
+			dnsOpts = append(dnsOpts,
+				coredns.WithBlocker(blocker),
+				coredns.WithBlockAddresses(lan.Addresses),
+				coredns.WithBlockMode(mode, listModes),
+				coredns.WithCNAMECheck(corednsCNAMECheck),
+			)
+		}
+		dnsServer = coredns.NewCoreDNSServer(dnsOpts...)
+
+		logger.Debug(
+			"CoreDNS Configuration",
+			fields.Int("port", corednsPort),
+			fields.String("hostsfile", corednsHostsFile),
+			fields.Strings("upstreams", corednsUpstreams),
+			fields.String("corefile", corednsCoreFile),
+		)
+	}
+
+	// Reload CA and CoreDNS on SIGHUP
+	reload := &reloader{
+		flags:      cmd.Root().PersistentFlags(),
+		logger:     logger,
+		factory:    certFactory,
+		dnsServer:  dnsServer,
+		blocklists: lists,
+		level:      level,
+	}
+	stopReload := make(chan struct{})
+	defer close(stopReload)
+	go reload.watch(stopReload)
+
+	if dnsServer != nil {
+		components = append(components, supervisor.Component{
+			Name: "coredns", Serve: dnsServer.Run, Shutdown: dnsServer.Shutdown, Restart: true,
+		})
+	}
+
+	components = append(components, supervisor.Component{
+		Name: "lanaddr", Serve: lan.Serve, Shutdown: lan.Shutdown,
+	})
+
+	// MAC addresses of clients follow the changes of the lease file
+	if leaseWatcher != nil {
+		components = append(components, supervisor.Component{
+			Name: "leases", Serve: leaseWatcher.Serve, Shutdown: leaseWatcher.Shutdown,
+		})
+	}
+
+	// temporarily allowed domains are blocked again once expired, sources
+	// are fetched again every --blocklist-refresh
+	if lists != nil {
+		components = append(components, supervisor.Component{
+			Name: "blocklists", Serve: lists.Serve, Shutdown: lists.Shutdown,
+		})
+	}
+
+	// temporarily allowed domains are blocked again on shutdown
+	if allows != nil {
+		components = append(components, supervisor.Component{
+			Name: "allows", Serve: allows.Serve, Shutdown: allows.Shutdown,
+		})
 	}
 
-	// Start TLS Server
-	go func() {
-		err := tlsSrv.ListenAndServe()
+	// the query log is flushed once the servers recording to it are stopped
+	if queryLog != nil {
+		components = append(components, supervisor.Component{
+			Name: "querylog", Serve: queryLog.Serve, Shutdown: queryLog.Shutdown,
+		})
+	}
+
+	// Setup Admin Server
+	var sup *supervisor.Supervisor
+	if adminAddr != "" {
+		auth, adminTLSConfig, err := newAdminAuth()
 		if err != nil {
-			logger.Error("TLS server failed to start", fields.Error(err))
+			return err
+		}
+
+		adminOpts := []admin.Option{
+			admin.WithLogger(logger),
+			admin.WithAuth(auth),
+			admin.WithCertificates(repo),
+			admin.WithCA(certFactory.Roots),
+			admin.WithTraffic(traffic),
+			admin.WithBlocklists(newBlocklistsFunc(lists)),
+			admin.WithSchedules(newSchedulesFunc(schedules, groups)),
+			admin.WithStats(func(_ context.Context) (map[string]float64, error) {
+				return m.Stats()
+			}),
+			admin.WithConfig(func() map[string]any {
+				return effectiveConfig(cmd.Root().PersistentFlags())
+			}),
+			admin.WithReload(reload.Reload),
+			admin.WithBackup(func(w io.Writer) (int64, error) {
+				return boltdb.Backup(client, w)
+			}),
+		}
+		if queryLog != nil {
+			adminOpts = append(adminOpts, admin.WithQueryLog(queryLog))
+		}
+		if hosts != nil {
+			adminOpts = append(adminOpts, admin.WithDomains(hosts))
+		}
+		if lists != nil {
+			adminOpts = append(adminOpts, admin.WithBlocklistUpdates(lists))
+		}
+		adminAPI := admin.New(adminOpts...)
+
+		// liveness only covers components, readiness also checks their dependencies
+		var liveness []health.Option
+		for _, c := range components {
+			name := c.Name
+			liveness = append(liveness, health.WithCheck(name, func(_ context.Context) error {
+				return sup.Running(name)
+			}))
 		}
-	}()
 
-	httpSrv, err := server.NewServer(
-		server.WithName("needle-http"),
-		server.WithLogger(logger),
-		server.WithRouter(router),
-		server.WithPort(httpPort),
-		server.WithHTTPServerTimeout(httpServerTimeout),
-		server.WithHTTPServerShutdownTimeout(httpServerShutdownTimeout),
+		readiness := append([]health.Option{
+			health.WithCheck("https", tlsSrv.Check),
+			health.WithCheck("ca", func(_ context.Context) error {
+				return certFactory.CheckExpiry(caExpiryThreshold)
+			}),
+			health.WithCheck("repository", func(_ context.Context) error {
+				return boltdb.Ping(client)
+			}),
+		}, liveness...)
+		if dnsServer != nil {
+			readiness = append(readiness, health.WithCheck("dns", dnsServer.Check))
+		}
+
+		adminSrv := http.NewServer(
+			http.WithName("needle-admin"),
+			http.WithLogger(logger),
+			http.WithAddr(adminAddr),
+			http.WithHTTPServerTimeout(httpServerTimeout),
+			http.WithTLSConfig(adminTLSConfig),
+			http.WithRouter(http.NewRouter(
+				logger,
+				http.Route{
+					Path:    "/healthz",
+					Handler: handlers.NewHealthHandler(logger, health.New(liveness...)),
+				},
+				http.Route{
+					Path:    "/readyz",
+					Handler: handlers.NewHealthHandler(logger, health.New(readiness...)),
+				},
+				http.Route{
+					Path:    "/metrics",
+					Handler: auth.Middleware(logger)(m.Handler()),
+				},
+				http.Route{
+					Path:    "/api/",
+					Handler: adminAPI.Handler(),
+				},
+				http.Route{
+					Path:    "/",
+					Handler: auth.Middleware(logger)(dashboard.Handler()),
+				},
+			)),
+		)
+
+		// the admin server is shut down before CoreDNS, like the other listeners
+		components = slices.Insert(components, 2, supervisor.Component{
+			Name: "needle-admin", Serve: adminSrv.ListenAndServe, Shutdown: adminSrv.Shutdown,
+		})
+	}
+
+	// Run until SIGINT/SIGTERM or until a server fails
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	sup = supervisor.New(
+		supervisor.WithLogger(logger),
+		supervisor.WithShutdownTimeout(httpServerShutdownTimeout),
+		supervisor.WithComponents(components...),
 	)
+
+	return sup.Run(ctx)
+}
+
+// newLANWatcher returns the watcher of needle addresses, fixed by
+// --lan-addresses, updating the addresses of f.
+func newLANWatcher(logger log.Logger, f *factory.Factory) (*lanaddr.Watcher, error) {
+	addresses, err := lanAddresses(lanAddrs)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Start HTTP Server
-	if err := httpSrv.ListenAndServe(); err != nil {
-		return err
+	opts := []lanaddr.Option{
+		lanaddr.WithLogger(logger),
+		lanaddr.WithInterfaceNames(lanInterfaces...),
+		lanaddr.WithOnChange(func(addresses []net.IP) {
+			f.SetAddresses(addresses...)
+		}),
+	}
+	if len(addresses) > 0 {
+		opts = append(opts, lanaddr.WithAddresses(addresses...))
 	}
+	return lanaddr.New(opts...)
+}
 
-	return nil
+// lanAddresses parses --lan-addresses.
+func lanAddresses(addresses []string) ([]net.IP, error) {
+	ips := make([]net.IP, 0, len(addresses))
+	for _, address := range addresses {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return nil, errors.Errorf("lan-addresses %q is not an IP address", address)
+		}
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
+
+// stubOptions parses --stub-rules.
+func stubOptions() ([]handlers.StubOption, error) {
+	opts := make([]handlers.StubOption, 0, len(stubRules))
+	for _, rule := range stubRules {
+		selector, stub, err := handlers.ParseStubRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, handlers.WithStubRule(selector, stub))
+	}
+	return opts, nil
+}
+
+// newBlockPage returns the block page, domains can be allowed from it when
+// needle serves the hosts file or blocklists and --unblock-passphrase is set.
+func newBlockPage(
+	logger log.Logger, blocker clientBlocker, hosts *coredns.Hosts, unblocker unblockers,
+) *blockpage.BlockPage {
+	opts := []blockpage.Option{blockpage.WithLogger(logger)}
+	if blocker.lists != nil || hosts != nil {
+		opts = append(opts,
+			blockpage.WithLookup(func(ctx context.Context, client net.IP, domain string) (string, bool, error) {
+				return blockedBy(ctx, blocker, hosts, client, domain)
+			}),
+			blockpage.WithUnblock(unblocker, unblockPassphrase, unblockDuration),
+		)
+	}
+	return blockpage.New(opts...)
 }
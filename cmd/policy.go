@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.pixelfactory.io/pkg/observability/log"
+
+	"go.pixelfactory.io/needle/internal/app/blocklist"
+	"go.pixelfactory.io/needle/internal/app/policy"
+	"go.pixelfactory.io/needle/internal/app/schedule"
+	"go.pixelfactory.io/needle/internal/infra/leases"
+)
+
+// noLists is the --group-blocklists value enforcing no blocklist.
+const noLists = "none"
+
+// newPolicy returns the client groups of --group-* flags with the list
+// schedules of --blocklist-schedules, and the watcher of --dhcp-leases, nil
+// when it is not set.
+func newPolicy(
+	logger log.Logger, schedules map[string]*schedule.Schedule,
+) (*policy.Policy, *leases.Watcher, error) {
+	groups, err := policyGroups(schedules)
+	if err != nil {
+		return nil, nil, err
+	}
+	lists, err := listSchedules(schedules)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := []policy.Option{policy.WithGroups(groups...), policy.WithListSchedules(lists)}
+
+	var watcher *leases.Watcher
+	if dhcpLeases != "" {
+		watcher, err = leases.New(dhcpLeases, leases.WithLogger(logger))
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, policy.WithLeases(watcher))
+	}
+
+	p, err := policy.New(opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, watcher, nil
+}
+
+// policyGroups parses --group-clients, --group-blocklists, --group-allow and
+// --group-schedules, groups are ordered by their first appearance.
+func policyGroups(schedules map[string]*schedule.Schedule) ([]policy.Group, error) {
+	var groups []*policy.Group
+	byName := map[string]*policy.Group{}
+	group := func(flag, s string) (*policy.Group, string, error) {
+		name, value, ok := strings.Cut(s, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, "", errors.Errorf("%s %q must be written group=value", flag, s)
+		}
+
+		g, ok := byName[name]
+		if !ok {
+			g = &policy.Group{Name: name}
+			byName[name] = g
+			groups = append(groups, g)
+		}
+		return g, strings.TrimSpace(value), nil
+	}
+
+	for _, s := range groupClients {
+		g, value, err := group("group-clients", s)
+		if err != nil {
+			return nil, err
+		}
+		client, err := policy.ParseClient(value)
+		if err != nil {
+			return nil, errors.Wrap(err, "group-clients")
+		}
+		g.Clients = append(g.Clients, client)
+	}
+
+	for _, s := range groupBlocklists {
+		g, value, err := group("group-blocklists", s)
+		if err != nil {
+			return nil, err
+		}
+		if g.Lists == nil {
+			g.Lists = []string{}
+		}
+		if value != noLists {
+			g.Lists = append(g.Lists, value)
+		}
+	}
+
+	for _, s := range groupAllow {
+		g, value, err := group("group-allow", s)
+		if err != nil {
+			return nil, err
+		}
+		rule, err := blocklist.ParseRule(value)
+		if err != nil {
+			return nil, errors.Wrap(err, "group-allow")
+		}
+		g.Allow = append(g.Allow, rule)
+	}
+
+	for _, s := range groupSchedules {
+		g, _, err := group("group-schedules", s)
+		if err != nil {
+			return nil, err
+		}
+		_, sched, err := scheduleOf(schedules, "group-schedules", s)
+		if err != nil {
+			return nil, err
+		}
+		g.Schedule = sched
+	}
+
+	result := make([]policy.Group, len(groups))
+	for i, g := range groups {
+		result[i] = *g
+	}
+	return result, nil
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"go.pixelfactory.io/pkg/observability/log"
+	"go.pixelfactory.io/pkg/observability/log/fields"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"go.pixelfactory.io/needle/internal/app/blocklist"
+	"go.pixelfactory.io/needle/internal/app/factory"
+	"go.pixelfactory.io/needle/internal/infra/coredns"
+)
+
+// reloader re-reads configuration and CA material without interrupting listeners.
+type reloader struct {
+	mu        sync.Mutex
+	flags     *pflag.FlagSet
+	logger    log.Logger
+	factory   *factory.Factory
+	dnsServer *coredns.DNSServer
+	// blocklists are fetched again, changing sources requires a restart
+	blocklists *blocklist.Manager
+	// level is the level of logger and the loggers derived from it
+	level zap.AtomicLevel
+}
+
+// Reload re-reads configuration, reloads the CA pair into the factory, fetches blocklists again,
+// restarts CoreDNS with a re-rendered Corefile and applies the log level.
+func (r *reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.logger.Info("Reloading configuration")
+
+	var errs []error
+	if err := readConfig(r.flags); err != nil {
+		r.logger.Error("failed to reload configuration", fields.Error(err))
+		errs = append(errs, err)
+	}
+
+	rootCA, trustedCAs, err := loadCA()
+	if err != nil {
+		r.logger.Error("failed to reload CA", fields.Error(err))
+		errs = append(errs, err)
+	} else {
+		r.factory.SetCA(rootCA, trustedCAs...)
+		r.logger.Info("CA reloaded", fields.String("ca", viper.GetString("ca")))
+	}
+
+	if r.blocklists != nil {
+		// sources failing to load keep their previous rules
+		if err := r.blocklists.Load(context.Background()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if r.dnsServer != nil {
+		err := r.reloadDNS()
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			r.logger.Info("CoreDNS reloaded")
+		}
+	}
+
+	if level, err := zapcore.ParseLevel(viper.GetString("log-level")); err != nil {
+		r.logger.Error("failed to reload log level", fields.Error(err))
+		errs = append(errs, err)
+	} else if level != r.level.Level() {
+		r.logger.Info("Log level changed", fields.String("log-level", level.String()))
+		r.level.SetLevel(level)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		r.logger.Error("Reload failed", fields.Error(err))
+		return err
+	}
+
+	r.logger.Info("Reload completed")
+	return nil
+}
+
+// reloadDNS restarts CoreDNS with the reloaded configuration.
+func (r *reloader) reloadDNS() error {
+	mode, listModes, err := blockModes(viper.GetString("coredns-block-mode"), viper.GetStringSlice("coredns-list-modes"))
+	if err != nil {
+		return err
+	}
+
+	return r.dnsServer.Reload(
+		coredns.WithPort(viper.GetInt("coredns-port")),
+		coredns.WithHostsFile(viper.GetString("coredns-hosts-file")),
+		coredns.WithUpstreams(viper.GetStringSlice("coredns-upstreams")),
+		coredns.WithCoreFile(viper.GetString("coredns-corefile")),
+		coredns.WithMetricsAddr(viper.GetString("coredns-metrics-addr")),
+		coredns.WithBlockMode(mode, listModes),
+		coredns.WithCNAMECheck(viper.GetBool("coredns-cname-check")),
+	)
+}
+
+// watch reloads on SIGHUP until stop is closed.
+func (r *reloader) watch(stop <-chan struct{}) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	for {
+		select {
+		case <-sighup:
+			_ = r.Reload() // errors are logged by Reload
+		case <-stop:
+			return
+		}
+	}
+}
@@ -2,13 +2,24 @@
 package cmd
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
 	"strings"
 
 	"github.com/asdine/storm/v3"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"go.pixelfactory.io/pkg/observability/log"
 	"go.pixelfactory.io/pkg/version"
+
+	"go.pixelfactory.io/needle/internal/app/factory"
+	"go.pixelfactory.io/needle/internal/app/pki"
+	"go.pixelfactory.io/needle/internal/infra/boltdb"
+	"go.pixelfactory.io/needle/internal/infra/encryption"
 )
 
 var envPrefix = "NEEDLE"
@@ -47,3 +58,94 @@ func newStormClient(dbFile string) (*storm.DB, error) {
 	}
 	return client, nil
 }
+
+// newKeyring loads private key encryption keys from --db-keys and --db-key-file.
+func newKeyring() (*encryption.Keyring, error) {
+	keyring := encryption.NewKeyring()
+	for _, keys := range viper.GetStringSlice("db-keys") {
+		// NEEDLE_DB_KEYS is a comma separated list
+		if err := keyring.Parse(strings.Split(keys, ",")...); err != nil {
+			return nil, err
+		}
+	}
+
+	if file := viper.GetString("db-key-file"); file != "" {
+		if err := keyring.ParseFile(file); err != nil {
+			return nil, err
+		}
+	}
+
+	return keyring, nil
+}
+
+// newRepository creates the certificate repository, encrypting private keys when keys are configured.
+func newRepository(client *storm.DB) (pki.Repository, error) {
+	keyring, err := newKeyring()
+	if err != nil {
+		return nil, err
+	}
+
+	if keyring.Empty() {
+		return boltdb.New(client), nil
+	}
+
+	return encryption.NewRepository(boltdb.New(client), keyring), nil
+}
+
+// loadCertificates parses all PEM certificates from files.
+func loadCertificates(files ...string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, errors.Wrap(err, file)
+			}
+			certs = append(certs, cert)
+		}
+	}
+
+	return certs, nil
+}
+
+// loadCA loads the CA pair and the trusted CAs from --ca, --ca-key and --ca-trusted.
+func loadCA() (tls.Certificate, []*x509.Certificate, error) {
+	rootCA, err := tls.LoadX509KeyPair(viper.GetString("ca"), viper.GetString("ca-key"))
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	trustedCAs, err := loadCertificates(viper.GetStringSlice("ca-trusted")...)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	return rootCA, trustedCAs, nil
+}
+
+// newFactory creates a certificate factory from --ca, --ca-key and --ca-trusted,
+// issuing certificates for the needle addresses.
+func newFactory() (*factory.Factory, error) {
+	rootCA, trustedCAs, err := loadCA()
+	if err != nil {
+		return nil, err
+	}
+
+	f := factory.New(rootCA, trustedCAs...)
+	lan, err := newLANWatcher(log.New(log.WithLevel(logLevel)), f)
+	if err != nil {
+		return nil, err
+	}
+	f.SetAddresses(lan.Addresses()...)
+
+	return f, nil
+}
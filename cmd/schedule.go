@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+	// time zones of schedules on systems without a time zone database
+	_ "time/tzdata"
+
+	"github.com/pkg/errors"
+
+	"go.pixelfactory.io/needle/internal/app/policy"
+	"go.pixelfactory.io/needle/internal/app/schedule"
+	"go.pixelfactory.io/needle/internal/infra/http/admin"
+)
+
+// newSchedules parses --schedules in --schedule-timezone, by name.
+func newSchedules() (map[string]*schedule.Schedule, error) {
+	loc := time.Local
+	if scheduleTimezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(scheduleTimezone); err != nil {
+			return nil, errors.Wrap(err, "schedule-timezone")
+		}
+	}
+
+	var names []string
+	windows := map[string][]string{}
+	for _, s := range scheduleWindows {
+		name, window, ok := strings.Cut(s, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, errors.Errorf("schedules %q must be written name=days hh:mm-hh:mm", s)
+		}
+		if _, ok := windows[name]; !ok {
+			names = append(names, name)
+		}
+		windows[name] = append(windows[name], window)
+	}
+
+	schedules := make(map[string]*schedule.Schedule, len(names))
+	for _, name := range names {
+		s, err := schedule.Parse(name, loc, windows[name]...)
+		if err != nil {
+			return nil, errors.Wrap(err, "schedules")
+		}
+		schedules[name] = s
+	}
+	return schedules, nil
+}
+
+// scheduleOf returns the schedule of a name=schedule value of flag.
+func scheduleOf(schedules map[string]*schedule.Schedule, flag, s string) (string, *schedule.Schedule, error) {
+	name, value, ok := strings.Cut(s, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return "", nil, errors.Errorf("%s %q must be written name=schedule", flag, s)
+	}
+
+	sched, ok := schedules[strings.TrimSpace(value)]
+	if !ok {
+		return "", nil, errors.Errorf("%s %q is not a schedule", flag, value)
+	}
+	return name, sched, nil
+}
+
+// listSchedules parses --blocklist-schedules.
+func listSchedules(schedules map[string]*schedule.Schedule) (map[string]*schedule.Schedule, error) {
+	result := make(map[string]*schedule.Schedule, len(blocklistSchedules))
+	for _, s := range blocklistSchedules {
+		list, sched, err := scheduleOf(schedules, "blocklist-schedules", s)
+		if err != nil {
+			return nil, err
+		}
+		result[list] = sched
+	}
+	return result, nil
+}
+
+// newSchedulesFunc reports the schedules, whether they are active and the
+// groups and blocklists they apply to.
+func newSchedulesFunc(schedules map[string]*schedule.Schedule, p *policy.Policy) admin.SchedulesFunc {
+	return func(_ context.Context) ([]admin.Schedule, error) {
+		now := time.Now()
+
+		result := make([]admin.Schedule, 0, len(schedules))
+		for _, s := range schedules {
+			state := admin.Schedule{
+				Name:       s.Name,
+				Timezone:   s.Location.String(),
+				Active:     s.Active(now),
+				Groups:     []string{},
+				Blocklists: []string{},
+			}
+			for _, w := range s.Windows {
+				state.Windows = append(state.Windows, w.String())
+			}
+			if next := s.Next(now); !next.IsZero() {
+				state.NextChange = &next
+			}
+
+			for _, g := range p.Groups() {
+				if g.Schedule == s {
+					state.Groups = append(state.Groups, g.Name)
+				}
+			}
+			for list, ls := range p.ListSchedules() {
+				if ls == s {
+					state.Blocklists = append(state.Blocklists, list)
+				}
+			}
+			slices.Sort(state.Blocklists)
+
+			result = append(result, state)
+		}
+
+		slices.SortFunc(result, func(a, b admin.Schedule) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+		return result, nil
+	}
+}
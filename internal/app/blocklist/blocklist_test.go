@@ -0,0 +1,461 @@
+package blocklist_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/app/blocklist"
+	"go.pixelfactory.io/pkg/observability/log"
+)
+
+// fetcher serves lists from memory.
+type fetcher map[string]string
+
+func (f fetcher) Fetch(_ context.Context, location string) (io.ReadCloser, error) {
+	list, ok := f[location]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return io.NopCloser(strings.NewReader(list)), nil
+}
+
+func Test_Parse(t *testing.T) {
+	is := require.New(t)
+
+	rules, skipped, err := blocklist.Parse(strings.NewReader(`
+# hosts
+127.0.0.1 localhost
+0.0.0.0 Ads.Needle.Local. pixel.needle.local # trailing comment
+! adblock
+||tracker.needle.local^
+@@||cdn.tracker.needle.local^
+||video.needle.local^$third-party
+||needle.local/banner.gif
+*.metrics.needle.local
+ad*.needle.local
+/^ad[0-9]+\./
+plain.needle.local
+not a domain
+page#anchor.needle.local
+`))
+	is.NoError(err)
+	is.Equal(4, skipped)
+	is.Equal([]blocklist.Rule{
+		{Domain: "ads.needle.local"},
+		{Domain: "pixel.needle.local"},
+		{Domain: "tracker.needle.local", Subdomains: true},
+		{Domain: "cdn.tracker.needle.local", Subdomains: true, Allow: true},
+		{Domain: "metrics.needle.local", Subdomains: true},
+		{Domain: "ad*.needle.local", Wildcard: true},
+		{Domain: `^ad[0-9]+\.`, Regexp: true},
+		{Domain: "plain.needle.local"},
+	}, rules)
+
+	rule, err := blocklist.ParseRule("||good.needle.local^")
+	is.NoError(err)
+	is.Equal(blocklist.Rule{Domain: "good.needle.local", Subdomains: true}, rule)
+
+	_, err = blocklist.ParseRule("0.0.0.0 a.needle.local b.needle.local")
+	is.Error(err)
+}
+
+func Test_ParseAdblock(t *testing.T) {
+	is := require.New(t)
+
+	// EasyList lines, only the domain anchors block names
+	rules, skipped, err := blocklist.Parse(strings.NewReader(`[Adblock Plus 2.0]
+! Title: EasyList
+! Homepage: https://easylist.to/
+&ad_box_
+-ad-banner.
+/ads/
+/banner/*/img^
+||doubleclick.net^
+||adservice.google.com^$third-party
+@@||ads.example.com/ads.js
+@@||pagead2.googlesyndication.com^
+###AdHeader
+##.adsbygoogle
+example.com##.ad-banner
+youtube.com#@#.foo
+bbc.com#?#div:-abp-has(> .ad)
+imdb.com#$#abort-on-property-read adsbygoogle
+`))
+	is.NoError(err)
+	is.Equal(6, skipped)
+	is.Equal([]blocklist.Rule{
+		{Domain: "doubleclick.net", Subdomains: true},
+		{Domain: "pagead2.googlesyndication.com", Subdomains: true, Allow: true},
+	}, rules)
+
+	// element hiding rules make a list without header an Adblock Plus list
+	rules, skipped, err = blocklist.Parse(strings.NewReader(`
+||tracker.needle.local^
+/^ad[0-9]+\./
+needle.local##.ad
+`))
+	is.NoError(err)
+	is.Equal(1, skipped)
+	is.Equal([]blocklist.Rule{{Domain: "tracker.needle.local", Subdomains: true}}, rules)
+}
+
+func Test_Set(t *testing.T) {
+	is := require.New(t)
+
+	set := blocklist.Compile(
+		blocklist.List{Name: "ads", Rules: []blocklist.Rule{
+			{Domain: "ads.needle.local"},
+			{Domain: "tracker.needle.local", Subdomains: true},
+		}},
+		blocklist.List{Name: "trackers", Rules: []blocklist.Rule{
+			{Domain: "tracker.needle.local", Subdomains: true},
+			{Domain: "tracker.needle.local"},
+			{Domain: "cdn.tracker.needle.local", Allow: true},
+		}},
+	)
+
+	lists, ok := set.Match("a.b.Tracker.needle.local.")
+	is.True(ok)
+	is.Equal([]string{"ads", "trackers"}, lists)
+
+	lists, ok = set.Match("ads.needle.local")
+	is.True(ok)
+	is.Equal([]string{"ads"}, lists)
+
+	is.False(set.Blocked("x.ads.needle.local"))
+	is.False(set.Blocked("cdn.tracker.needle.local"))
+	is.True(set.Blocked("img.cdn.tracker.needle.local"))
+
+	is.Equal(3, set.Len())
+	is.Equal(2, set.Count("ads"))
+	is.Equal(2, set.Count("trackers"))
+
+	domains := map[string]bool{}
+	set.Range(func(rule blocklist.Rule) bool {
+		domains[rule.Domain] = rule.Subdomains
+		return true
+	})
+	is.Equal(map[string]bool{"ads.needle.local": false, "tracker.needle.local": true}, domains)
+}
+
+func Test_SetPatterns(t *testing.T) {
+	is := require.New(t)
+
+	rules, skipped, err := blocklist.Parse(strings.NewReader(`
+ad*.needle.local
+||pixel*.needle.local^
+*.metrics-*.needle.local
+ads.*
+/^track[0-9]+\.needle\.local$/
+@@/^ad-ok\./
+@@||adsafe.needle.local^
+`))
+	is.NoError(err)
+	is.Zero(skipped)
+
+	set := blocklist.Compile(
+		blocklist.List{Name: "patterns", Rules: rules},
+		blocklist.List{Name: "ads", Rules: []blocklist.Rule{{Domain: "ads.needle.local"}}},
+	)
+	is.Equal(6, set.Len())
+	is.Equal(5, set.Count("patterns"))
+
+	for name, blocked := range map[string]bool{
+		"ad1.needle.local":            true,
+		"ads.cdn.needle.local":        true,
+		"x.ad1.needle.local":          false,
+		"pixel.needle.local":          true,
+		"a.b.pixel9.needle.local":     true,
+		"metrics-eu.needle.local":     true,
+		"eu.metrics-eu.needle.local":  true,
+		"ads.example.com":             true,
+		"Track42.needle.local.":       true,
+		"track.needle.local":          false,
+		"ad-ok.needle.local":          false,
+		"adsafe.needle.local":         false,
+		"x.adsafe.needle.local":       false,
+		"needle.local":                false,
+		"bad.needle.local":            false,
+		"unrelated.example.org":       false,
+		"ads.needle.local":            true,
+		"www.track1.needle.local.com": false,
+	} {
+		is.Equal(blocked, set.Blocked(name), name)
+	}
+
+	lists, ok := set.Match("ads.needle.local")
+	is.True(ok)
+	is.Equal([]string{"patterns", "ads"}, lists)
+
+	added, removed := blocklist.Compare(blocklist.Compile(), set)
+	is.Empty(removed)
+	is.Equal([]string{
+		"*.metrics-*.needle.local",
+		"*.pixel*.needle.local",
+		"/^track[0-9]+\\.needle\\.local$/",
+		"ad*.needle.local",
+		"ads.*",
+		"ads.needle.local",
+	}, added)
+
+	for _, rule := range []string{"*", "/[/", "||*^", "@@/a(/"} {
+		_, err := blocklist.ParseRule(rule)
+		is.Error(err, rule)
+	}
+}
+
+func Test_SetPatternIndex(t *testing.T) {
+	is := require.New(t)
+
+	rules, skipped, err := blocklist.Parse(strings.NewReader(`
+/^BANNER+s\./
+/(ads|trk)[0-9]\.needle/
+/^(pixel|px)-/
+/metrics/
+@@/^metrics-ok\./
+*stats.*
+`))
+	is.NoError(err)
+	is.Zero(skipped)
+
+	set := blocklist.Compile(blocklist.List{Name: "patterns", Rules: rules})
+	for name, blocked := range map[string]bool{
+		"bannerrrs.needle.local":  true,
+		"Banners.needle.local":    true,
+		"banners-eu.needle.local": false,
+		"ads1.needle.local":       true,
+		"x.trk2.needle.local":     true,
+		"ads.needle.local":        false,
+		"px-eu.needle.local":      true,
+		"eu-px.needle.local":      false,
+		"eu.metrics.needle.local": true,
+		"metrics-ok.needle.local": false,
+		"webstats.needle.local":   true,
+		"www.needle.local":        false,
+	} {
+		is.Equal(blocked, set.Blocked(name), name)
+	}
+}
+
+func Test_ParseSource(t *testing.T) {
+	is := require.New(t)
+
+	source, err := blocklist.ParseSource("ads=https://lists.needle.local/ads.txt")
+	is.NoError(err)
+	is.Equal(blocklist.Source{Name: "ads", Location: "https://lists.needle.local/ads.txt"}, source)
+
+	source, err = blocklist.ParseSource("https://lists.needle.local/ads.txt")
+	is.NoError(err)
+	is.Equal("lists.needle.local", source.Name)
+
+	source, err = blocklist.ParseSource("data/lists/trackers.txt")
+	is.NoError(err)
+	is.Equal("trackers", source.Name)
+
+	_, err = blocklist.ParseSource("=data/ads.txt")
+	is.Error(err)
+}
+
+func Test_Manager(t *testing.T) {
+	is := require.New(t)
+
+	ctx := context.Background()
+	lists := fetcher{
+		"ads.txt":      "0.0.0.0 ads.needle.local\n0.0.0.0 good.needle.local\n",
+		"trackers.txt": "||tracker.needle.local^\n",
+	}
+
+	var changes atomic.Int32
+	now := time.Now()
+	var clock atomic.Int64
+	manager, err := blocklist.New(
+		blocklist.WithLogger(log.New()),
+		blocklist.WithFetcher(lists),
+		blocklist.WithSources(
+			blocklist.Source{Name: "ads", Location: "ads.txt"},
+			blocklist.Source{Name: "trackers", Location: "trackers.txt"},
+		),
+		blocklist.WithLists(blocklist.List{
+			Name:  "social",
+			Rules: []blocklist.Rule{{Domain: "social.needle.local", Subdomains: true}},
+		}),
+		blocklist.WithAllow(blocklist.Rule{Domain: "good.needle.local"}),
+		blocklist.WithOnChange(func(_ context.Context) error {
+			changes.Add(1)
+			return nil
+		}),
+		blocklist.WithClock(func() time.Time { return now.Add(time.Duration(clock.Load())) }),
+	)
+	is.NoError(err)
+	is.False(manager.Blocked("ads.needle.local"))
+
+	is.NoError(manager.Load(ctx))
+	is.Equal(int32(1), changes.Load())
+	is.True(manager.Blocked("ads.needle.local"))
+	is.True(manager.Blocked("pixel.tracker.needle.local"))
+	is.False(manager.Blocked("good.needle.local"))
+	names, ok := manager.Match("www.social.needle.local")
+	is.True(ok)
+	is.Equal([]string{"social"}, names)
+
+	t.Run("Failed sources keep their rules", func(_ *testing.T) {
+		delete(lists, "trackers.txt")
+		err := manager.Load(ctx)
+		is.ErrorContains(err, "trackers")
+		is.True(manager.Blocked("pixel.tracker.needle.local"))
+
+		statuses := manager.Statuses()
+		is.Len(statuses, 3)
+		is.Equal("ads", statuses[0].Name)
+		is.Equal(2, statuses[0].Entries)
+		is.Empty(statuses[0].Error)
+		is.Equal(1, statuses[1].Entries)
+		is.NotEmpty(statuses[1].Error)
+		is.Equal(blocklist.Status{Name: "social", Entries: 1}, statuses[2])
+	})
+
+	t.Run("Temporary allows", func(_ *testing.T) {
+		done := make(chan error, 1)
+		go func() {
+			done <- manager.Serve()
+		}()
+
+		before := changes.Load()
+		is.NoError(manager.Allow(ctx, "ads.needle.local", time.Minute))
+		is.False(manager.Blocked("ads.needle.local"))
+		_, ok := manager.Match("ads.needle.local")
+		is.False(ok)
+		is.Equal(before+1, changes.Load())
+
+		clock.Store(int64(2 * time.Minute))
+		is.Eventually(func() bool { return changes.Load() == before+2 }, 3*time.Second, 50*time.Millisecond)
+		lists, ok := manager.Match("ads.needle.local")
+		is.True(ok)
+		is.Equal([]string{"ads"}, lists)
+
+		is.NoError(manager.Shutdown(ctx))
+		is.NoError(<-done)
+		is.NoError(manager.Shutdown(ctx))
+	})
+
+	t.Run("Shutdown without Serve", func(_ *testing.T) {
+		idle, err := blocklist.New(blocklist.WithLogger(log.New()), blocklist.WithFetcher(lists))
+		is.NoError(err)
+		is.NoError(idle.Shutdown(context.Background()))
+	})
+
+	t.Run("Invalid configuration", func(_ *testing.T) {
+		_, err := blocklist.New(blocklist.WithLogger(log.New()))
+		is.Error(err)
+
+		_, err = blocklist.New(
+			blocklist.WithLogger(log.New()),
+			blocklist.WithFetcher(lists),
+			blocklist.WithSources(blocklist.Source{Name: "ads"}, blocklist.Source{Name: "ads"}),
+		)
+		is.Error(err)
+
+		_, err = blocklist.New(
+			blocklist.WithLogger(log.New()),
+			blocklist.WithFetcher(lists),
+			blocklist.WithSources(blocklist.Source{Name: "ads"}),
+			blocklist.WithLists(blocklist.List{Name: "ads"}),
+		)
+		is.Error(err)
+
+		_, err = blocklist.New(
+			blocklist.WithLogger(log.New()),
+			blocklist.WithFetcher(lists),
+			blocklist.WithMaxShrink(1),
+		)
+		is.Error(err)
+	})
+}
+
+// fetchFunc serves the list returned by the function.
+type fetchFunc func() string
+
+func (f fetchFunc) Fetch(_ context.Context, _ string) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(f())), nil
+}
+
+func Test_Refresh(t *testing.T) {
+	is := require.New(t)
+
+	ctx := context.Background()
+	var list atomic.Value
+	list.Store("a.needle.local\nb.needle.local\nc.needle.local\nd.needle.local\n")
+
+	manager, err := blocklist.New(
+		blocklist.WithLogger(log.New()),
+		blocklist.WithFetcher(fetchFunc(func() string { return list.Load().(string) })),
+		blocklist.WithSources(blocklist.Source{Name: "ads", Location: "ads.txt"}),
+		blocklist.WithRefresh(50*time.Millisecond),
+		blocklist.WithMaxShrink(0.5),
+	)
+	is.NoError(err)
+
+	_, ok := manager.LastDiff()
+	is.False(ok)
+
+	is.NoError(manager.Load(ctx))
+	diff, ok := manager.LastDiff()
+	is.True(ok)
+	is.Equal(4, diff.AddedCount)
+	is.ErrorIs(manager.Rollback(ctx), blocklist.ErrNoRollback)
+	loaded := manager.Statuses()[0]
+
+	t.Run("Scheduled refresh", func(_ *testing.T) {
+		done := make(chan error, 1)
+		go func() {
+			done <- manager.Serve()
+		}()
+
+		list.Store("a.needle.local\nb.needle.local\nc.needle.local\n*.e.needle.local\n")
+		is.Eventually(func() bool { return manager.Blocked("x.e.needle.local") }, 3*time.Second, 20*time.Millisecond)
+
+		diff, ok := manager.LastDiff()
+		is.True(ok)
+		is.Equal([]string{"*.e.needle.local"}, diff.Added)
+		is.Equal([]string{"d.needle.local"}, diff.Removed)
+
+		is.NoError(manager.Shutdown(ctx))
+		is.NoError(<-done)
+	})
+
+	t.Run("Invalid lists are rejected", func(_ *testing.T) {
+		list.Store("a.needle.local\n")
+		is.ErrorContains(manager.Load(ctx), "shrank from 4 to 1 rules")
+		is.True(manager.Blocked("b.needle.local"))
+
+		list.Store("<html>\n<body>\na.needle.local\n")
+		is.ErrorContains(manager.Load(ctx), "2 of 3 lines failed to parse")
+		is.True(manager.Blocked("b.needle.local"))
+		is.NotEmpty(manager.Statuses()[0].Error)
+	})
+
+	t.Run("Rollback", func(_ *testing.T) {
+		failed := manager.Statuses()[0]
+
+		is.NoError(manager.Rollback(ctx))
+		is.True(manager.Blocked("d.needle.local"))
+		is.False(manager.Blocked("x.e.needle.local"))
+		is.Equal(loaded, manager.Statuses()[0])
+
+		diff, ok := manager.LastDiff()
+		is.True(ok)
+		is.True(diff.Rollback)
+		is.Equal([]string{"d.needle.local"}, diff.Added)
+
+		is.NoError(manager.Rollback(ctx))
+		is.False(manager.Blocked("d.needle.local"))
+		is.True(manager.Blocked("x.e.needle.local"))
+		is.Equal(failed, manager.Statuses()[0])
+	})
+}
@@ -0,0 +1,572 @@
+// Package blocklist compiles blocked domains from hosts, domain and
+// Adblock Plus lists.
+package blocklist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.pixelfactory.io/pkg/observability/log"
+	"go.pixelfactory.io/pkg/observability/log/fields"
+)
+
+// allowListName names the list compiled from WithAllow rules.
+const allowListName = "allowlist"
+
+// maxDiffDomains caps the domains kept by a Diff.
+const maxDiffDomains = 1000
+
+// ErrNoRollback is returned by Rollback when no previous version is kept.
+var ErrNoRollback = errors.New("no previous blocklists")
+
+// Fetcher opens the list at location, a file path or an URL.
+type Fetcher interface {
+	Fetch(ctx context.Context, location string) (io.ReadCloser, error)
+}
+
+// ChangeFunc is called once the blocked domains changed.
+type ChangeFunc func(ctx context.Context) error
+
+// Source is a list to load.
+type Source struct {
+	Name     string
+	Location string
+}
+
+// ParseSource parses a source written name=location, or location alone
+// which is then named after its file name.
+func ParseSource(s string) (Source, error) {
+	name, location, ok := strings.Cut(s, "=")
+	if !ok {
+		location = s
+		name = strings.TrimSuffix(path.Base(location), path.Ext(location))
+		if u, err := url.Parse(location); err == nil && u.Host != "" {
+			name = u.Host
+		}
+	}
+
+	name, location = strings.TrimSpace(name), strings.TrimSpace(location)
+	if name == "" || name == "." || name == "/" || location == "" || name == allowListName {
+		return Source{}, fmt.Errorf("blocklist.ParseSource: invalid source %q, expected name=location", s)
+	}
+	return Source{Name: name, Location: location}, nil
+}
+
+// Status describes a loaded source.
+type Status struct {
+	Name     string
+	Location string
+	// Entries is the number of block rules after deduplication.
+	Entries   int
+	UpdatedAt time.Time
+	// Error is set when the last load failed, the previous rules are kept.
+	Error string
+}
+
+// Diff describes the domains added and removed by a blocklists update.
+type Diff struct {
+	Time time.Time
+	// Added and Removed hold at most 1000 domains each, sorted.
+	Added        []string
+	Removed      []string
+	AddedCount   int
+	RemovedCount int
+	// Rollback is set when the update restored the previous version.
+	Rollback bool
+}
+
+// version is a compiled Set with the lists it was compiled from and their
+// statuses.
+type version struct {
+	lists    map[string]List
+	statuses map[string]Status
+	set      *Set
+}
+
+// Manager loads sources into a Set and keeps domains allowed for a while.
+type Manager struct {
+	logger    log.Logger
+	fetcher   Fetcher
+	sources   []Source
+	static    []List
+	allow     []Rule
+	onChange  ChangeFunc
+	now       func() time.Time
+	tick      time.Duration
+	refresh   time.Duration
+	maxShrink float64
+
+	set atomic.Pointer[Set]
+
+	// loadMu serializes loads and change notifications
+	loadMu   sync.Mutex
+	lists    map[string]List
+	statuses map[string]Status
+	previous *version
+	diff     *Diff
+
+	mu         sync.Mutex
+	exceptions map[string]time.Time
+
+	started  atomic.Bool
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// Option type.
+type Option func(*Manager)
+
+// WithLogger set manager logger.
+func WithLogger(l log.Logger) Option {
+	return func(m *Manager) {
+		m.logger = l
+	}
+}
+
+// WithFetcher set how source locations are opened.
+func WithFetcher(f Fetcher) Option {
+	return func(m *Manager) {
+		m.fetcher = f
+	}
+}
+
+// WithSources set the lists to load, at most MaxLists-1.
+func WithSources(sources ...Source) Option {
+	return func(m *Manager) {
+		m.sources = sources
+	}
+}
+
+// WithLists set lists compiled with the sources on every load, such as the
+// domains of a category. Sources and lists are at most MaxLists-1.
+func WithLists(lists ...List) Option {
+	return func(m *Manager) {
+		m.static = lists
+	}
+}
+
+// WithAllow excepts names matching rules from every list.
+func WithAllow(rules ...Rule) Option {
+	return func(m *Manager) {
+		m.allow = rules
+	}
+}
+
+// WithOnChange set the function called once the blocked domains changed.
+func WithOnChange(fn ChangeFunc) Option {
+	return func(m *Manager) {
+		m.onChange = fn
+	}
+}
+
+// WithClock set the time source of temporary allows.
+func WithClock(now func() time.Time) Option {
+	return func(m *Manager) {
+		m.now = now
+	}
+}
+
+// WithRefresh set the interval between loads run by Serve, zero disables
+// them.
+func WithRefresh(d time.Duration) Option {
+	return func(m *Manager) {
+		m.refresh = d
+	}
+}
+
+// WithMaxShrink set the ratio of rules a source may lose between two loads,
+// such as 0.5, larger losses are rejected. Zero disables the check.
+func WithMaxShrink(ratio float64) Option {
+	return func(m *Manager) {
+		m.maxShrink = ratio
+	}
+}
+
+// New returns a Manager, nothing is blocked until Load.
+func New(opts ...Option) (*Manager, error) {
+	m := &Manager{
+		now:        time.Now,
+		tick:       time.Second,
+		lists:      map[string]List{},
+		statuses:   map[string]Status{},
+		exceptions: map[string]time.Time{},
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if m.logger == nil {
+		m.logger = log.New()
+		m.logger.Info("Using default logger")
+	}
+
+	if m.fetcher == nil {
+		return nil, errors.New("blocklist.New: no fetcher")
+	}
+
+	if m.maxShrink < 0 || m.maxShrink >= 1 {
+		return nil, fmt.Errorf("blocklist.New: max shrink %v out of [0, 1)", m.maxShrink)
+	}
+
+	if len(m.sources)+len(m.static) >= MaxLists {
+		return nil, fmt.Errorf("blocklist.New: at most %d sources and lists", MaxLists-1)
+	}
+
+	names := map[string]bool{}
+	for _, source := range m.sources {
+		if names[source.Name] {
+			return nil, fmt.Errorf("blocklist.New: duplicate source %q", source.Name)
+		}
+		names[source.Name] = true
+	}
+	for _, list := range m.static {
+		if names[list.Name] || list.Name == allowListName {
+			return nil, fmt.Errorf("blocklist.New: duplicate list %q", list.Name)
+		}
+		names[list.Name] = true
+	}
+
+	for i := range m.allow {
+		m.allow[i].Allow = true
+	}
+
+	m.set.Store(Compile())
+	return m, nil
+}
+
+// Load fetches every source and swaps the compiled Set. Sources failing to
+// load or to validate keep their previous rules, their errors are returned
+// together. The replaced version is kept for Rollback when domains changed.
+func (m *Manager) Load(ctx context.Context) error {
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
+
+	prev := version{lists: maps.Clone(m.lists), statuses: maps.Clone(m.statuses), set: m.set.Load()}
+
+	var errs []error
+	for _, source := range m.sources {
+		rules, skipped, err := m.fetch(ctx, source)
+		if err == nil {
+			err = m.validate(source, rules, skipped)
+		}
+		status := m.statuses[source.Name]
+		status.Name, status.Location = source.Name, source.Location
+		if err != nil {
+			m.logger.Error("Unable to load blocklist", fields.String("blocklist", source.Name), fields.Error(err))
+			status.Error = err.Error()
+			m.statuses[source.Name] = status
+			errs = append(errs, err)
+			continue
+		}
+
+		m.logger.Debug(
+			"Blocklist loaded",
+			fields.String("blocklist", source.Name),
+			fields.Int("rules", len(rules)),
+			fields.Int("skipped", skipped),
+		)
+		m.lists[source.Name] = List{Name: source.Name, Rules: rules}
+		status.UpdatedAt = m.now()
+		status.Error = ""
+		m.statuses[source.Name] = status
+	}
+
+	lists := make([]List, 0, len(m.sources)+len(m.static)+1)
+	for _, source := range m.sources {
+		if list, ok := m.lists[source.Name]; ok {
+			lists = append(lists, list)
+		}
+	}
+	lists = append(lists, m.static...)
+	lists = append(lists, List{Name: allowListName, Rules: m.allow})
+
+	set := Compile(lists...)
+	m.set.Store(set)
+	m.logger.Info("Blocklists compiled", fields.Int("domains", set.Len()))
+
+	diff := m.record(prev.set, set, false)
+	if len(prev.lists) > 0 && diff.AddedCount+diff.RemovedCount > 0 {
+		m.previous = &prev
+	}
+
+	if err := m.notify(ctx); err != nil {
+		m.logger.Error("Unable to apply blocklists", fields.Error(err))
+		errs = append(errs, err)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("blocklist.Manager.Load: %w", err)
+	}
+	return nil
+}
+
+func (m *Manager) fetch(ctx context.Context, source Source) ([]Rule, int, error) {
+	r, err := m.fetcher.Fetch(ctx, source.Location)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", source.Name, err)
+	}
+	defer r.Close()
+
+	rules, skipped, err := Parse(r)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", source.Name, err)
+	}
+	return rules, skipped, nil
+}
+
+// validate rejects lists that mostly failed to parse or that lost more than
+// maxShrink of their rules since the last load, loadMu must be held.
+func (m *Manager) validate(source Source, rules []Rule, skipped int) error {
+	if skipped > len(rules) {
+		return fmt.Errorf("%s: %d of %d lines failed to parse", source.Name, skipped, skipped+len(rules))
+	}
+
+	prev, ok := m.lists[source.Name]
+	if ok && m.maxShrink > 0 && float64(len(rules)) < float64(len(prev.Rules))*(1-m.maxShrink) {
+		return fmt.Errorf("%s: shrank from %d to %d rules", source.Name, len(prev.Rules), len(rules))
+	}
+	return nil
+}
+
+// record logs the diff between prev and next, and keeps it when domains
+// changed. loadMu must be held.
+func (m *Manager) record(prev, next *Set, rollback bool) Diff {
+	added, removed := Compare(prev, next)
+	// clone the kept domains so the diff does not pin every domain of a
+	// large change
+	diff := Diff{
+		Time:         m.now(),
+		Added:        slices.Clone(added[:min(len(added), maxDiffDomains)]),
+		Removed:      slices.Clone(removed[:min(len(removed), maxDiffDomains)]),
+		AddedCount:   len(added),
+		RemovedCount: len(removed),
+		Rollback:     rollback,
+	}
+	if diff.AddedCount+diff.RemovedCount > 0 {
+		m.diff = &diff
+	}
+
+	m.logger.Info(
+		"Blocklists updated",
+		fields.Int("added", diff.AddedCount),
+		fields.Int("removed", diff.RemovedCount),
+	)
+	for _, domain := range diff.Added {
+		m.logger.Debug("Blocklist domain added", fields.String("domain", domain))
+	}
+	for _, domain := range diff.Removed {
+		m.logger.Debug("Blocklist domain removed", fields.String("domain", domain))
+	}
+	return diff
+}
+
+// LastDiff returns the diff of the last load or rollback changing domains,
+// ok is false until domains changed.
+func (m *Manager) LastDiff() (Diff, bool) {
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
+
+	if m.diff == nil {
+		return Diff{}, false
+	}
+	return *m.diff, true
+}
+
+// Rollback restores the version replaced by the last load that changed
+// domains and its statuses, the current one is kept so a second Rollback
+// undoes the first.
+// The next load fetches sources again.
+func (m *Manager) Rollback(ctx context.Context) error {
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
+
+	if m.previous == nil {
+		return fmt.Errorf("blocklist.Manager.Rollback: %w", ErrNoRollback)
+	}
+
+	current, restored := version{lists: m.lists, statuses: m.statuses, set: m.set.Load()}, m.previous
+	m.lists, m.statuses, m.previous = restored.lists, restored.statuses, &current
+	m.set.Store(restored.set)
+	m.record(current.set, restored.set, true)
+
+	if err := m.notify(ctx); err != nil {
+		return fmt.Errorf("blocklist.Manager.Rollback: %w", err)
+	}
+	return nil
+}
+
+// notify calls the ChangeFunc, loadMu must be held.
+func (m *Manager) notify(ctx context.Context) error {
+	if m.onChange == nil {
+		return nil
+	}
+	return m.onChange(ctx)
+}
+
+// Set returns the compiled Set, without temporary allows.
+func (m *Manager) Set() *Set {
+	return m.set.Load()
+}
+
+// Match returns the names of the lists blocking name, ok is false when no
+// list blocks it or when it is allowed.
+func (m *Manager) Match(name string) ([]string, bool) {
+	if m.Allowed(name) {
+		return nil, false
+	}
+	return m.set.Load().Match(name)
+}
+
+// Blocked reports whether name is blocked.
+func (m *Manager) Blocked(name string) bool {
+	return !m.Allowed(name) && m.set.Load().Blocked(name)
+}
+
+// Allowed reports whether name is temporarily allowed.
+func (m *Manager) Allowed(name string) bool {
+	name = normalize(name)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	until, ok := m.exceptions[name]
+	return ok && m.now().Before(until)
+}
+
+// Allow excepts name from every list for d.
+func (m *Manager) Allow(ctx context.Context, name string, d time.Duration) error {
+	name = normalize(name)
+	if !domainRegexp.MatchString(name) {
+		return fmt.Errorf("blocklist.Manager.Allow: invalid name %q", name)
+	}
+
+	m.mu.Lock()
+	m.exceptions[name] = m.now().Add(d)
+	m.mu.Unlock()
+
+	m.logger.Info("Temporarily allowed domain", fields.String("domain", name), fields.String("duration", d.String()))
+
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
+	if err := m.notify(ctx); err != nil {
+		return fmt.Errorf("blocklist.Manager.Allow: %w", err)
+	}
+	return nil
+}
+
+// Statuses returns the status of each source, in configuration order, then
+// of each list without location.
+func (m *Manager) Statuses() []Status {
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
+
+	set := m.set.Load()
+	statuses := make([]Status, 0, len(m.sources)+len(m.static))
+	for _, source := range m.sources {
+		status, ok := m.statuses[source.Name]
+		if !ok {
+			status = Status{Name: source.Name, Location: source.Location}
+		}
+		status.Entries = set.Count(source.Name)
+		statuses = append(statuses, status)
+	}
+	for _, list := range m.static {
+		statuses = append(statuses, Status{Name: list.Name, Entries: set.Count(list.Name)})
+	}
+	return statuses
+}
+
+// Serve expires temporary allows and loads sources every refresh interval
+// until Shutdown.
+func (m *Manager) Serve() error {
+	m.started.Store(true)
+	defer close(m.done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-m.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(m.tick)
+	defer ticker.Stop()
+
+	var refresh <-chan time.Time
+	if m.refresh > 0 {
+		refreshTicker := time.NewTicker(m.refresh)
+		defer refreshTicker.Stop()
+		refresh = refreshTicker.C
+	}
+
+	for {
+		select {
+		case <-m.stop:
+			return nil
+		case <-ticker.C:
+			m.expire()
+		case <-refresh:
+			// Errors are logged by Load, failed sources keep their rules.
+			_ = m.Load(ctx)
+		}
+	}
+}
+
+// Shutdown stops Serve, it returns at once when Serve was not started.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.stopOnce.Do(func() { close(m.stop) })
+	if !m.started.Load() {
+		return nil
+	}
+
+	select {
+	case <-m.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// expire forgets expired allows and notifies the change.
+func (m *Manager) expire() {
+	now := m.now()
+	expired := false
+
+	m.mu.Lock()
+	for name, until := range m.exceptions {
+		if now.Before(until) {
+			continue
+		}
+		delete(m.exceptions, name)
+		expired = true
+		m.logger.Info("Temporary allow expired", fields.String("domain", name))
+	}
+	m.mu.Unlock()
+
+	if !expired {
+		return
+	}
+
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
+	if err := m.notify(context.Background()); err != nil {
+		m.logger.Error("Unable to apply expired allows", fields.Error(err))
+	}
+}
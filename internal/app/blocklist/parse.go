@@ -0,0 +1,310 @@
+package blocklist
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Rule is a domain or a pattern parsed from a list.
+type Rule struct {
+	// Domain is a domain name, a wildcard pattern when Wildcard is set, or
+	// a regular expression when Regexp is set.
+	Domain string
+	// Subdomains also matches every subdomain of Domain.
+	Subdomains bool
+	// Wildcard makes Domain a pattern where * matches any characters,
+	// dots included.
+	Wildcard bool
+	// Regexp makes Domain a regular expression, matched case-insensitively
+	// against names without trailing dot.
+	Regexp bool
+	// Allow excepts the matched names from every list.
+	Allow bool
+}
+
+// String returns the rule in the domain list syntax: *.domain for rules
+// with subdomains, /regexp/ for regular expressions.
+func (r Rule) String() string {
+	switch {
+	case r.Regexp:
+		return "/" + r.Domain + "/"
+	case r.Subdomains:
+		return "*." + r.Domain
+	default:
+		return r.Domain
+	}
+}
+
+// domainRegexp matches a lowercase domain name without trailing dot.
+var domainRegexp = regexp.MustCompile(`^([a-z0-9_]([a-z0-9_-]{0,61}[a-z0-9_])?\.)*[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// ignoredHosts are the local names found in most hosts files.
+var ignoredHosts = map[string]bool{
+	"localhost":             true,
+	"localhost.localdomain": true,
+	"local":                 true,
+	"broadcasthost":         true,
+	"ip6-localhost":         true,
+	"ip6-loopback":          true,
+	"ip6-localnet":          true,
+	"ip6-mcastprefix":       true,
+	"ip6-allnodes":          true,
+	"ip6-allrouters":        true,
+	"ip6-allhosts":          true,
+	"0.0.0.0":               true,
+}
+
+// Parse reads rules from r, one per line, in any of these formats:
+//
+//	0.0.0.0 ads.example.com   hosts, the name only
+//	ads.example.com           plain domain, the name only
+//	*.ads.example.com         wildcard domain, the name and its subdomains
+//	ad*.example.com           wildcard pattern, * matches any characters
+//	/^ad[0-9]+\./             regular expression
+//	||ads.example.com^        Adblock Plus, the name and its subdomains
+//	@@||ads.example.com^      Adblock Plus exception, also @@/regexp/
+//
+// Comments and element hiding rules are ignored. Adblock Plus rules with
+// options or paths, and invalid names or regular expressions are skipped
+// and counted. Adblock Plus lists, with an [Adblock Plus] header or element
+// hiding rules, have no regular expressions: /ads/ is a path there.
+func Parse(r io.Reader) ([]Rule, int, error) {
+	var rules []Rule
+	skipped := 0
+	adblock := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(strings.ToLower(line), "[adblock") {
+			adblock = true
+		}
+		if line == "" || line[0] == '#' || line[0] == '!' || line[0] == '[' {
+			continue
+		}
+		if elementHiding(line) {
+			adblock = true
+			continue
+		}
+
+		parsed, ok := parseLine(line)
+		if !ok {
+			skipped++
+			continue
+		}
+		rules = append(rules, parsed...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, skipped, errors.Wrap(err, "blocklist.Parse")
+	}
+
+	if adblock {
+		kept := rules[:0]
+		for _, rule := range rules {
+			if rule.Regexp {
+				skipped++
+				continue
+			}
+			kept = append(kept, rule)
+		}
+		rules = kept
+	}
+
+	return rules, skipped, nil
+}
+
+// elementHidingSeparators separate the domains of Adblock Plus element
+// hiding and snippet rules, such as example.com##.ad-banner, from their
+// selector.
+var elementHidingSeparators = []string{"##", "#@#", "#?#", "#@?#", "#$#", "#@$#", "#%#", "#@%#"}
+
+// elementHiding reports whether line is an element hiding or snippet rule,
+// which does not block names.
+func elementHiding(line string) bool {
+	for _, sep := range elementHidingSeparators {
+		if strings.Contains(line, sep) {
+			return true
+		}
+	}
+	return false
+}
+
+// commentIndex returns the index of the # starting a comment in line, at
+// the start of the line or after whitespace, or -1.
+func commentIndex(line string) int {
+	for i := range len(line) {
+		if line[i] == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			return i
+		}
+	}
+	return -1
+}
+
+// ParseRule parses a single rule, such as an allow-list entry.
+func ParseRule(s string) (Rule, error) {
+	rules, ok := parseLine(strings.TrimSpace(s))
+	if !ok || len(rules) != 1 {
+		return Rule{}, errors.Errorf("blocklist.ParseRule: invalid rule %q", s)
+	}
+	return rules[0], nil
+}
+
+func parseLine(line string) ([]Rule, bool) {
+	if strings.HasPrefix(line, "||") || strings.HasPrefix(line, "@@||") {
+		rule, ok := parseAdblock(line)
+		if !ok {
+			return nil, false
+		}
+		return []Rule{rule}, true
+	}
+
+	if strings.HasPrefix(line, "/") || strings.HasPrefix(line, "@@/") {
+		rule, ok := parseRegexp(line)
+		if !ok {
+			return nil, false
+		}
+		return []Rule{rule}, true
+	}
+
+	if i := commentIndex(line); i >= 0 {
+		line = line[:i]
+	}
+
+	f := strings.Fields(line)
+	switch {
+	case len(f) == 0:
+		return nil, true
+	case len(f) == 1:
+		rule, ok := parseDomain(f[0])
+		if !ok {
+			return nil, false
+		}
+		return []Rule{rule}, true
+	case net.ParseIP(f[0]) == nil:
+		return nil, false
+	}
+
+	// hosts line, local names are not blocked
+	rules := make([]Rule, 0, len(f)-1)
+	for _, host := range f[1:] {
+		host = normalize(host)
+		if ignoredHosts[host] {
+			continue
+		}
+		if !domainRegexp.MatchString(host) {
+			return nil, false
+		}
+		rules = append(rules, Rule{Domain: host})
+	}
+	return rules, true
+}
+
+// parseAdblock parses the domain anchors of the Adblock Plus syntax.
+func parseAdblock(line string) (Rule, bool) {
+	var rule Rule
+	if strings.HasPrefix(line, "@@") {
+		rule.Allow = true
+		line = line[2:]
+	}
+
+	line = strings.TrimPrefix(line, "||")
+	line, ok := strings.CutSuffix(line, "^")
+	if !ok {
+		line, ok = strings.CutSuffix(line, "^|")
+	}
+	if !ok {
+		return Rule{}, false
+	}
+
+	rule.Domain = normalize(line)
+	rule.Subdomains = true
+	rule.Wildcard = strings.Contains(rule.Domain, "*")
+	return rule, validDomain(rule)
+}
+
+// parseRegexp parses /regexp/ and @@/regexp/ rules.
+func parseRegexp(line string) (Rule, bool) {
+	rule := Rule{Regexp: true}
+	if strings.HasPrefix(line, "@@") {
+		rule.Allow = true
+		line = line[2:]
+	}
+
+	if len(line) < 3 || !strings.HasSuffix(line, "/") {
+		return Rule{}, false
+	}
+
+	rule.Domain = line[1 : len(line)-1]
+	_, err := compileRegexp(rule.Domain)
+	return rule, err == nil
+}
+
+func parseDomain(s string) (Rule, bool) {
+	var rule Rule
+	if strings.HasPrefix(s, "*.") {
+		rule.Subdomains = true
+		s = s[2:]
+	}
+
+	rule.Domain = normalize(s)
+	rule.Wildcard = strings.Contains(rule.Domain, "*")
+	return rule, validDomain(rule) && net.ParseIP(rule.Domain) == nil
+}
+
+// validDomain checks the domain of rule, a * of wildcard rules standing for
+// any characters. Wildcard rules need at least two labels, so that a rule
+// such as * does not block every name.
+func validDomain(rule Rule) bool {
+	domain := rule.Domain
+	if rule.Wildcard {
+		domain = strings.ReplaceAll(domain, "*", "x")
+		if !strings.Contains(domain, ".") {
+			return false
+		}
+	}
+	return domainRegexp.MatchString(domain)
+}
+
+// compileRegexp compiles the regular expression of a rule.
+func compileRegexp(expr string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + expr)
+}
+
+// wildcardMatch reports whether name matches pattern, where * matches any
+// characters.
+func wildcardMatch(pattern, name string) bool {
+	// backtrack to the last * only, matching it one more character
+	p, n := 0, 0
+	star, next := -1, 0
+	for n < len(name) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			star, next = p, n
+			p++
+		case p < len(pattern) && pattern[p] == name[n]:
+			p++
+			n++
+		case star >= 0:
+			next++
+			p, n = star+1, next
+		default:
+			return false
+		}
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// normalize lowercases name and removes its trailing dot.
+func normalize(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
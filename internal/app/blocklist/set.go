@@ -0,0 +1,488 @@
+package blocklist
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"strings"
+)
+
+// MaxLists is the number of lists a Set can hold.
+const MaxLists = 64
+
+// literalKey is the length of the keys indexing patterns by their literal.
+const literalKey = 3
+
+// List is a named list of rules.
+type List struct {
+	Name  string
+	Rules []Rule
+}
+
+// Set is a compiled, immutable set of blocked domains and patterns, allow
+// rules of any list take precedence.
+//
+// Domains are stored in a trie of labels, from the top-level domain down,
+// each node holding the lists blocking it as a bitmask: a lookup costs one
+// map access per label of the name, whatever the number of domains. The
+// edges of every node share a single map, which takes far less memory than
+// a map per node.
+// Wildcard patterns are attached to the node of the domain they end with,
+// such as example.com for ad*.example.com, and only run for names below it.
+// Other patterns are indexed by a few bytes of a literal every name they
+// match contains, such as "track" for ^track(er)?[0-9]*\., and only
+// run for names containing it: a lookup costs a map access per byte of the
+// name, whatever the number of patterns.
+type Set struct {
+	lists  []string
+	counts []int
+	size   int
+
+	// nodes are indexed by edges, the root is the first one
+	nodes []node
+	edges map[edge]uint32
+	// anchored holds the patterns of the nodes with patterns set
+	anchored map[uint32][]*pattern
+	// indexed holds the patterns which are not anchored to a domain by
+	// literalKey bytes of their literal, patterns the others
+	indexed  map[string][]*pattern
+	patterns []*pattern
+	byRule   map[Rule]*pattern
+}
+
+// edge leads from a node to its child.
+type edge struct {
+	parent uint32
+	label  string
+}
+
+// node is a label of the trie.
+type node struct {
+	exact  uint64
+	suffix uint64
+	flags  nodeFlags
+}
+
+// nodeFlags mark allowed nodes and nodes with patterns.
+type nodeFlags uint8
+
+const (
+	allowExact nodeFlags = 1 << iota
+	allowSuffix
+	hasPatterns
+)
+
+// pattern is a wildcard or a compiled regular expression rule.
+type pattern struct {
+	rule Rule
+	re   *regexp.Regexp
+	mask uint64
+	// literal is contained in every name matched
+	literal string
+}
+
+// match reports whether the pattern matches name.
+func (p *pattern) match(name string) bool {
+	if !strings.Contains(name, p.literal) {
+		return false
+	}
+	if p.re != nil {
+		return p.re.MatchString(name)
+	}
+	if !p.rule.Subdomains {
+		return wildcardMatch(p.rule.Domain, name)
+	}
+
+	for {
+		if wildcardMatch(p.rule.Domain, name) {
+			return true
+		}
+		_, rest, found := strings.Cut(name, ".")
+		if !found {
+			return false
+		}
+		name = rest
+	}
+}
+
+// Compile deduplicates the rules of lists into a Set, lists after the
+// first MaxLists are ignored.
+func Compile(lists ...List) *Set {
+	if len(lists) > MaxLists {
+		lists = lists[:MaxLists]
+	}
+
+	// most rules add a single node and some their parents, size for them
+	// to avoid growing
+	size := 1
+	for _, list := range lists {
+		size += len(list.Rules)
+	}
+	size += size / 4
+
+	s := &Set{
+		lists:    make([]string, len(lists)),
+		counts:   make([]int, len(lists)),
+		nodes:    make([]node, 1, size),
+		edges:    make(map[edge]uint32, size),
+		anchored: map[uint32][]*pattern{},
+		indexed:  map[string][]*pattern{},
+		byRule:   map[Rule]*pattern{},
+	}
+
+	for i, list := range lists {
+		s.lists[i] = list.Name
+		for _, rule := range list.Rules {
+			s.add(rule, i)
+		}
+	}
+
+	// the Set is immutable, drop the room left by the last append
+	if cap(s.nodes) > len(s.nodes)+len(s.nodes)/8 {
+		s.nodes = slices.Clone(s.nodes)
+	}
+
+	return s
+}
+
+// add compiles rule of the list at index i.
+func (s *Set) add(rule Rule, i int) {
+	if rule.Wildcard || rule.Regexp {
+		s.addPattern(rule, i)
+		return
+	}
+
+	n := &s.nodes[s.insert(rule.Domain)]
+	switch {
+	case rule.Allow && rule.Subdomains:
+		n.flags |= allowSuffix
+	case rule.Allow:
+		n.flags |= allowExact
+	case rule.Subdomains:
+		s.count(&n.suffix, i)
+	default:
+		s.count(&n.exact, i)
+	}
+}
+
+func (s *Set) addPattern(rule Rule, i int) {
+	p, ok := s.byRule[rule]
+	if !ok {
+		p = &pattern{rule: rule, literal: patternLiteral(rule)}
+		if rule.Regexp {
+			re, err := compileRegexp(rule.Domain)
+			if err != nil {
+				return
+			}
+			p.re = re
+		}
+		s.byRule[rule] = p
+
+		switch parent := wildcardParent(rule); {
+		case parent != "":
+			n := s.insert(parent)
+			s.nodes[n].flags |= hasPatterns
+			s.anchored[n] = append(s.anchored[n], p)
+		case len(p.literal) >= literalKey:
+			key := s.literalKey(p.literal)
+			s.indexed[key] = append(s.indexed[key], p)
+		default:
+			s.patterns = append(s.patterns, p)
+		}
+	}
+
+	if !rule.Allow {
+		s.count(&p.mask, i)
+	}
+}
+
+// literalKey returns the key of literal indexing the fewest patterns, so
+// that patterns sharing a prefix such as ads are spread over keys.
+func (s *Set) literalKey(literal string) string {
+	key := literal[:literalKey]
+	for i := 1; i+literalKey <= len(literal); i++ {
+		if k := literal[i : i+literalKey]; len(s.indexed[k]) < len(s.indexed[key]) {
+			key = k
+		}
+	}
+	return key
+}
+
+// count adds the list at index i to mask, counting the rule once per list.
+func (s *Set) count(mask *uint64, i int) {
+	bit := uint64(1) << i
+	if *mask == 0 {
+		s.size++
+	}
+	if *mask&bit == 0 {
+		s.counts[i]++
+	}
+	*mask |= bit
+}
+
+// insert returns the node of domain, adding the missing labels.
+func (s *Set) insert(domain string) uint32 {
+	var n uint32
+	for end := len(domain); end > 0; {
+		i := strings.LastIndexByte(domain[:end], '.')
+		e := edge{parent: n, label: domain[i+1 : end]}
+
+		child, ok := s.edges[e]
+		if !ok {
+			child = uint32(len(s.nodes))
+			s.nodes = append(s.nodes, node{})
+			s.edges[e] = child
+		}
+		n, end = child, i
+	}
+	return n
+}
+
+// lookup returns the node of domain, ok is false when missing.
+func (s *Set) lookup(domain string) (*node, bool) {
+	var n uint32
+	for end := len(domain); end > 0; {
+		i := strings.LastIndexByte(domain[:end], '.')
+		child, ok := s.edges[edge{parent: n, label: domain[i+1 : end]}]
+		if !ok {
+			return nil, false
+		}
+		n, end = child, i
+	}
+	return &s.nodes[n], true
+}
+
+// patternLiteral returns the longest string found in every name matched by
+// a wildcard or regular expression rule, empty when there is none.
+func patternLiteral(rule Rule) string {
+	if !rule.Regexp {
+		var literal string
+		for _, part := range strings.Split(rule.Domain, "*") {
+			if len(part) > len(literal) {
+				literal = part
+			}
+		}
+		return literal
+	}
+
+	re, err := syntax.Parse("(?i)"+rule.Domain, syntax.Perl)
+	if err != nil {
+		return ""
+	}
+	// names are lowercase, literals are matched case-insensitively
+	return strings.ToLower(requiredLiteral(re.Simplify()))
+}
+
+// requiredLiteral returns the longest literal found in every match of re.
+func requiredLiteral(re *syntax.Regexp) string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return string(re.Rune)
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiteral(re.Sub[0])
+	case syntax.OpConcat:
+		var literal string
+		for _, sub := range re.Sub {
+			if l := requiredLiteral(sub); len(l) > len(literal) {
+				literal = l
+			}
+		}
+		return literal
+	default:
+		return ""
+	}
+}
+
+// wildcardParent returns the domain every name matched by a wildcard rule
+// ends with, empty when there is none.
+func wildcardParent(rule Rule) string {
+	if !rule.Wildcard {
+		return ""
+	}
+
+	tail := rule.Domain[strings.LastIndexByte(rule.Domain, '*')+1:]
+	_, parent, _ := strings.Cut(tail, ".")
+	return parent
+}
+
+// Match returns the names of the lists blocking name, ok is false when no
+// list blocks it or when it is allowed.
+func (s *Set) Match(name string) ([]string, bool) {
+	mask := s.match(normalize(name))
+	if mask == 0 {
+		return nil, false
+	}
+
+	var lists []string
+	for i, list := range s.lists {
+		if mask&(1<<i) != 0 {
+			lists = append(lists, list)
+		}
+	}
+	return lists, true
+}
+
+// Blocked reports whether name is blocked.
+func (s *Set) Blocked(name string) bool {
+	return s.match(normalize(name)) != 0
+}
+
+func (s *Set) match(name string) uint64 {
+	var mask uint64
+
+	var id uint32
+	for end := len(name); end > 0; {
+		i := strings.LastIndexByte(name[:end], '.')
+		child, ok := s.edges[edge{parent: id, label: name[i+1 : end]}]
+		if !ok {
+			break
+		}
+
+		id, end = child, i
+		n := &s.nodes[id]
+		if n.flags&allowSuffix != 0 || (end < 0 && n.flags&allowExact != 0) {
+			return 0
+		}
+		mask |= n.suffix
+		if end < 0 {
+			mask |= n.exact
+		}
+
+		if n.flags&hasPatterns != 0 {
+			m, allowed := matchPatterns(s.anchored[id], name)
+			if allowed {
+				return 0
+			}
+			mask |= m
+		}
+	}
+
+	// a pattern is matched again for each occurrence of its key
+	for i := 0; len(s.indexed) > 0 && i+literalKey <= len(name); i++ {
+		m, allowed := matchPatterns(s.indexed[name[i:i+literalKey]], name)
+		if allowed {
+			return 0
+		}
+		mask |= m
+	}
+
+	m, allowed := matchPatterns(s.patterns, name)
+	if allowed {
+		return 0
+	}
+	return mask | m
+}
+
+// matchPatterns returns the lists of the patterns matching name, allowed is
+// set when an allow pattern matches.
+func matchPatterns(patterns []*pattern, name string) (mask uint64, allowed bool) {
+	for _, p := range patterns {
+		if !p.match(name) {
+			continue
+		}
+		if p.rule.Allow {
+			return 0, true
+		}
+		mask |= p.mask
+	}
+	return mask, false
+}
+
+// Len returns the number of block rules after deduplication.
+func (s *Set) Len() int {
+	return s.size
+}
+
+// Count returns the number of block rules of list after deduplication.
+func (s *Set) Count(list string) int {
+	for i, name := range s.lists {
+		if name == list {
+			return s.counts[i]
+		}
+	}
+	return 0
+}
+
+// Range calls fn with each block rule until fn returns false, in no
+// particular order. Domains also blocked with their subdomains are
+// reported once with subdomains set, allowed domains are skipped.
+func (s *Set) Range(fn func(rule Rule) bool) {
+	// domains are only stored as edges, rebuild them from the root down
+	parents := make([]edge, len(s.nodes))
+	for e, child := range s.edges {
+		parents[child] = e
+	}
+	domains := make([]string, len(s.nodes))
+	var domain func(id uint32) string
+	domain = func(id uint32) string {
+		if id == 0 || domains[id] != "" {
+			return domains[id]
+		}
+		e := parents[id]
+		domains[id] = e.label
+		if e.parent != 0 {
+			domains[id] += "." + domain(e.parent)
+		}
+		return domains[id]
+	}
+
+	for id := range s.nodes[1:] {
+		n, name := &s.nodes[id+1], domain(uint32(id+1))
+		switch {
+		case n.suffix != 0 && s.match(name) != 0:
+			if !fn(Rule{Domain: name, Subdomains: true}) {
+				return
+			}
+		case n.exact != 0 && s.match(name) != 0:
+			if !fn(Rule{Domain: name}) {
+				return
+			}
+		}
+	}
+
+	for rule, p := range s.byRule {
+		if p.mask != 0 && !fn(rule) {
+			return
+		}
+	}
+}
+
+// has reports whether the Set holds rule and does not allow its domain.
+func (s *Set) has(rule Rule) bool {
+	if rule.Wildcard || rule.Regexp {
+		p, ok := s.byRule[rule]
+		return ok && p.mask != 0
+	}
+
+	n, ok := s.lookup(rule.Domain)
+	if !ok {
+		return false
+	}
+
+	mask := n.exact
+	if rule.Subdomains {
+		mask = n.suffix
+	}
+	return mask != 0 && s.match(rule.Domain) != 0
+}
+
+// Compare returns the rules blocking in next and not in prev, and the rules
+// no longer blocking, sorted in the domain list syntax.
+func Compare(prev, next *Set) (added, removed []string) {
+	next.Range(func(rule Rule) bool {
+		if !prev.has(rule) {
+			added = append(added, rule.String())
+		}
+		return true
+	})
+	prev.Range(func(rule Rule) bool {
+		if !next.has(rule) {
+			removed = append(removed, rule.String())
+		}
+		return true
+	})
+
+	slices.Sort(added)
+	slices.Sort(removed)
+	return added, removed
+}
@@ -0,0 +1,113 @@
+package blocklist_test
+
+import (
+	"runtime"
+	"strconv"
+	"testing"
+
+	"go.pixelfactory.io/needle/internal/app/blocklist"
+)
+
+// domainCount is the size of the generated lists.
+const domainCount = 1_000_000
+
+// generate returns count rules such as ads123.tracker45.com, a fifth of
+// them blocking subdomains, and a few patterns.
+func generate(count int) []blocklist.Rule {
+	tlds := []string{"com", "net", "org", "io", "co.uk"}
+
+	rules := make([]blocklist.Rule, 0, count+3)
+	for i := range count {
+		rules = append(rules, blocklist.Rule{
+			Domain:     "ads" + strconv.Itoa(i) + ".tracker" + strconv.Itoa(i/8) + "." + tlds[i%len(tlds)],
+			Subdomains: i%5 == 0,
+		})
+	}
+	return append(rules,
+		blocklist.Rule{Domain: "pixel*.tracker1.com", Wildcard: true},
+		blocklist.Rule{Domain: "metrics.*", Wildcard: true},
+		blocklist.Rule{Domain: `^ad[0-9]+\.cdn\.`, Regexp: true},
+	)
+}
+
+func Benchmark_Compile(b *testing.B) {
+	rules := generate(domainCount)
+	b.ResetTimer()
+
+	// label strings are shared with the rules, only the trie is counted:
+	// about 120 bytes per domain, half of it for the edges map
+	var set *blocklist.Set
+	var before, after runtime.MemStats
+	for range b.N {
+		b.StopTimer()
+		set = nil
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		b.StartTimer()
+
+		set = blocklist.Compile(blocklist.List{Name: "ads", Rules: rules})
+
+		b.StopTimer()
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		b.StartTimer()
+	}
+
+	b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/domainCount, "B/domain")
+	runtime.KeepAlive(rules)
+	runtime.KeepAlive(set)
+}
+
+func Benchmark_BlockedPatterns(b *testing.B) {
+	// hundreds of regular expressions and wildcards which are not anchored
+	// to a domain, like regex lists
+	var rules []blocklist.Rule
+	for i := range 300 {
+		n := strconv.Itoa(i)
+		rules = append(rules,
+			blocklist.Rule{Domain: `^ad` + n + `[0-9]*\.`, Regexp: true},
+			blocklist.Rule{Domain: `track(er)?` + n + `\.(com|net)$`, Regexp: true},
+			blocklist.Rule{Domain: "metrics" + n + ".*", Wildcard: true},
+		)
+	}
+	set := blocklist.Compile(blocklist.List{Name: "patterns", Rules: rules})
+
+	for _, bm := range []struct {
+		name   string
+		domain string
+	}{
+		{"Regexp", "ad150.cdn.needle.local"},
+		{"Wildcard", "metrics299.needle.local"},
+		{"Miss", "www.needle.local"},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				set.Blocked(bm.domain)
+			}
+		})
+	}
+}
+
+func Benchmark_Blocked(b *testing.B) {
+	set := blocklist.Compile(blocklist.List{Name: "ads", Rules: generate(domainCount)})
+
+	for _, bm := range []struct {
+		name   string
+		domain string
+	}{
+		{"Exact", "ads123456.tracker15432.org"},
+		{"Subdomain", "a.b.ads500000.tracker62500.com"},
+		{"Wildcard", "pixel-eu.tracker1.com"},
+		{"Regexp", "ad42.cdn.needle.local"},
+		{"Miss", "www.needle.local"},
+		{"MissSameParent", "www.tracker15432.org"},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				set.Blocked(bm.domain)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+// Package category classifies domains by category, such as ads or
+// analytics, and by the company owning them, from local datasets.
+package category
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"go.pixelfactory.io/needle/internal/app/blocklist"
+)
+
+// Category of a domain.
+type Category string
+
+// Categories domains are classified in.
+const (
+	Ads       Category = "ads"
+	Analytics Category = "analytics"
+	Social    Category = "social"
+	Malware   Category = "malware"
+	Adult     Category = "adult"
+	Telemetry Category = "telemetry"
+)
+
+// All categories, in the order of their bits.
+var All = []Category{Ads, Analytics, Social, Malware, Adult, Telemetry}
+
+// disconnectCategories maps the categories of Disconnect services files,
+// the others only classify domains by company.
+var disconnectCategories = map[string]Category{
+	"Advertising":            Ads,
+	"Analytics":              Analytics,
+	"FingerprintingGeneral":  Analytics,
+	"FingerprintingInvasive": Analytics,
+	"Social":                 Social,
+	"Cryptomining":           Malware,
+}
+
+// Parse parses a category name.
+func Parse(s string) (Category, error) {
+	c := Category(strings.ToLower(strings.TrimSpace(s)))
+	if !slices.Contains(All, c) {
+		return "", fmt.Errorf("category.Parse: %q is not a category, one of %s", s, strings.Join(names(All), ", "))
+	}
+	return c, nil
+}
+
+func names(categories []Category) []string {
+	result := make([]string, len(categories))
+	for i, c := range categories {
+		result[i] = string(c)
+	}
+	return result
+}
+
+// categories is a set of categories, bit i is All[i].
+type categories uint8
+
+func (c categories) list() []Category {
+	var result []Category
+	for i, category := range All {
+		if c&(1<<i) != 0 {
+			result = append(result, category)
+		}
+	}
+	return result
+}
+
+func bit(c Category) categories {
+	return 1 << slices.Index(All, c)
+}
+
+// Tag classifies a domain.
+type Tag struct {
+	// Entity is the company owning the domain, empty when unknown.
+	Entity     string
+	Categories []Category
+}
+
+// entry is the classification of a domain of a dataset.
+type entry struct {
+	entity     string
+	categories categories
+}
+
+// Dataset classifies domains and their subdomains.
+type Dataset struct {
+	domains map[string]*entry
+}
+
+// New returns an empty Dataset.
+func New() *Dataset {
+	return &Dataset{domains: map[string]*entry{}}
+}
+
+// Open returns the Dataset of the files at paths, later files completing
+// and overriding earlier ones.
+func Open(paths ...string) (*Dataset, error) {
+	d := New()
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("category.Open: %w", err)
+		}
+		err = d.Read(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("category.Open: %s: %w", path, err)
+		}
+	}
+	return d, nil
+}
+
+// Read adds the domains of a Disconnect services file, of a Disconnect
+// entities file or of a text file of "domain categories entity" lines, such
+// as "graph.facebook.com social,analytics Meta". Categories are separated by
+// commas, "-" for none, and the entity is optional.
+func (d *Dataset) Read(r io.Reader) error {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.Peek(1)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case b[0] == '{':
+			return d.readJSON(br)
+		case bytes.ContainsAny(b, " \t\r\n"):
+			_, _ = br.ReadByte()
+		default:
+			return d.readText(br)
+		}
+	}
+}
+
+// readJSON reads a Disconnect services or entities file.
+func (d *Dataset) readJSON(r io.Reader) error {
+	var file struct {
+		// Categories are lists of {"Company": {"https://company/": ["domain"]}}
+		Categories map[string][]map[string]map[string]json.RawMessage `json:"categories"`
+		Entities   map[string]struct {
+			Properties []string `json:"properties"`
+			Resources  []string `json:"resources"`
+		} `json:"entities"`
+	}
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return err
+	}
+	if file.Categories == nil && file.Entities == nil {
+		return errors.New("neither a Disconnect services nor entities file")
+	}
+
+	for name, companies := range file.Categories {
+		category := disconnectCategories[name]
+		for _, company := range companies {
+			for entity, sites := range company {
+				for _, raw := range sites {
+					// sites also hold flags such as "performance": "true"
+					var domains []string
+					if json.Unmarshal(raw, &domains) != nil {
+						continue
+					}
+					for _, domain := range domains {
+						d.add(domain, entity, category)
+					}
+				}
+			}
+		}
+	}
+
+	for entity, e := range file.Entities {
+		for _, domain := range append(e.Properties, e.Resources...) {
+			d.add(domain, entity, "")
+		}
+	}
+	return nil
+}
+
+// readText reads "domain categories entity" lines.
+func (d *Dataset) readText(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		f := strings.Fields(text)
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) < 2 {
+			return fmt.Errorf("line %d: expected domain categories entity", line)
+		}
+
+		var cats []Category
+		if f[1] != "-" {
+			for _, s := range strings.Split(f[1], ",") {
+				c, err := Parse(s)
+				if err != nil {
+					return fmt.Errorf("line %d: %w", line, err)
+				}
+				cats = append(cats, c)
+			}
+		}
+
+		entity := strings.Join(f[2:], " ")
+		if len(cats) == 0 {
+			d.add(f[0], entity, "")
+		}
+		for _, c := range cats {
+			d.add(f[0], entity, c)
+		}
+	}
+	return scanner.Err()
+}
+
+// add classifies domain, invalid domains are skipped.
+func (d *Dataset) add(domain, entity string, category Category) {
+	rule, err := blocklist.ParseRule(domain)
+	if err != nil || rule.Wildcard || rule.Regexp || rule.Allow {
+		return
+	}
+
+	e, ok := d.domains[rule.Domain]
+	if !ok {
+		e = &entry{}
+		d.domains[rule.Domain] = e
+	}
+	if entity != "" {
+		e.entity = entity
+	}
+	if category != "" {
+		e.categories |= bit(category)
+	}
+}
+
+// Len returns the number of classified domains.
+func (d *Dataset) Len() int {
+	return len(d.domains)
+}
+
+// Lookup returns the classification of name, from the closest of name and
+// its parent domains with an entity and with categories. ok is false when
+// name is not classified.
+func (d *Dataset) Lookup(name string) (tag Tag, ok bool) {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+
+	var cats categories
+	for {
+		if e, found := d.domains[name]; found {
+			ok = true
+			if tag.Entity == "" {
+				tag.Entity = e.entity
+			}
+			if cats == 0 {
+				cats = e.categories
+			}
+			if tag.Entity != "" && cats != 0 {
+				break
+			}
+		}
+
+		_, parent, found := strings.Cut(name, ".")
+		if !found {
+			break
+		}
+		name = parent
+	}
+
+	tag.Categories = cats.list()
+	return tag, ok
+}
+
+// Entity returns the company owning name, empty when unknown.
+func (d *Dataset) Entity(name string) string {
+	tag, _ := d.Lookup(name)
+	return tag.Entity
+}
+
+// List returns the blocklist of the domains of category and their
+// subdomains, named after the category.
+func (d *Dataset) List(category Category) blocklist.List {
+	list := blocklist.List{Name: string(category)}
+	b := bit(category)
+	for domain, e := range d.domains {
+		if e.categories&b != 0 {
+			list.Rules = append(list.Rules, blocklist.Rule{Domain: domain, Subdomains: true})
+		}
+	}
+	slices.SortFunc(list.Rules, func(a, b blocklist.Rule) int {
+		return strings.Compare(a.Domain, b.Domain)
+	})
+	return list
+}
@@ -0,0 +1,111 @@
+package category_test
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/app/blocklist"
+	"go.pixelfactory.io/needle/internal/app/category"
+)
+
+const services = `{
+  "license": "GPL-3.0",
+  "categories": {
+    "Advertising": [
+      {"Google": {"http://www.google.com/": ["doubleclick.net", "googlesyndication.com"]}}
+    ],
+    "Social": [
+      {"Facebook": {"http://www.facebook.com/": ["facebook.net"], "performance": "true"}}
+    ],
+    "Content": [
+      {"YouTube": {"http://www.youtube.com/": ["ytimg.com"]}}
+    ]
+  }
+}`
+
+const entities = `
+{
+  "entities": {
+    "Meta": {"properties": ["facebook.com", "instagram.com"], "resources": ["facebook.net", "fbcdn.net"]}
+  }
+}`
+
+const text = `# domain categories entity
+telemetry.microsoft.com telemetry Microsoft Corporation
+graph.facebook.com social,analytics
+malware.needle.local malware
+static.fbcdn.net -   # entity of the parent domain
+`
+
+func Test_Dataset(t *testing.T) {
+	is := require.New(t)
+
+	dir := t.TempDir()
+	var paths []string
+	for i, content := range []string{services, entities, text} {
+		path := filepath.Join(dir, strconv.Itoa(i))
+		is.NoError(os.WriteFile(path, []byte(content), 0o600))
+		paths = append(paths, path)
+	}
+
+	d, err := category.Open(paths...)
+	is.NoError(err)
+	is.Equal(11, d.Len())
+
+	for name, want := range map[string]category.Tag{
+		"ad.doubleclick.net":          {Entity: "Google", Categories: []category.Category{category.Ads}},
+		"connect.facebook.net.":       {Entity: "Meta", Categories: []category.Category{category.Social}},
+		"i.ytimg.com":                 {Entity: "YouTube"},
+		"www.instagram.com":           {Entity: "Meta"},
+		"graph.facebook.com":          {Entity: "Meta", Categories: []category.Category{category.Analytics, category.Social}},
+		"v10.telemetry.microsoft.com": {Entity: "Microsoft Corporation", Categories: []category.Category{category.Telemetry}},
+		"static.fbcdn.net":            {Entity: "Meta"},
+		"malware.needle.local":        {Categories: []category.Category{category.Malware}},
+	} {
+		tag, ok := d.Lookup(name)
+		is.True(ok, name)
+		is.Equal(want, tag, name)
+	}
+
+	_, ok := d.Lookup("needle.local")
+	is.False(ok)
+	is.Empty(d.Entity("www.needle.local"))
+	is.Equal("Meta", d.Entity("www.facebook.com"))
+
+	list := d.List(category.Ads)
+	is.Equal("ads", list.Name)
+	is.Equal([]blocklist.Rule{
+		{Domain: "doubleclick.net", Subdomains: true},
+		{Domain: "googlesyndication.com", Subdomains: true},
+	}, list.Rules)
+	is.True(blocklist.Compile(list).Blocked("pagead2.googlesyndication.com"))
+	is.Empty(d.List(category.Adult).Rules)
+}
+
+func Test_DatasetErrors(t *testing.T) {
+	is := require.New(t)
+
+	for _, content := range []string{
+		`{"version": 1}`,
+		`{"categories": [}`,
+		"ads.needle.local",
+		"ads.needle.local games",
+	} {
+		is.Error(category.New().Read(strings.NewReader(content)), content)
+	}
+
+	is.NoError(category.New().Read(strings.NewReader("")))
+
+	_, err := category.Open(filepath.Join(t.TempDir(), "missing.json"))
+	is.Error(err)
+
+	c, err := category.Parse(" Ads")
+	is.NoError(err)
+	is.Equal(category.Ads, c)
+	_, err = category.Parse("games")
+	is.Error(err)
+}
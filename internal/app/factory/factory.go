@@ -2,14 +2,19 @@ package factory
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"fmt"
 	"math/big"
 	"net"
+	"slices"
+	"sync"
 	"time"
 
 	"go.pixelfactory.io/needle/internal/app/pki"
@@ -17,25 +22,65 @@ import (
 
 // Factory represents the certificate factory.
 type Factory struct {
-	rootCA tls.Certificate
+	mu      sync.RWMutex
+	rootCA  tls.Certificate
+	trusted []*x509.Certificate
+	// addresses are added to the IP SANs of new certificates
+	addresses []net.IP
+	// verified holds the certificates which verified against rootCA, so
+	// handshakes do not verify the same certificate again, SetCA clears it
+	verified map[string]verified
+	// generation counts SetCA calls
+	generation uint64
+}
+
+// verified is a certificate which verified against the active CA.
+type verified struct {
+	sum      [sha256.Size]byte
+	notAfter time.Time
 }
 
 // New create certificateFactory.
-func New(rootCA tls.Certificate) *Factory {
-	return &Factory{rootCA: rootCA}
+// rootCA signs new certificates, trusted CAs are only served to clients
+// during a CA rotation, certificates they issued are re-issued by rootCA.
+func New(rootCA tls.Certificate, trusted ...*x509.Certificate) *Factory {
+	return &Factory{rootCA: rootCA, trusted: trusted}
+}
+
+// SetCA replaces the CA pair and trusted CAs, it is safe to call while certificates are created.
+func (f *Factory) SetCA(rootCA tls.Certificate, trusted ...*x509.Certificate) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.rootCA = rootCA
+	f.trusted = trusted
+	f.verified = nil
+	f.generation++
+}
+
+// SetAddresses set the needle addresses added to the IP SANs of new
+// certificates, next to 127.0.0.1 and ::1.
+func (f *Factory) SetAddresses(addresses ...net.IP) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.addresses = addresses
 }
 
 // Create creates a certificate.
-func (f *Factory) Create(name string) (*pki.InternalCert, error) {
+func (f *Factory) Create(_ context.Context, name string) (*pki.InternalCert, error) {
 	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
 	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
 	if err != nil {
 		return nil, err
 	}
 
+	f.mu.RLock()
+	IPAddresses := append([]net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}, f.addresses...)
+	f.mu.RUnlock()
+
 	// Try to parse name as IP.
-	IPAddresses := []net.IP{net.ParseIP("0.0.0.0"), net.ParseIP("127.0.0.1")}
-	if ip := net.ParseIP(name); ip != nil {
+	if ip := net.ParseIP(name); ip != nil && !slices.ContainsFunc(IPAddresses, ip.Equal) {
 		IPAddresses = append(IPAddresses, ip)
 	}
 
@@ -57,12 +102,16 @@ func (f *Factory) Create(name string) (*pki.InternalCert, error) {
 		return nil, err
 	}
 
-	ca, err := x509.ParseCertificate(f.rootCA.Certificate[0])
+	f.mu.RLock()
+	rootCA := f.rootCA
+	f.mu.RUnlock()
+
+	ca, err := parseCA(rootCA)
 	if err != nil {
 		return nil, err
 	}
 
-	certBytes, err := x509.CreateCertificate(rand.Reader, cert, ca, &certPrivKey.PublicKey, f.rootCA.PrivateKey)
+	certBytes, err := x509.CreateCertificate(rand.Reader, cert, ca, &certPrivKey.PublicKey, rootCA.PrivateKey)
 	if err != nil {
 		return nil, err
 	}
@@ -85,3 +134,94 @@ func (f *Factory) Create(name string) (*pki.InternalCert, error) {
 		KeyPEM:  certPrivKeyPEM.Bytes(),
 	}, nil
 }
+
+// Verify checks that cert is valid and was issued by the active CA, the
+// result is kept until the certificate or the CA changes, or either expires.
+func (f *Factory) Verify(cert *pki.InternalCert) error {
+	sum := sha256.Sum256(append(slices.Clip(cert.CertPEM), cert.KeyPEM...))
+
+	f.mu.RLock()
+	rootCA, generation := f.rootCA, f.generation
+	v, ok := f.verified[cert.Name]
+	f.mu.RUnlock()
+	if ok && v.sum == sum && time.Now().Before(v.notAfter) {
+		return nil
+	}
+
+	ca, err := parseCA(rootCA)
+	if err != nil {
+		return err
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	if err := pki.Verify(cert, roots); err != nil {
+		return err
+	}
+
+	block, _ := pem.Decode(cert.CertPEM)
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// SetCA was called while verifying
+	if f.generation != generation {
+		return nil
+	}
+	if f.verified == nil {
+		f.verified = map[string]verified{}
+	}
+	f.verified[cert.Name] = verified{sum: sum, notAfter: earliest(leaf.NotAfter, ca.NotAfter)}
+
+	return nil
+}
+
+// Roots returns the active and trusted CA certificates.
+func (f *Factory) Roots() ([]*x509.Certificate, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	ca, err := parseCA(f.rootCA)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]*x509.Certificate{ca}, f.trusted...), nil
+}
+
+// CheckExpiry returns an error when the active CA expires within d.
+func (f *Factory) CheckExpiry(d time.Duration) error {
+	f.mu.RLock()
+	ca, err := parseCA(f.rootCA)
+	f.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	if time.Now().Add(d).After(ca.NotAfter) {
+		return fmt.Errorf("CA %q expires on %s", ca.Subject.CommonName, ca.NotAfter.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// earliest returns the earliest of a and b.
+func earliest(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// parseCA returns the x509 certificate of a CA pair.
+func parseCA(rootCA tls.Certificate) (*x509.Certificate, error) {
+	if rootCA.Leaf != nil {
+		return rootCA.Leaf, nil
+	}
+	return x509.ParseCertificate(rootCA.Certificate[0])
+}
@@ -1,9 +1,16 @@
 package factory_test
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.pixelfactory.io/needle/internal/app/factory"
@@ -35,7 +42,7 @@ func Test_Create(t *testing.T) {
 
 	t.Run("Create certificate", func(_ *testing.T) {
 		// create certificate
-		cert, err := certFactory.Create("test.needle.local")
+		cert, err := certFactory.Create(context.Background(), "test.needle.local")
 		is.NoError(err)
 		is.Equal(cert.Name, "test.needle.local")
 
@@ -53,8 +60,117 @@ func Test_Create(t *testing.T) {
 
 	t.Run("Create certificate IP", func(_ *testing.T) {
 		// create certificate
-		cert, err := certFactory.Create("192.168.1.1")
+		cert, err := certFactory.Create(context.Background(), "192.168.1.1")
 		is.NoError(err)
 		is.Equal(cert.Name, "192.168.1.1")
 	})
+
+	t.Run("Create certificate with needle addresses", func(_ *testing.T) {
+		certFactory.SetAddresses(net.ParseIP("192.168.1.2"), net.ParseIP("fd00::2"))
+
+		cert, err := certFactory.Create(context.Background(), "192.168.1.2")
+		is.NoError(err)
+
+		tlsCert, err := tls.X509KeyPair(cert.CertPEM, cert.KeyPEM)
+		is.NoError(err)
+		x509tlsCert, err := x509.ParseCertificate(tlsCert.Certificate[0])
+		is.NoError(err)
+
+		var ips []string
+		for _, ip := range x509tlsCert.IPAddresses {
+			ips = append(ips, ip.String())
+		}
+		is.Equal([]string{"127.0.0.1", "::1", "192.168.1.2", "fd00::2"}, ips)
+	})
+}
+
+// newCA creates a self-signed CA.
+func newCA(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "identity-next.needle.local"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func Test_Verify(t *testing.T) {
+	is := require.New(t)
+
+	rootCA, testCert := testdata.Setup(t)
+	nextCA := newCA(t)
+
+	oldCACert, err := x509.ParseCertificate(rootCA.Certificate[0])
+	is.NoError(err)
+
+	t.Run("Verify certificate issued by active CA", func(_ *testing.T) {
+		is.NoError(factory.New(rootCA).Verify(testCert))
+	})
+
+	t.Run("Verify certificate issued by retired CA", func(_ *testing.T) {
+		certFactory := factory.New(nextCA, oldCACert)
+		is.Error(certFactory.Verify(testCert))
+
+		cert, err := certFactory.Create(context.Background(), "test.needle.local")
+		is.NoError(err)
+		is.NoError(certFactory.Verify(cert))
+	})
+
+	t.Run("Verify changed certificate after a cached result", func(_ *testing.T) {
+		certFactory := factory.New(rootCA)
+
+		cert, err := certFactory.Create(context.Background(), "test.needle.local")
+		is.NoError(err)
+		is.NoError(certFactory.Verify(cert))
+		is.NoError(certFactory.Verify(cert))
+
+		other, err := certFactory.Create(context.Background(), "test.needle.local")
+		is.NoError(err)
+		is.Error(certFactory.Verify(&pki.InternalCert{Name: cert.Name, CertPEM: cert.CertPEM, KeyPEM: other.KeyPEM}))
+	})
+
+	t.Run("Roots", func(_ *testing.T) {
+		roots, err := factory.New(nextCA, oldCACert).Roots()
+		is.NoError(err)
+		is.Len(roots, 2)
+		is.Equal(oldCACert, roots[1])
+	})
+}
+
+func Test_SetCA(t *testing.T) {
+	is := require.New(t)
+
+	rootCA, testCert := testdata.Setup(t)
+	nextCA := newCA(t)
+
+	certFactory := factory.New(rootCA)
+	is.NoError(certFactory.Verify(testCert))
+
+	certFactory.SetCA(nextCA)
+	is.Error(certFactory.Verify(testCert))
+
+	cert, err := certFactory.Create(context.Background(), "test.needle.local")
+	is.NoError(err)
+	is.NoError(certFactory.Verify(cert))
+}
+
+func Test_CheckExpiry(t *testing.T) {
+	is := require.New(t)
+
+	certFactory := factory.New(newCA(t))
+	is.NoError(certFactory.CheckExpiry(30 * 24 * time.Hour))
+	is.ErrorContains(certFactory.CheckExpiry(2*365*24*time.Hour), "identity-next.needle.local")
 }
@@ -0,0 +1,104 @@
+package pki
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+
+	"github.com/pkg/errors"
+)
+
+// CheckMode defines what Check does with broken certificates.
+type CheckMode int
+
+const (
+	// CheckOnly reports broken certificates.
+	CheckOnly CheckMode = iota
+	// CheckRepair re-issues broken certificates.
+	CheckRepair
+	// CheckDelete deletes broken certificates.
+	CheckDelete
+)
+
+// CheckResult holds the outcome of checking a stored certificate.
+type CheckResult struct {
+	Name   string
+	Err    error
+	Action string
+}
+
+// Verify checks that cert PEMs parse, the key matches the certificate
+// and the certificate chain verifies against roots.
+func Verify(cert *InternalCert, roots *x509.CertPool) error {
+	tlsCert, err := tls.X509KeyPair(cert.CertPEM, cert.KeyPEM)
+	if err != nil {
+		return errors.Wrap(err, "invalid key pair")
+	}
+
+	leaf, err := x509.ParseCertificate(tlsCert.Certificate[0])
+	if err != nil {
+		return errors.Wrap(err, "invalid certificate")
+	}
+
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	if err != nil {
+		return errors.Wrap(err, "invalid chain")
+	}
+
+	return nil
+}
+
+// Check verifies every stored certificate against roots, broken certificates
+// and entries which cannot be read are re-issued or deleted depending on mode.
+func (s *Service) Check(ctx context.Context, roots *x509.CertPool, mode CheckMode) ([]CheckResult, error) {
+	certs, unreadable, err := s.list(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "pki.Service.Check")
+	}
+
+	results := make([]CheckResult, 0, len(certs)+len(unreadable))
+	for _, cert := range certs {
+		results = append(results, CheckResult{Name: cert.Name, Err: Verify(cert, roots)})
+	}
+	for _, name := range unreadable.Names() {
+		results = append(results, CheckResult{Name: name, Err: unreadable[name]})
+	}
+
+	for i := range results {
+		result := &results[i]
+		if result.Err == nil {
+			continue
+		}
+
+		// on failure, the results up to the failing one are returned,
+		// without action
+		switch mode {
+		case CheckRepair:
+			if err := s.reissue(ctx, result.Name); err != nil {
+				return results[:i+1], errors.Wrapf(err, "pki.Service.Check: repair %s", result.Name)
+			}
+			result.Action = "repaired"
+		case CheckDelete:
+			if err := s.certRepo.Delete(ctx, result.Name); err != nil {
+				return results[:i+1], errors.Wrapf(err, "pki.Service.Check: delete %s", result.Name)
+			}
+			result.Action = "deleted"
+		case CheckOnly:
+		}
+	}
+
+	return results, nil
+}
+
+// reissue creates and stores a new certificate for name.
+func (s *Service) reissue(ctx context.Context, name string) error {
+	cert, err := s.certFactory.Create(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	return s.certRepo.Store(ctx, cert)
+}
@@ -1,21 +1,58 @@
 package pki
 
 import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
 	"github.com/pkg/errors"
 )
 
 // ErrCertificateNotFound unable to find certificate.
 var ErrCertificateNotFound = errors.New("Certificate Not Found")
 
+// EntryErrors is returned by Repository.List next to the readable
+// certificates, with the error of each entry which could not be read, such
+// as a private key which does not decrypt, by name.
+type EntryErrors map[string]error
+
+// Names returns the names of the unreadable entries, sorted.
+func (e EntryErrors) Names() []string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (e EntryErrors) Error() string {
+	return fmt.Sprintf("%d unreadable certificates: %s", len(e), strings.Join(e.Names(), ", "))
+}
+
+// list returns the readable certificates and the unreadable entries.
+func (s *Service) list(ctx context.Context) ([]*InternalCert, EntryErrors, error) {
+	certs, err := s.certRepo.List(ctx)
+	var unreadable EntryErrors
+	if err != nil && !errors.As(err, &unreadable) {
+		return nil, nil, err
+	}
+	return certs, unreadable, nil
+}
+
 // Factory interface.
 type Factory interface {
-	Create(name string) (*InternalCert, error)
+	Create(ctx context.Context, name string) (*InternalCert, error)
+	Verify(cert *InternalCert) error
 }
 
 // Repository interface.
 type Repository interface {
-	Get(name string) (*InternalCert, error)
-	Store(certificate *InternalCert) error
+	Delete(ctx context.Context, name string) error
+	Get(ctx context.Context, name string) (*InternalCert, error)
+	List(ctx context.Context) ([]*InternalCert, error)
+	Store(ctx context.Context, certificate *InternalCert) error
 }
 
 // Service represents a Certificate service.
@@ -33,17 +70,24 @@ func New(certRepo Repository, certFactory Factory) *Service {
 }
 
 // GetOrCreate retrives or create a certificat for the given name.
-func (s *Service) GetOrCreate(name string) (*InternalCert, error) {
-	var cert *InternalCert
-	cert, err := s.certRepo.Get(name)
+func (s *Service) GetOrCreate(ctx context.Context, name string) (*InternalCert, error) {
+	cert, err := s.certRepo.Get(ctx, name)
+	if err != nil && !errors.Is(err, ErrCertificateNotFound) {
+		// such as a stored key which cannot be decrypted
+		return nil, errors.Wrap(err, "pki.Service.GetOrCreate")
+	}
+	if err == nil && s.certFactory.Verify(cert) != nil {
+		// Certificate was issued by a retired CA, or is no longer valid
+		err = ErrCertificateNotFound
+	}
 	if errors.Is(err, ErrCertificateNotFound) {
 		// Create new Certificate
-		cert, err = s.certFactory.Create(name)
+		cert, err = s.certFactory.Create(ctx, name)
 		if err != nil {
 			return nil, errors.Wrap(err, "pki.Service.GetOrCreate")
 		}
 		// Store Certificate
-		err := s.certRepo.Store(cert)
+		err := s.certRepo.Store(ctx, cert)
 		if err != nil {
 			return nil, errors.Wrap(err, "pki.Service.GetOrCreate")
 		}
@@ -51,3 +95,29 @@ func (s *Service) GetOrCreate(name string) (*InternalCert, error) {
 	}
 	return cert, nil
 }
+
+// Reissue re-issues every stored certificate which does not verify against the active CA
+// or cannot be read. It returns the names of re-issued certificates.
+func (s *Service) Reissue(ctx context.Context) ([]string, error) {
+	certs, unreadable, err := s.list(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "pki.Service.Reissue")
+	}
+
+	stale := unreadable.Names()
+	for _, cert := range certs {
+		if s.certFactory.Verify(cert) != nil {
+			stale = append(stale, cert.Name)
+		}
+	}
+
+	var names []string
+	for _, name := range stale {
+		if err := s.reissue(ctx, name); err != nil {
+			return names, errors.Wrap(err, "pki.Service.Reissue")
+		}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
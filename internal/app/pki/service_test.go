@@ -1,11 +1,16 @@
 package pki_test
 
 import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
 	"testing"
 
 	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"go.pixelfactory.io/needle/internal/app/pki"
+	"go.pixelfactory.io/needle/internal/infra/encryption"
 	"go.pixelfactory.io/needle/internal/infra/http/handlers"
 	mocks "go.pixelfactory.io/needle/mocks/pki"
 	"go.pixelfactory.io/needle/testdata"
@@ -31,11 +36,11 @@ func Test_GetOrCreate(t *testing.T) {
 	svc := pki.New(repo, factory)
 
 	t.Run("Create certificate", func(_ *testing.T) {
-		repo.On("Get", "test.needle.local").Return(nil, pki.ErrCertificateNotFound).Once()
-		factory.On("Create", "test.needle.local").Return(testCert, nil).Once()
-		repo.On("Store", testCert).Return(nil).Once()
+		repo.On("Get", mock.Anything, "test.needle.local").Return(nil, pki.ErrCertificateNotFound).Once()
+		factory.On("Create", mock.Anything, "test.needle.local").Return(testCert, nil).Once()
+		repo.On("Store", mock.Anything, testCert).Return(nil).Once()
 
-		cert, err := svc.GetOrCreate("test.needle.local")
+		cert, err := svc.GetOrCreate(context.Background(), "test.needle.local")
 		is.NoError(err)
 		is.NotEmpty(cert)
 		repo.AssertExpectations(t)
@@ -43,22 +48,59 @@ func Test_GetOrCreate(t *testing.T) {
 	})
 
 	t.Run("Get certificate", func(_ *testing.T) {
-		repo.On("Get", "test.needle.local").Return(testCert, nil).Once()
+		repo.On("Get", mock.Anything, "test.needle.local").Return(testCert, nil).Once()
+		factory.On("Verify", testCert).Return(nil).Once()
 
-		cert, err := svc.GetOrCreate("test.needle.local")
+		cert, err := svc.GetOrCreate(context.Background(), "test.needle.local")
 		is.NoError(err)
 		is.NotEmpty(cert.Name)
 		is.NotEmpty(cert.CertPEM)
 		is.NotEmpty(cert.KeyPEM)
 		is.Equal(cert, testCert)
 		repo.AssertExpectations(t)
+		factory.AssertExpectations(t)
+	})
+
+	t.Run("Get certificate issued by retired CA", func(_ *testing.T) {
+		staleCert := &pki.InternalCert{Name: "test.needle.local"}
+		repo.On("Get", mock.Anything, "test.needle.local").Return(staleCert, nil).Once()
+		factory.On("Verify", staleCert).Return(errors.New("unknown authority")).Once()
+		factory.On("Create", mock.Anything, "test.needle.local").Return(testCert, nil).Once()
+		repo.On("Store", mock.Anything, testCert).Return(nil).Once()
+
+		cert, err := svc.GetOrCreate(context.Background(), "test.needle.local")
+		is.NoError(err)
+		is.Equal(cert, testCert)
+		repo.AssertExpectations(t)
+		factory.AssertExpectations(t)
+	})
+
+	t.Run("Get undecryptable certificate", func(_ *testing.T) {
+		sealing := encryption.NewKeyring()
+		is.NoError(sealing.Parse("old:0123456789abcdef"))
+		keyPEM, err := sealing.Seal(testCert.Name, testCert.KeyPEM)
+		is.NoError(err)
+		sealed := *testCert
+		sealed.KeyPEM = keyPEM
+
+		keyring := encryption.NewKeyring()
+		is.NoError(keyring.Parse("new:fedcba9876543210"))
+		store := &mocks.Repository{}
+		store.On("Get", mock.Anything, "test.needle.local").Return(&sealed, nil).Once()
+
+		svc := pki.New(encryption.NewRepository(store, keyring), factory)
+		cert, err := svc.GetOrCreate(context.Background(), "test.needle.local")
+		is.ErrorIs(err, encryption.ErrUnknownKey)
+		is.Nil(cert)
+		store.AssertExpectations(t)
+		factory.AssertExpectations(t)
 	})
 
 	t.Run("Get certificate create error", func(_ *testing.T) {
-		repo.On("Get", "test.needle.local").Return(nil, pki.ErrCertificateNotFound).Once()
-		factory.On("Create", "test.needle.local").Return(nil, errors.New("unable to create certificate")).Once()
+		repo.On("Get", mock.Anything, "test.needle.local").Return(nil, pki.ErrCertificateNotFound).Once()
+		factory.On("Create", mock.Anything, "test.needle.local").Return(nil, errors.New("unable to create certificate")).Once()
 
-		cert, err := svc.GetOrCreate("test.needle.local")
+		cert, err := svc.GetOrCreate(context.Background(), "test.needle.local")
 		is.Error(err)
 		is.Empty(cert)
 		repo.AssertExpectations(t)
@@ -66,14 +108,177 @@ func Test_GetOrCreate(t *testing.T) {
 	})
 
 	t.Run("Get certificate store error", func(_ *testing.T) {
-		repo.On("Get", "test.needle.local").Return(nil, pki.ErrCertificateNotFound).Once()
-		factory.On("Create", "test.needle.local").Return(testCert, nil).Once()
-		repo.On("Store", testCert).Return(errors.New("unable to store certificate")).Once()
+		repo.On("Get", mock.Anything, "test.needle.local").Return(nil, pki.ErrCertificateNotFound).Once()
+		factory.On("Create", mock.Anything, "test.needle.local").Return(testCert, nil).Once()
+		repo.On("Store", mock.Anything, testCert).Return(errors.New("unable to store certificate")).Once()
 
-		cert, err := svc.GetOrCreate("test.needle.local")
+		cert, err := svc.GetOrCreate(context.Background(), "test.needle.local")
 		is.Error(err)
 		is.Empty(cert)
 		repo.AssertExpectations(t)
 		factory.AssertExpectations(t)
 	})
 }
+
+func Test_Check(t *testing.T) {
+	is := require.New(t)
+
+	rootCA, testCert := testdata.Setup(t)
+	x509CACert, err := x509.ParseCertificate(rootCA.Certificate[0])
+	is.NoError(err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(x509CACert)
+
+	brokenCert := &pki.InternalCert{Name: "broken.needle.local", CertPEM: testCert.CertPEM}
+
+	t.Run("Verify certificate", func(_ *testing.T) {
+		is.NoError(pki.Verify(testCert, roots))
+		is.Error(pki.Verify(brokenCert, roots))
+		is.Error(pki.Verify(testCert, x509.NewCertPool()))
+	})
+
+	t.Run("Check only", func(_ *testing.T) {
+		repo := mocks.NewRepository(t)
+		svc := pki.New(repo, mocks.NewFactory(t))
+		repo.On("List", mock.Anything).Return([]*pki.InternalCert{testCert, brokenCert}, nil).Once()
+
+		results, err := svc.Check(context.Background(), roots, pki.CheckOnly)
+		is.NoError(err)
+		is.Len(results, 2)
+		is.NoError(results[0].Err)
+		is.Error(results[1].Err)
+		is.Empty(results[1].Action)
+	})
+
+	t.Run("Check repair", func(_ *testing.T) {
+		repo := mocks.NewRepository(t)
+		factory := mocks.NewFactory(t)
+		svc := pki.New(repo, factory)
+		repo.On("List", mock.Anything).Return([]*pki.InternalCert{testCert, brokenCert}, nil).Once()
+		factory.On("Create", mock.Anything, "broken.needle.local").Return(testCert, nil).Once()
+		repo.On("Store", mock.Anything, testCert).Return(nil).Once()
+
+		results, err := svc.Check(context.Background(), roots, pki.CheckRepair)
+		is.NoError(err)
+		is.Equal("repaired", results[1].Action)
+	})
+
+	t.Run("Check repair failure", func(_ *testing.T) {
+		repo := mocks.NewRepository(t)
+		factory := mocks.NewFactory(t)
+		svc := pki.New(repo, factory)
+		repo.On("List", mock.Anything).Return([]*pki.InternalCert{testCert, brokenCert}, nil).Once()
+		factory.On("Create", mock.Anything, "broken.needle.local").Return(testCert, nil).Once()
+		repo.On("Store", mock.Anything, testCert).Return(errors.New("database is read-only")).Once()
+
+		results, err := svc.Check(context.Background(), roots, pki.CheckRepair)
+		is.ErrorContains(err, "repair broken.needle.local: database is read-only")
+		is.Len(results, 2)
+		is.Equal("broken.needle.local", results[1].Name)
+		is.Error(results[1].Err)
+		is.Empty(results[1].Action)
+	})
+
+	t.Run("Check delete", func(_ *testing.T) {
+		repo := mocks.NewRepository(t)
+		svc := pki.New(repo, mocks.NewFactory(t))
+		repo.On("List", mock.Anything).Return([]*pki.InternalCert{testCert, brokenCert}, nil).Once()
+		repo.On("Delete", mock.Anything, "broken.needle.local").Return(nil).Once()
+
+		results, err := svc.Check(context.Background(), roots, pki.CheckDelete)
+		is.NoError(err)
+		is.Equal("deleted", results[1].Action)
+	})
+
+	t.Run("Check entries which do not decrypt", func(_ *testing.T) {
+		keyring := encryption.NewKeyring()
+		is.NoError(keyring.Parse("k1:0123456789abcdef"))
+		sealed, err := keyring.Seal(testCert.Name, testCert.KeyPEM)
+		is.NoError(err)
+		block, _ := pem.Decode(sealed)
+		block.Bytes[len(block.Bytes)-1] ^= 0xff
+		tampered := *testCert
+		tampered.KeyPEM = pem.EncodeToMemory(block)
+
+		store := mocks.NewRepository(t)
+		factory := mocks.NewFactory(t)
+		svc := pki.New(encryption.NewRepository(store, keyring), factory)
+		store.On("List", mock.Anything).Return([]*pki.InternalCert{&tampered}, nil).Twice()
+
+		results, err := svc.Check(context.Background(), roots, pki.CheckOnly)
+		is.NoError(err)
+		is.Len(results, 1)
+		is.Equal(testCert.Name, results[0].Name)
+		is.Error(results[0].Err)
+
+		factory.On("Create", mock.Anything, testCert.Name).Return(testCert, nil).Once()
+		store.On("Store", mock.Anything, mock.Anything).Return(nil).Once()
+
+		results, err = svc.Check(context.Background(), roots, pki.CheckRepair)
+		is.NoError(err)
+		is.Equal("repaired", results[0].Action)
+	})
+
+	t.Run("Check list error", func(_ *testing.T) {
+		repo := mocks.NewRepository(t)
+		svc := pki.New(repo, mocks.NewFactory(t))
+		repo.On("List", mock.Anything).Return(nil, errors.New("unable to list certificates")).Once()
+
+		_, err := svc.Check(context.Background(), roots, pki.CheckOnly)
+		is.Error(err)
+	})
+}
+
+func Test_Reissue(t *testing.T) {
+	is := require.New(t)
+
+	_, testCert := testdata.Setup(t)
+	staleCert := &pki.InternalCert{Name: "stale.needle.local"}
+
+	t.Run("Reissue stale certificates", func(_ *testing.T) {
+		repo := mocks.NewRepository(t)
+		factory := mocks.NewFactory(t)
+		svc := pki.New(repo, factory)
+
+		repo.On("List", mock.Anything).Return([]*pki.InternalCert{testCert, staleCert}, nil).Once()
+		factory.On("Verify", testCert).Return(nil).Once()
+		factory.On("Verify", staleCert).Return(errors.New("unknown authority")).Once()
+		factory.On("Create", mock.Anything, "stale.needle.local").Return(testCert, nil).Once()
+		repo.On("Store", mock.Anything, testCert).Return(nil).Once()
+
+		names, err := svc.Reissue(context.Background())
+		is.NoError(err)
+		is.Equal([]string{"stale.needle.local"}, names)
+	})
+
+	t.Run("Reissue unreadable certificates", func(_ *testing.T) {
+		repo := mocks.NewRepository(t)
+		factory := mocks.NewFactory(t)
+		svc := pki.New(repo, factory)
+
+		repo.On("List", mock.Anything).Return([]*pki.InternalCert{testCert},
+			pki.EntryErrors{"broken.needle.local": errors.New("message authentication failed")}).Once()
+		factory.On("Verify", testCert).Return(nil).Once()
+		factory.On("Create", mock.Anything, "broken.needle.local").Return(testCert, nil).Once()
+		repo.On("Store", mock.Anything, testCert).Return(nil).Once()
+
+		names, err := svc.Reissue(context.Background())
+		is.NoError(err)
+		is.Equal([]string{"broken.needle.local"}, names)
+	})
+
+	t.Run("Reissue create error", func(_ *testing.T) {
+		repo := mocks.NewRepository(t)
+		factory := mocks.NewFactory(t)
+		svc := pki.New(repo, factory)
+
+		repo.On("List", mock.Anything).Return([]*pki.InternalCert{staleCert}, nil).Once()
+		factory.On("Verify", staleCert).Return(errors.New("unknown authority")).Once()
+		factory.On("Create", mock.Anything, "stale.needle.local").Return(nil, errors.New("unable to create certificate")).Once()
+
+		names, err := svc.Reissue(context.Background())
+		is.Error(err)
+		is.Empty(names)
+	})
+}
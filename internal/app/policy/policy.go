@@ -0,0 +1,279 @@
+// Package policy applies different blocklists and allow rules to groups of
+// clients, matched by address, network or MAC address.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"go.pixelfactory.io/needle/internal/app/blocklist"
+	"go.pixelfactory.io/needle/internal/app/schedule"
+)
+
+// DefaultGroup names the group of the clients in no other group.
+const DefaultGroup = "default"
+
+// exactMatch ranks MAC and single address clients above any network.
+const exactMatch = 256
+
+// Client matches devices by address, network or MAC address.
+type Client struct {
+	Prefix netip.Prefix
+	MAC    net.HardwareAddr
+}
+
+// ParseClient parses an IP address, a CIDR network or a MAC address.
+func ParseClient(s string) (Client, error) {
+	s = strings.TrimSpace(s)
+	if prefix, err := netip.ParsePrefix(s); err == nil {
+		return Client{Prefix: prefix.Masked()}, nil
+	}
+	if addr, err := netip.ParseAddr(s); err == nil {
+		addr = addr.Unmap()
+		return Client{Prefix: netip.PrefixFrom(addr, addr.BitLen())}, nil
+	}
+	if mac, err := net.ParseMAC(s); err == nil {
+		return Client{MAC: mac}, nil
+	}
+	return Client{}, fmt.Errorf("policy.ParseClient: %q is not an IP, CIDR or MAC address", s)
+}
+
+// String returns the client as parsed.
+func (c Client) String() string {
+	if c.MAC != nil {
+		return c.MAC.String()
+	}
+	if c.Prefix.IsSingleIP() {
+		return c.Prefix.Addr().String()
+	}
+	return c.Prefix.String()
+}
+
+// rank returns how specifically the client matches addr, zero when it does
+// not match.
+func (c Client) rank(addr netip.Addr, mac net.HardwareAddr) int {
+	switch {
+	case c.MAC != nil:
+		if mac != nil && slices.Equal(c.MAC, mac) {
+			return exactMatch
+		}
+		return 0
+	case !c.Prefix.Contains(addr):
+		return 0
+	case c.Prefix.IsSingleIP():
+		return exactMatch
+	default:
+		// a /0 network still matches
+		return c.Prefix.Bits() + 1
+	}
+}
+
+// Group is a set of clients with their own blocklists and allow rules.
+type Group struct {
+	Name    string
+	Clients []Client
+	// Lists names the blocklists enforced for the clients, every list when
+	// nil and none when empty.
+	Lists []string
+	// Allow excepts names from the lists of the group.
+	Allow []blocklist.Rule
+	// Schedule restricts the group to its windows, its clients are in the
+	// default group otherwise. The group always applies when nil.
+	Schedule *schedule.Schedule
+}
+
+// Leases finds the MAC address of a client from its address.
+type Leases interface {
+	MAC(ip net.IP) (net.HardwareAddr, bool)
+}
+
+// group is a compiled Group.
+type group struct {
+	Group
+	lists map[string]bool
+	allow *blocklist.Set
+}
+
+// Policy finds the group of clients and the lists enforced for them.
+type Policy struct {
+	groups    []*group
+	fallback  *group
+	leases    Leases
+	macs      bool
+	schedules map[string]*schedule.Schedule
+	now       func() time.Time
+}
+
+// Option type.
+type Option func(*Policy)
+
+// WithGroups set the groups, the group named DefaultGroup applies to the
+// clients in no other group.
+func WithGroups(groups ...Group) Option {
+	return func(p *Policy) {
+		for _, g := range groups {
+			p.groups = append(p.groups, &group{Group: g})
+		}
+	}
+}
+
+// WithLeases set how MAC addresses of clients are found.
+func WithLeases(l Leases) Option {
+	return func(p *Policy) {
+		p.leases = l
+	}
+}
+
+// WithListSchedules set the schedules of lists, which are only enforced
+// during them for every group.
+func WithListSchedules(schedules map[string]*schedule.Schedule) Option {
+	return func(p *Policy) {
+		p.schedules = schedules
+	}
+}
+
+// WithClock set the time source of schedules.
+func WithClock(now func() time.Time) Option {
+	return func(p *Policy) {
+		p.now = now
+	}
+}
+
+// New returns a Policy, clients are in no group without groups.
+func New(opts ...Option) (*Policy, error) {
+	p := &Policy{now: time.Now}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	names := map[string]bool{}
+	groups := p.groups[:0]
+	for _, g := range p.groups {
+		switch {
+		case g.Name == "":
+			return nil, errors.New("policy.New: group without name")
+		case names[g.Name]:
+			return nil, fmt.Errorf("policy.New: duplicate group %q", g.Name)
+		case g.Name == DefaultGroup && (len(g.Clients) > 0 || g.Schedule != nil):
+			return nil, fmt.Errorf("policy.New: group %q applies to clients in no other group", DefaultGroup)
+		}
+		names[g.Name] = true
+
+		if g.Lists != nil {
+			g.lists = make(map[string]bool, len(g.Lists))
+			for _, list := range g.Lists {
+				g.lists[list] = true
+			}
+		}
+
+		// allow rules are compiled as block rules, a match allows the name
+		if len(g.Allow) > 0 {
+			rules := make([]blocklist.Rule, len(g.Allow))
+			for i, rule := range g.Allow {
+				rule.Allow = false
+				rules[i] = rule
+			}
+			g.allow = blocklist.Compile(blocklist.List{Name: g.Name, Rules: rules})
+		}
+
+		for _, c := range g.Clients {
+			p.macs = p.macs || c.MAC != nil
+		}
+
+		if g.Name == DefaultGroup {
+			p.fallback = g
+			continue
+		}
+		groups = append(groups, g)
+	}
+	p.groups = groups
+
+	if p.macs && p.leases == nil {
+		return nil, errors.New("policy.New: MAC addresses require leases")
+	}
+
+	return p, nil
+}
+
+// Groups returns the groups, in configuration order and the default one
+// last.
+func (p *Policy) Groups() []Group {
+	groups := make([]Group, 0, len(p.groups)+1)
+	for _, g := range p.groups {
+		groups = append(groups, g.Group)
+	}
+	if p.fallback != nil {
+		groups = append(groups, p.fallback.Group)
+	}
+	return groups
+}
+
+// ListSchedules returns the schedules of lists by list name.
+func (p *Policy) ListSchedules() map[string]*schedule.Schedule {
+	return p.schedules
+}
+
+// Group returns the name of the group of client, empty when it is in none.
+func (p *Policy) Group(client net.IP) string {
+	if g := p.group(client, p.now()); g != nil {
+		return g.Name
+	}
+	return ""
+}
+
+// group returns the group active at now matching client the most
+// specifically: by MAC or address first, then by the longest network. Ties
+// go to the first group.
+func (p *Policy) group(client net.IP, now time.Time) *group {
+	addr, ok := netip.AddrFromSlice(client)
+	if !ok || len(p.groups) == 0 {
+		return p.fallback
+	}
+	addr = addr.Unmap()
+
+	var mac net.HardwareAddr
+	if p.macs {
+		mac, _ = p.leases.MAC(client)
+	}
+
+	best, bestRank := p.fallback, 0
+	for _, g := range p.groups {
+		if g.Schedule != nil && !g.Schedule.Active(now) {
+			continue
+		}
+		for _, c := range g.Clients {
+			if rank := c.rank(addr, mac); rank > bestRank {
+				best, bestRank = g, rank
+			}
+		}
+	}
+	return best
+}
+
+// Filter returns the lists among lists enforced for client now, ok is
+// false when none is or when the group of client allows name.
+func (p *Policy) Filter(client net.IP, name string, lists []string) ([]string, bool) {
+	now := p.now()
+
+	g := p.group(client, now)
+	if g != nil && g.allow != nil && g.allow.Blocked(name) {
+		return nil, false
+	}
+
+	if (g != nil && g.lists != nil) || len(p.schedules) > 0 {
+		lists = slices.DeleteFunc(slices.Clone(lists), func(list string) bool {
+			if g != nil && g.lists != nil && !g.lists[list] {
+				return true
+			}
+			s := p.schedules[list]
+			return s != nil && !s.Active(now)
+		})
+	}
+	return lists, len(lists) > 0
+}
@@ -0,0 +1,186 @@
+package policy_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/app/blocklist"
+	"go.pixelfactory.io/needle/internal/app/policy"
+	"go.pixelfactory.io/needle/internal/app/schedule"
+)
+
+// leases maps client addresses to MAC addresses.
+type leases map[string]string
+
+func (l leases) MAC(ip net.IP) (net.HardwareAddr, bool) {
+	mac, ok := l[ip.String()]
+	if !ok {
+		return nil, false
+	}
+	hw, err := net.ParseMAC(mac)
+	return hw, err == nil
+}
+
+func clients(t *testing.T, s ...string) []policy.Client {
+	t.Helper()
+
+	result := make([]policy.Client, 0, len(s))
+	for _, c := range s {
+		client, err := policy.ParseClient(c)
+		require.NoError(t, err)
+		result = append(result, client)
+	}
+	return result
+}
+
+func Test_ParseClient(t *testing.T) {
+	is := require.New(t)
+
+	for s, want := range map[string]string{
+		"192.168.1.10":        "192.168.1.10",
+		"::ffff:192.168.1.10": "192.168.1.10",
+		"192.168.1.7/28":      "192.168.1.0/28",
+		"fd00::/64":           "fd00::/64",
+		"AA:BB:CC:DD:EE:FF":   "aa:bb:cc:dd:ee:ff",
+	} {
+		client, err := policy.ParseClient(s)
+		is.NoError(err, s)
+		is.Equal(want, client.String())
+	}
+
+	_, err := policy.ParseClient("tablet")
+	is.Error(err)
+}
+
+func Test_Policy(t *testing.T) {
+	is := require.New(t)
+
+	p, err := policy.New(
+		policy.WithGroups(
+			policy.Group{
+				Name:    "kids",
+				Clients: clients(t, "192.168.1.0/28", "aa:bb:cc:dd:ee:01"),
+				Allow:   []blocklist.Rule{{Domain: "school.needle.local", Subdomains: true}},
+			},
+			policy.Group{Name: "tv", Clients: clients(t, "192.168.1.5"), Lists: []string{"ads"}},
+			policy.Group{Name: "laptops", Clients: clients(t, "192.168.1.0/24"), Lists: []string{}},
+			policy.Group{Name: policy.DefaultGroup, Lists: []string{"ads", "malware"}},
+		),
+		policy.WithLeases(leases{"192.168.1.100": "aa:bb:cc:dd:ee:01"}),
+	)
+	is.NoError(err)
+
+	for client, group := range map[string]string{
+		"192.168.1.2":        "kids",
+		"::ffff:192.168.1.2": "kids",
+		"192.168.1.5":        "tv",
+		"192.168.1.100":      "kids",
+		"192.168.1.200":      "laptops",
+		"10.0.0.1":           policy.DefaultGroup,
+	} {
+		is.Equal(group, p.Group(net.ParseIP(client)), client)
+	}
+
+	all := []string{"ads", "social", "malware"}
+
+	lists, ok := p.Filter(net.ParseIP("192.168.1.2"), "ads.needle.local", all)
+	is.True(ok)
+	is.Equal(all, lists)
+
+	_, ok = p.Filter(net.ParseIP("192.168.1.2"), "www.school.needle.local", all)
+	is.False(ok)
+
+	lists, ok = p.Filter(net.ParseIP("192.168.1.5"), "ads.needle.local", all)
+	is.True(ok)
+	is.Equal([]string{"ads"}, lists)
+	is.Equal([]string{"ads", "social", "malware"}, all)
+
+	_, ok = p.Filter(net.ParseIP("192.168.1.5"), "social.needle.local", []string{"social"})
+	is.False(ok)
+
+	_, ok = p.Filter(net.ParseIP("192.168.1.200"), "ads.needle.local", all)
+	is.False(ok)
+
+	lists, ok = p.Filter(net.ParseIP("10.0.0.1"), "ads.needle.local", all)
+	is.True(ok)
+	is.Equal([]string{"ads", "malware"}, lists)
+
+	groups := p.Groups()
+	is.Len(groups, 4)
+	is.Equal(policy.DefaultGroup, groups[3].Name)
+
+	t.Run("Without groups", func(_ *testing.T) {
+		p, err := policy.New()
+		is.NoError(err)
+		is.Empty(p.Group(net.ParseIP("192.168.1.2")))
+
+		lists, ok := p.Filter(net.ParseIP("192.168.1.2"), "ads.needle.local", all)
+		is.True(ok)
+		is.Equal(all, lists)
+	})
+
+	t.Run("Invalid groups", func(_ *testing.T) {
+		for _, groups := range [][]policy.Group{
+			{{Name: "kids"}, {Name: "kids"}},
+			{{Name: ""}},
+			{{Name: policy.DefaultGroup, Clients: clients(t, "10.0.0.1")}},
+			{{Name: "kids", Clients: clients(t, "aa:bb:cc:dd:ee:01")}},
+			{{Name: policy.DefaultGroup, Schedule: &schedule.Schedule{Name: "nights"}}},
+		} {
+			_, err := policy.New(policy.WithGroups(groups...))
+			is.Error(err)
+		}
+	})
+}
+
+func Test_PolicySchedules(t *testing.T) {
+	is := require.New(t)
+
+	nights, err := schedule.Parse("school-nights", time.UTC, "sun-thu 21:00-07:00")
+	is.NoError(err)
+	weekends, err := schedule.Parse("weekends", time.UTC, "sat-sun")
+	is.NoError(err)
+
+	// 2026-10-19 is a Monday
+	now := time.Date(2026, time.October, 19, 22, 0, 0, 0, time.UTC)
+	p, err := policy.New(
+		policy.WithGroups(
+			policy.Group{
+				Name:     "kids",
+				Clients:  clients(t, "192.168.1.2"),
+				Lists:    []string{"ads", "social"},
+				Schedule: nights,
+			},
+			policy.Group{Name: policy.DefaultGroup, Lists: []string{"ads", "games"}},
+		),
+		policy.WithListSchedules(map[string]*schedule.Schedule{"games": weekends}),
+		policy.WithClock(func() time.Time { return now }),
+	)
+	is.NoError(err)
+
+	kid := net.ParseIP("192.168.1.2")
+	all := []string{"ads", "social", "games"}
+
+	is.Equal("kids", p.Group(kid))
+	lists, ok := p.Filter(kid, "social.needle.local", all)
+	is.True(ok)
+	is.Equal([]string{"ads", "social"}, lists)
+
+	// out of the school nights, kids are in the default group
+	now = time.Date(2026, time.October, 20, 8, 0, 0, 0, time.UTC)
+	is.Equal(policy.DefaultGroup, p.Group(kid))
+	lists, ok = p.Filter(kid, "games.needle.local", all)
+	is.True(ok)
+	is.Equal([]string{"ads"}, lists)
+
+	// games are only blocked on weekends
+	now = time.Date(2026, time.October, 24, 8, 0, 0, 0, time.UTC)
+	lists, ok = p.Filter(kid, "games.needle.local", all)
+	is.True(ok)
+	is.Equal([]string{"ads", "games"}, lists)
+
+	_, ok = p.Filter(kid, "social.needle.local", []string{"social"})
+	is.False(ok)
+}
@@ -0,0 +1,265 @@
+// Package schedule tells whether weekly windows of time, such as school
+// nights from 21:00 to 07:00, are active.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// day is the length of a window covering a whole day.
+const day = 24 * time.Hour
+
+// searchDays bounds the search of the next change, every window starts and
+// ends within a week.
+const searchDays = 8
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Window is active on some days of the week between two times of the day,
+// until the next day when it ends before it starts.
+type Window struct {
+	// Days are indexed by time.Weekday, the window starts on them.
+	Days  [7]bool
+	Start time.Duration
+	End   time.Duration
+}
+
+// ParseWindow parses days followed by times, such as "mon-fri 08:00-18:00",
+// "sun-thu 21:00-07:00" or "sat,sun". Days are every day when omitted or
+// "daily" and times the whole day.
+func ParseWindow(s string) (Window, error) {
+	var w Window
+
+	f := strings.Fields(strings.ToLower(s))
+	if len(f) == 0 || len(f) > 2 {
+		return w, fmt.Errorf("schedule.ParseWindow: %q must be written days hh:mm-hh:mm", s)
+	}
+
+	days, times := f[0], ""
+	if len(f) == 2 {
+		times = f[1]
+	} else if strings.Contains(days, ":") {
+		days, times = "", days
+	}
+
+	if err := w.parseDays(days); err != nil {
+		return w, fmt.Errorf("schedule.ParseWindow: %q: %w", s, err)
+	}
+	if err := w.parseTimes(times); err != nil {
+		return w, fmt.Errorf("schedule.ParseWindow: %q: %w", s, err)
+	}
+	return w, nil
+}
+
+// parseDays parses comma separated days and ranges of days, such as
+// "mon-fri" or "fri-mon,wed".
+func (w *Window) parseDays(s string) error {
+	if s == "" || s == "daily" {
+		w.Days = [7]bool{true, true, true, true, true, true, true}
+		return nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		if !isRange {
+			to = from
+		}
+
+		first, last := slices.Index(weekdays, from), slices.Index(weekdays, to)
+		if first < 0 || last < 0 {
+			return fmt.Errorf("%q is not a day, one of %s", part, strings.Join(weekdays, ", "))
+		}
+		for d := first; ; d = (d + 1) % len(weekdays) {
+			w.Days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// parseTimes parses "hh:mm-hh:mm", 24:00 ending a window at midnight.
+func (w *Window) parseTimes(s string) error {
+	if s == "" {
+		w.Start, w.End = 0, day
+		return nil
+	}
+
+	start, end, ok := strings.Cut(s, "-")
+	if !ok {
+		return errors.New("times must be written hh:mm-hh:mm")
+	}
+
+	var err error
+	if w.Start, err = parseTime(start); err != nil {
+		return err
+	}
+	if w.End, err = parseTime(end); err != nil {
+		return err
+	}
+	switch {
+	case w.Start == day:
+		return errors.New("windows cannot start at 24:00")
+	case w.Start == w.End:
+		return errors.New("windows cannot end when they start")
+	}
+	return nil
+}
+
+// parseTime parses "hh:mm" into the time since midnight.
+func parseTime(s string) (time.Duration, error) {
+	hh, mm, ok := strings.Cut(s, ":")
+	h, errH := strconv.Atoi(hh)
+	m, errM := strconv.Atoi(mm)
+	if !ok || errH != nil || errM != nil || len(mm) != 2 || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m > 0) {
+		return 0, fmt.Errorf("%q is not a time of the day, hh:mm", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// String returns the window in the syntax of ParseWindow, runs of days as
+// ranges.
+func (w Window) String() string {
+	var days []string
+	if w.Days != [7]bool{true, true, true, true, true, true, true} {
+		for d := 0; d < len(weekdays); d++ {
+			if !w.Days[d] {
+				continue
+			}
+			last := d
+			for last+1 < len(weekdays) && w.Days[last+1] {
+				last++
+			}
+			switch {
+			case last == d:
+				days = append(days, weekdays[d])
+			default:
+				days = append(days, weekdays[d]+"-"+weekdays[last])
+			}
+			d = last
+		}
+	}
+
+	times := ""
+	if w.Start != 0 || w.End != day {
+		times = formatTime(w.Start) + "-" + formatTime(w.End)
+	}
+
+	if days == nil && times == "" {
+		return "daily"
+	}
+	return strings.TrimSpace(strings.Join(days, ",") + " " + times)
+}
+
+func formatTime(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// active reports whether the window is active at offset since the midnight
+// of weekday.
+func (w Window) active(weekday time.Weekday, offset time.Duration) bool {
+	if w.Start < w.End {
+		return w.Days[weekday] && offset >= w.Start && offset < w.End
+	}
+
+	// the window ends the next day
+	yesterday := (weekday + 6) % 7
+	return (w.Days[weekday] && offset >= w.Start) || (w.Days[yesterday] && offset < w.End)
+}
+
+// Schedule is active during any of its windows, in its time zone.
+type Schedule struct {
+	Name     string
+	Windows  []Window
+	Location *time.Location
+}
+
+// Parse returns the schedule of windows in loc, parsed with ParseWindow.
+func Parse(name string, loc *time.Location, windows ...string) (*Schedule, error) {
+	if name == "" {
+		return nil, errors.New("schedule.Parse: schedule without name")
+	}
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("schedule.Parse: schedule %q without window", name)
+	}
+
+	s := &Schedule{Name: name, Location: loc}
+	for _, window := range windows {
+		w, err := ParseWindow(window)
+		if err != nil {
+			return nil, err
+		}
+		s.Windows = append(s.Windows, w)
+	}
+	return s, nil
+}
+
+// Active reports whether any window is active at t.
+func (s *Schedule) Active(t time.Time) bool {
+	t = t.In(s.Location)
+
+	// the wall clock time, which daylight saving time changes do not shift
+	h, m, sec := t.Clock()
+	offset := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec)*time.Second
+
+	for _, w := range s.Windows {
+		if w.active(t.Weekday(), offset) {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns when the schedule becomes active or inactive after t, zero
+// when it never changes.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.In(s.Location)
+	active := s.Active(t)
+
+	// the schedule can only change when a window starts or ends, windows
+	// of yesterday can end today
+	var changes []time.Time
+	year, month, date := t.Date()
+	for i := -1; i < searchDays; i++ {
+		midnight := time.Date(year, month, date+i, 0, 0, 0, 0, s.Location)
+		for _, w := range s.Windows {
+			if !w.Days[midnight.Weekday()] {
+				continue
+			}
+			end := w.End
+			if w.Start >= w.End {
+				end += day
+			}
+			changes = append(changes, at(midnight, w.Start), at(midnight, end))
+		}
+	}
+	slices.SortFunc(changes, time.Time.Compare)
+
+	for _, change := range changes {
+		if change.After(t) && s.Active(change) != active {
+			return change
+		}
+	}
+	return time.Time{}
+}
+
+// at returns the wall clock time offset after midnight.
+func at(midnight time.Time, offset time.Duration) time.Time {
+	y, m, d := midnight.Date()
+	return time.Date(y, m, d, 0, int(offset.Minutes()), 0, 0, midnight.Location())
+}
+
+// String returns the windows of the schedule, separated by commas.
+func (s *Schedule) String() string {
+	windows := make([]string, len(s.Windows))
+	for i, w := range s.Windows {
+		windows[i] = w.String()
+	}
+	return strings.Join(windows, ", ")
+}
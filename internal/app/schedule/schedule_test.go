@@ -0,0 +1,93 @@
+package schedule_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/app/schedule"
+)
+
+func Test_ParseWindow(t *testing.T) {
+	is := require.New(t)
+
+	for s, want := range map[string]string{
+		"mon-fri 08:00-18:00":    "mon-fri 08:00-18:00",
+		"SUN-THU 21:00-07:00":    "sun-thu 21:00-07:00",
+		"fri-mon":                "sun-mon,fri-sat",
+		"sat,sun":                "sun,sat",
+		"22:00-24:00":            "22:00-24:00",
+		"mon,wed 00:00-24:00":    "mon,wed",
+		"tue-wed,fri 9:30-12:00": "tue-wed,fri 09:30-12:00",
+		"sun-sat":                "daily",
+		"daily 21:00-07:00":      "21:00-07:00",
+	} {
+		w, err := schedule.ParseWindow(s)
+		is.NoError(err, s)
+		is.Equal(want, w.String(), s)
+	}
+
+	for _, s := range []string{
+		"", "weekdays", "mon-fri 8-18", "mon 08:00", "mon 24:00-08:00",
+		"mon 08:00-08:00", "mon 08:60-09:00", "mon 08:00-25:00", "mon 08:00-09:00 extra",
+	} {
+		_, err := schedule.ParseWindow(s)
+		is.Error(err, s)
+	}
+}
+
+func Test_Schedule(t *testing.T) {
+	is := require.New(t)
+
+	paris, err := time.LoadLocation("Europe/Paris")
+	is.NoError(err)
+
+	s, err := schedule.Parse("school-nights", paris, "sun-thu 21:00-07:00")
+	is.NoError(err)
+	is.Equal("sun-thu 21:00-07:00", s.String())
+
+	// 2026-10-18 is a Sunday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.October, day, hour, minute, 0, 0, paris)
+	}
+
+	for _, tc := range []struct {
+		time   time.Time
+		active bool
+		next   time.Time
+	}{
+		{at(18, 20, 59), false, at(18, 21, 0)},
+		{at(18, 21, 0), true, at(19, 7, 0)},
+		{at(19, 6, 59), true, at(19, 7, 0)},
+		{at(19, 7, 0), false, at(19, 21, 0)},
+		// Friday and Saturday nights are not school nights
+		{at(23, 23, 0), false, at(25, 21, 0)},
+		{at(24, 3, 0), false, at(25, 21, 0)},
+		// Thursday night ends on Friday
+		{at(23, 6, 0), true, at(23, 7, 0)},
+	} {
+		is.Equal(tc.active, s.Active(tc.time), tc.time)
+		is.True(tc.next.Equal(s.Next(tc.time)), "%s: next %s", tc.time, s.Next(tc.time))
+	}
+
+	// the same instant in UTC, two hours behind Paris in summer time
+	is.True(s.Active(time.Date(2026, time.October, 18, 19, 30, 0, 0, time.UTC)))
+
+	// daylight saving time ends on 2026-10-25, windows follow the wall clock
+	is.True(s.Next(at(25, 12, 0)).Equal(at(25, 21, 0)))
+	is.Equal(21, s.Next(at(25, 12, 0)).Hour())
+}
+
+func Test_ScheduleNeverChanges(t *testing.T) {
+	is := require.New(t)
+
+	always, err := schedule.Parse("always", time.UTC, "mon-fri", "sat-sun")
+	is.NoError(err)
+	is.True(always.Active(time.Now()))
+	is.True(always.Next(time.Now()).IsZero())
+
+	_, err = schedule.Parse("empty", time.UTC)
+	is.Error(err)
+	_, err = schedule.Parse("", time.UTC, "mon")
+	is.Error(err)
+}
@@ -0,0 +1,119 @@
+package boltdb
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+	"go.pixelfactory.io/needle/internal/app/pki"
+)
+
+const healthBucket = "health"
+
+// Backup writes a consistent snapshot of the database to w while it stays online.
+func Backup(client *storm.DB, w io.Writer) (int64, error) {
+	var n int64
+	err := client.Bolt.View(func(tx *bolt.Tx) error {
+		var err error
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	if err != nil {
+		return n, errors.Wrap(err, "boltdb.Backup")
+	}
+	return n, nil
+}
+
+// Count returns the number of stored certificates.
+func Count(client *storm.DB) (int, error) {
+	n, err := client.Count(&pki.InternalCert{})
+	if err != nil {
+		return 0, errors.Wrap(err, "boltdb.Count")
+	}
+	return n, nil
+}
+
+// Ping checks the database is readable and writable by writing then reading
+// back a timestamp in the health bucket.
+func Ping(client *storm.DB) error {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	if err := client.Set(healthBucket, "ping", now); err != nil {
+		return errors.Wrap(err, "boltdb.Ping")
+	}
+
+	var got string
+	if err := client.Get(healthBucket, "ping", &got); err != nil {
+		return errors.Wrap(err, "boltdb.Ping")
+	}
+
+	if got != now {
+		return errors.New("boltdb.Ping: read back a different value")
+	}
+
+	return nil
+}
+
+// Compact rewrites the database file at path, reclaiming free pages.
+// The database must not be opened by another process.
+func Compact(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return errors.Wrap(err, "boltdb.Compact")
+	}
+
+	src, err := bolt.Open(path, info.Mode(), &bolt.Options{ReadOnly: true})
+	if err != nil {
+		return errors.Wrap(err, "boltdb.Compact")
+	}
+	defer src.Close()
+
+	tmp := path + ".compact"
+	dst, err := bolt.Open(tmp, info.Mode(), nil)
+	if err != nil {
+		return errors.Wrap(err, "boltdb.Compact")
+	}
+
+	err = src.View(func(stx *bolt.Tx) error {
+		return dst.Update(func(dtx *bolt.Tx) error {
+			return stx.ForEach(func(name []byte, b *bolt.Bucket) error {
+				nb, err := dtx.CreateBucket(name)
+				if err != nil {
+					return err
+				}
+				return copyBucket(b, nb)
+			})
+		})
+	})
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return errors.Wrap(err, "boltdb.Compact")
+	}
+
+	return errors.Wrap(os.Rename(tmp, path), "boltdb.Compact")
+}
+
+// copyBucket recursively copies keys and nested buckets from src to dst.
+func copyBucket(src, dst *bolt.Bucket) error {
+	dst.FillPercent = 1.0
+	if err := dst.SetSequence(src.Sequence()); err != nil {
+		return err
+	}
+
+	return src.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return dst.Put(k, v)
+		}
+
+		nb, err := dst.CreateBucket(k)
+		if err != nil {
+			return err
+		}
+		return copyBucket(src.Bucket(k), nb)
+	})
+}
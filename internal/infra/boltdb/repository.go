@@ -2,6 +2,8 @@
 package boltdb
 
 import (
+	"context"
+
 	"github.com/asdine/storm/v3"
 	"github.com/pkg/errors"
 	"go.pixelfactory.io/needle/internal/app/pki"
@@ -18,8 +20,20 @@ func New(client *storm.DB) pki.Repository {
 	}
 }
 
+// Delete certificate in data/cache.db.
+func (br *boltRepository) Delete(_ context.Context, name string) error {
+	err := br.client.DeleteStruct(&pki.InternalCert{Name: name})
+	if errors.Is(err, storm.ErrNotFound) {
+		return errors.Wrap(pki.ErrCertificateNotFound, "repository.BoltRepository.Delete")
+	}
+	if err != nil {
+		return errors.Wrap(err, "repository.BoltRepository.Delete")
+	}
+	return nil
+}
+
 // Get certificate in data/cache.db.
-func (br *boltRepository) Get(name string) (*pki.InternalCert, error) {
+func (br *boltRepository) Get(_ context.Context, name string) (*pki.InternalCert, error) {
 	var cert pki.InternalCert
 	err := br.client.One("Name", name, &cert)
 	if errors.Is(err, storm.ErrNotFound) {
@@ -31,8 +45,18 @@ func (br *boltRepository) Get(name string) (*pki.InternalCert, error) {
 	return &cert, nil
 }
 
+// List all certificates in data/cache.db.
+func (br *boltRepository) List(_ context.Context) ([]*pki.InternalCert, error) {
+	var certs []*pki.InternalCert
+	err := br.client.All(&certs)
+	if err != nil {
+		return nil, errors.Wrap(err, "repository.BoltRepository.List")
+	}
+	return certs, nil
+}
+
 // Store certificate in data/cache.db.
-func (br *boltRepository) Store(certificate *pki.InternalCert) error {
+func (br *boltRepository) Store(_ context.Context, certificate *pki.InternalCert) error {
 	err := br.client.Save(certificate)
 	if err != nil {
 		return errors.Wrap(err, "repository.BoltRepository.store")
@@ -0,0 +1,91 @@
+package boltdb_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/asdine/storm/v3"
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/app/pki"
+	"go.pixelfactory.io/needle/internal/infra/boltdb"
+	"go.pixelfactory.io/needle/testdata"
+)
+
+func newClient(t *testing.T, path string) *storm.DB {
+	t.Helper()
+
+	client, err := storm.Open(path)
+	require.NoError(t, err)
+	return client
+}
+
+func Test_Repository(t *testing.T) {
+	is := require.New(t)
+
+	_, testCert := testdata.Setup(t)
+	client := newClient(t, filepath.Join(t.TempDir(), "cache.db"))
+	defer client.Close()
+
+	repo := boltdb.New(client)
+	is.Implements((*pki.Repository)(nil), repo)
+
+	_, err := repo.Get(context.Background(), "test.needle.local")
+	is.ErrorIs(err, pki.ErrCertificateNotFound)
+
+	is.NoError(repo.Store(context.Background(), testCert))
+
+	cert, err := repo.Get(context.Background(), "test.needle.local")
+	is.NoError(err)
+	is.Equal(testCert, cert)
+
+	certs, err := repo.List(context.Background())
+	is.NoError(err)
+	is.Equal([]*pki.InternalCert{testCert}, certs)
+
+	count, err := boltdb.Count(client)
+	is.NoError(err)
+	is.Equal(1, count)
+
+	is.NoError(repo.Delete(context.Background(), "test.needle.local"))
+	is.ErrorIs(repo.Delete(context.Background(), "test.needle.local"), pki.ErrCertificateNotFound)
+}
+
+func Test_BackupAndCompact(t *testing.T) {
+	is := require.New(t)
+
+	_, testCert := testdata.Setup(t)
+	dir := t.TempDir()
+	client := newClient(t, filepath.Join(dir, "cache.db"))
+	is.NoError(boltdb.New(client).Store(context.Background(), testCert))
+
+	// backup while the database is open
+	var buf bytes.Buffer
+	n, err := boltdb.Backup(client, &buf)
+	is.NoError(err)
+	is.Equal(int64(buf.Len()), n)
+	is.NoError(client.Close())
+
+	backup := filepath.Join(dir, "backup.db")
+	is.NoError(os.WriteFile(backup, buf.Bytes(), 0o600))
+	is.NoError(boltdb.Compact(backup))
+
+	client = newClient(t, backup)
+	defer client.Close()
+
+	cert, err := boltdb.New(client).Get(context.Background(), "test.needle.local")
+	is.NoError(err)
+	is.Equal(testCert, cert)
+}
+
+func Test_Ping(t *testing.T) {
+	is := require.New(t)
+
+	client := newClient(t, filepath.Join(t.TempDir(), "cache.db"))
+	is.NoError(boltdb.Ping(client))
+
+	is.NoError(client.Close())
+	is.Error(boltdb.Ping(client))
+}
@@ -0,0 +1,118 @@
+package coredns
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.pixelfactory.io/pkg/observability/log"
+	"go.pixelfactory.io/pkg/observability/log/fields"
+)
+
+// TemporaryAllows removes names from the hosts file for a while, they are
+// blocked again once expired or when needle shuts down.
+type TemporaryAllows struct {
+	logger log.Logger
+	hosts  *Hosts
+	tick   time.Duration
+
+	mu        sync.Mutex
+	until     map[string]time.Time
+	addresses map[string]string // address of each allowed name in the hosts file
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewTemporaryAllows returns TemporaryAllows editing hosts.
+func NewTemporaryAllows(logger log.Logger, hosts *Hosts) *TemporaryAllows {
+	return &TemporaryAllows{
+		logger:    logger,
+		hosts:     hosts,
+		tick:      time.Second,
+		until:     map[string]time.Time{},
+		addresses: map[string]string{},
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Allow resolves name with upstreams for d, names which are not in the
+// hosts file are left untouched.
+func (t *TemporaryAllows) Allow(ctx context.Context, name string, d time.Duration) error {
+	name = normalizeHost(name)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.until[name]; !ok {
+		address, blocked, err := t.hosts.Lookup(ctx, name)
+		if err != nil {
+			return errors.Wrap(err, "coredns.TemporaryAllows.Allow")
+		}
+		if !blocked {
+			return nil
+		}
+
+		if err := t.hosts.Allow(ctx, name); err != nil {
+			return errors.Wrap(err, "coredns.TemporaryAllows.Allow")
+		}
+		t.addresses[name] = address
+	}
+
+	t.until[name] = time.Now().Add(d)
+	t.logger.Info("Temporarily allowed domain", fields.String("domain", name), fields.String("duration", d.String()))
+	return nil
+}
+
+// Serve blocks expired names until Shutdown.
+func (t *TemporaryAllows) Serve() error {
+	defer close(t.done)
+
+	ticker := time.NewTicker(t.tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.stop:
+			return nil
+		case now := <-ticker.C:
+			t.expire(now)
+		}
+	}
+}
+
+// Shutdown stops Serve and blocks every allowed name again.
+func (t *TemporaryAllows) Shutdown(ctx context.Context) error {
+	close(t.stop)
+
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	t.expire(time.Time{})
+	return nil
+}
+
+// expire blocks names allowed until before now, all of them when now is zero.
+func (t *TemporaryAllows) expire(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for name, until := range t.until {
+		if !now.IsZero() && now.Before(until) {
+			continue
+		}
+
+		if err := t.hosts.BlockAddress(context.Background(), name, t.addresses[name]); err != nil {
+			t.logger.Error("Unable to block domain again", fields.String("domain", name), fields.Error(err))
+			continue
+		}
+		delete(t.until, name)
+		delete(t.addresses, name)
+		t.logger.Info("Temporary allow expired", fields.String("domain", name))
+	}
+}
@@ -0,0 +1,54 @@
+package coredns_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/infra/coredns"
+	"go.pixelfactory.io/pkg/observability/log"
+)
+
+func Test_TemporaryAllows(t *testing.T) {
+	is := require.New(t)
+
+	ctx := context.Background()
+	hostsFile := filepath.Join(t.TempDir(), "hosts")
+	is.NoError(os.WriteFile(hostsFile, []byte("192.168.1.2 ads.needle.local\n192.168.1.3 pixel.needle.local\n"), 0o600))
+
+	hosts := coredns.NewHosts(hostsFile)
+	allows := coredns.NewTemporaryAllows(log.New(), hosts)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- allows.Serve()
+	}()
+
+	blocked := func(name string) bool {
+		_, ok, err := hosts.Lookup(ctx, name)
+		is.NoError(err)
+		return ok
+	}
+
+	is.NoError(allows.Allow(ctx, "pixel.needle.local", 10*time.Millisecond))
+	is.NoError(allows.Allow(ctx, "ads.needle.local", time.Hour))
+	is.NoError(allows.Allow(ctx, "unknown.needle.local", time.Hour))
+	is.False(blocked("pixel.needle.local"))
+	is.False(blocked("ads.needle.local"))
+
+	// expired names are blocked again with their address
+	is.Eventually(func() bool { return blocked("pixel.needle.local") }, 3*time.Second, 50*time.Millisecond)
+	address, _, err := hosts.Lookup(ctx, "pixel.needle.local")
+	is.NoError(err)
+	is.Equal("192.168.1.3", address)
+	is.False(blocked("ads.needle.local"))
+
+	// shutdown blocks every allowed name
+	is.NoError(allows.Shutdown(ctx))
+	is.NoError(<-done)
+	is.True(blocked("ads.needle.local"))
+	is.False(blocked("unknown.needle.local"))
+}
@@ -0,0 +1,254 @@
+package coredns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+)
+
+// blockDirective is the Corefile directive answering blocked names.
+const blockDirective = "needle"
+
+// blockTTL is short so that clients resolve allowed names soon after.
+const blockTTL = 10
+
+// Mode is how queries for blocked names are answered.
+type Mode string
+
+const (
+	// ModeAddress answers A and AAAA queries with the needle addresses,
+	// so that needle serves the blocked requests.
+	ModeAddress Mode = "address"
+	// ModeNull answers A queries with 0.0.0.0 and AAAA queries with ::.
+	ModeNull Mode = "null"
+	// ModeNXDomain answers that the name does not exist.
+	ModeNXDomain Mode = "nxdomain"
+	// ModeNoData answers that the name has no record of the queried type.
+	ModeNoData Mode = "nodata"
+	// ModeRefused refuses the query.
+	ModeRefused Mode = "refused"
+)
+
+// Modes lists the valid modes.
+var Modes = []Mode{ModeAddress, ModeNull, ModeNXDomain, ModeNoData, ModeRefused}
+
+// ParseMode parses a Mode, case insensitively.
+func ParseMode(s string) (Mode, error) {
+	m := Mode(strings.ToLower(strings.TrimSpace(s)))
+	if !slices.Contains(Modes, m) {
+		return "", fmt.Errorf("coredns.ParseMode: unknown mode %q", s)
+	}
+	return m, nil
+}
+
+// Blocker decides which names are blocked.
+type Blocker interface {
+	// Match returns the names of the lists blocking name for client, ok
+	// is false when it is not blocked.
+	Match(client net.IP, name string) (lists []string, ok bool)
+}
+
+// blocking is the Blocker of a server and how blocked names are answered.
+type blocking struct {
+	blocker   Blocker
+	addresses func() []net.IP
+	mode      Mode
+	// listModes overrides mode for the names blocked by a list
+	listModes map[string]Mode
+	// cnameCheck blocks names answered with a CNAME of a blocked name
+	cnameCheck bool
+}
+
+// modeOf returns the mode of the first list with one, mode otherwise.
+func (b blocking) modeOf(lists []string) Mode {
+	for _, list := range lists {
+		if m, ok := b.listModes[list]; ok {
+			return m
+		}
+	}
+	return b.mode
+}
+
+// blockers holds the blocking of each server by port, plugins are
+// configured from the Corefile and cannot be handed one directly.
+var blockers sync.Map
+
+func init() {
+	plugin.Register(blockDirective, setupBlock)
+
+	// answer before the cache, which would keep blocked answers of allowed
+	// names, and after the query log, which is inserted after log whichever
+	// init runs first
+	i := slices.Index(dnsserver.Directives, queryLogDirective)
+	if i < 0 {
+		i = slices.Index(dnsserver.Directives, "log")
+	}
+	dnsserver.Directives = slices.Insert(dnsserver.Directives, i+1, blockDirective)
+}
+
+func setupBlock(c *caddy.Controller) error {
+	config := dnsserver.GetConfig(c)
+	c.Next() // directive name
+	if c.NextArg() {
+		return plugin.Error(blockDirective, c.ArgErr())
+	}
+
+	b, ok := blockers.Load(config.Port)
+	if !ok {
+		return plugin.Error(blockDirective, c.Errf("no blocker for port %s", config.Port))
+	}
+
+	config.AddPlugin(func(next plugin.Handler) plugin.Handler {
+		b, _ := b.(blocking)
+		return block{Next: next, blocking: b}
+	})
+	return nil
+}
+
+// block answers queries for blocked names, of any type so that AAAA
+// queries do not reach upstreams.
+type block struct {
+	Next plugin.Handler
+	blocking
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (b block) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	client := net.ParseIP(state.IP())
+	lists, ok := b.blocker.Match(client, state.Name())
+	if !ok {
+		if b.cnameCheck {
+			chain, _ := ctx.Value(cnamesKey{}).(*[]string)
+			w = &cnameWriter{ResponseWriter: w, blocking: b.blocking, state: state, client: client, chain: chain}
+		}
+		return plugin.NextOrFailure(b.Name(), b.Next, ctx, w, r)
+	}
+
+	// the response is written, whatever its rcode
+	_ = w.WriteMsg(b.reply(state, lists)) // the client is gone
+	return dns.RcodeSuccess, nil
+}
+
+// reply returns the answer to state for a name blocked by lists.
+func (b blocking) reply(state request.Request, lists []string) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetReply(state.Req)
+	m.Authoritative = true
+
+	mode := b.modeOf(lists)
+	addresses := b.addresses()
+	if mode == ModeNull || (mode == ModeAddress && len(addresses) == 0) {
+		mode, addresses = ModeAddress, []net.IP{net.IPv4zero, net.IPv6zero}
+	}
+
+	switch mode {
+	case ModeRefused:
+		m.Rcode = dns.RcodeRefused
+		m.Authoritative = false
+	case ModeNXDomain:
+		m.Rcode = dns.RcodeNameError
+	case ModeAddress:
+		m.Answer = answers(state, addresses)
+	}
+
+	// negative answers are cached for the SOA minimum TTL
+	if m.Rcode == dns.RcodeNameError || (m.Rcode == dns.RcodeSuccess && len(m.Answer) == 0) {
+		m.Ns = []dns.RR{soa(state.QName())}
+	}
+	return m
+}
+
+// cnameWriter blocks responses whose CNAME chain leads to a blocked name,
+// so that first-party names aliasing trackers are blocked too.
+type cnameWriter struct {
+	dns.ResponseWriter
+	blocking
+	state  request.Request
+	client net.IP
+	// chain receives the CNAMEs of a blocked response, for the query log
+	chain *[]string
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *cnameWriter) WriteMsg(res *dns.Msg) error {
+	chain, lists, ok := w.uncloak(res)
+	if !ok {
+		return w.ResponseWriter.WriteMsg(res)
+	}
+
+	if w.chain != nil {
+		*w.chain = chain
+	}
+	return w.ResponseWriter.WriteMsg(w.reply(w.state, lists))
+}
+
+// uncloak follows the CNAMEs of res from the queried name, chain ends with
+// the first blocked one and lists are the lists blocking it. ok is false
+// when no CNAME is blocked.
+func (w *cnameWriter) uncloak(res *dns.Msg) (chain []string, lists []string, ok bool) {
+	name := w.state.Name()
+	// a chain has at most one CNAME per answer, which also stops loops
+	for range res.Answer {
+		target := ""
+		for _, rr := range res.Answer {
+			if cname, isCNAME := rr.(*dns.CNAME); isCNAME && strings.EqualFold(cname.Hdr.Name, name) {
+				target = strings.ToLower(dns.Fqdn(cname.Target))
+				break
+			}
+		}
+		if target == "" {
+			return nil, nil, false
+		}
+
+		chain = append(chain, strings.TrimSuffix(target, "."))
+		if lists, ok = w.blocker.Match(w.client, target); ok {
+			return chain, lists, true
+		}
+		name = target
+	}
+	return nil, nil, false
+}
+
+// answers returns the A or AAAA records of addresses answering state.
+func answers(state request.Request, addresses []net.IP) []dns.RR {
+	var rrs []dns.RR
+	hdr := dns.RR_Header{Name: state.QName(), Class: dns.ClassINET, Ttl: blockTTL}
+	for _, ip := range addresses {
+		switch {
+		case state.QType() == dns.TypeA && ip.To4() != nil:
+			hdr.Rrtype = dns.TypeA
+			rrs = append(rrs, &dns.A{Hdr: hdr, A: ip.To4()})
+		case state.QType() == dns.TypeAAAA && ip.To4() == nil:
+			hdr.Rrtype = dns.TypeAAAA
+			rrs = append(rrs, &dns.AAAA{Hdr: hdr, AAAA: ip})
+		}
+	}
+	return rrs
+}
+
+// soa returns the SOA record of negative answers for name.
+func soa(name string) dns.RR {
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: name, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: blockTTL},
+		Ns:      "needle.",
+		Mbox:    "hostmaster.needle.",
+		Serial:  1,
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  86400,
+		Minttl:  blockTTL,
+	}
+}
+
+// Name implements the Handler interface.
+func (b block) Name() string { return blockDirective }
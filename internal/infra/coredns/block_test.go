@@ -0,0 +1,256 @@
+package coredns_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/infra/coredns"
+	"go.pixelfactory.io/pkg/observability/log"
+)
+
+// blocker blocks names ending with .needle.local for loopback clients, the
+// list blocking them is their first label.
+type blocker struct{}
+
+func (blocker) Match(client net.IP, name string) ([]string, bool) {
+	if !client.IsLoopback() || !strings.HasSuffix(name, ".needle.local.") || strings.HasPrefix(name, "ads.") {
+		return nil, false
+	}
+	list, _, _ := strings.Cut(name, ".")
+	return []string{list}, true
+}
+
+// exchange sends a qtype query for name to addr.
+func exchange(t *testing.T, addr, name string, qtype uint16) *dns.Msg {
+	t.Helper()
+
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+
+	var in *dns.Msg
+	var err error
+	for i := 0; i < 20; i++ {
+		in, err = dns.Exchange(m, addr)
+		if err == nil {
+			return in
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	require.NoError(t, err)
+	return nil
+}
+
+func Test_Block(t *testing.T) {
+	is := require.New(t)
+
+	hostsFile := filepath.Join(t.TempDir(), "hosts")
+	is.NoError(os.WriteFile(hostsFile, []byte("10.0.0.1 ads.needle.local\n"), 0o600))
+
+	srv := coredns.NewCoreDNSServer(
+		coredns.WithLogger(log.New()),
+		coredns.WithPort(15354),
+		coredns.WithHostsFile(hostsFile),
+		coredns.WithUpstreams([]string{"127.0.0.1:1"}),
+		coredns.WithCoreFile(""),
+		coredns.WithMetricsAddr("127.0.0.1:19154"),
+		coredns.WithBlocker(blocker{}),
+		coredns.WithBlockAddresses(func() []net.IP {
+			return []net.IP{net.ParseIP("10.0.0.9"), net.ParseIP("fd00::9")}
+		}),
+		coredns.WithBlockMode(coredns.ModeAddress, map[string]coredns.Mode{
+			"malware":   coredns.ModeNXDomain,
+			"social":    coredns.ModeNoData,
+			"adult":     coredns.ModeRefused,
+			"telemetry": coredns.ModeNull,
+		}),
+	)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Run()
+	}()
+
+	in := exchange(t, "127.0.0.1:15354", "Tracker.needle.local", dns.TypeA)
+	is.Equal(dns.RcodeSuccess, in.Rcode)
+	is.Len(in.Answer, 1)
+	is.Equal("10.0.0.9", in.Answer[0].(*dns.A).A.String())
+	is.Equal(uint32(10), in.Answer[0].Header().Ttl)
+
+	in = exchange(t, "127.0.0.1:15354", "tracker.needle.local", dns.TypeAAAA)
+	is.Len(in.Answer, 1)
+	is.Equal("fd00::9", in.Answer[0].(*dns.AAAA).AAAA.String())
+
+	in = exchange(t, "127.0.0.1:15354", "tracker.needle.local", dns.TypeTXT)
+	is.Equal(dns.RcodeSuccess, in.Rcode)
+	is.Empty(in.Answer)
+	is.Len(in.Ns, 1)
+
+	t.Run("List modes", func(_ *testing.T) {
+		in := exchange(t, "127.0.0.1:15354", "malware.needle.local", dns.TypeA)
+		is.Equal(dns.RcodeNameError, in.Rcode)
+		is.Len(in.Ns, 1)
+
+		in = exchange(t, "127.0.0.1:15354", "social.needle.local", dns.TypeAAAA)
+		is.Equal(dns.RcodeSuccess, in.Rcode)
+		is.Empty(in.Answer)
+
+		in = exchange(t, "127.0.0.1:15354", "adult.needle.local", dns.TypeA)
+		is.Equal(dns.RcodeRefused, in.Rcode)
+
+		in = exchange(t, "127.0.0.1:15354", "telemetry.needle.local", dns.TypeAAAA)
+		is.Len(in.Answer, 1)
+		is.Equal("::", in.Answer[0].(*dns.AAAA).AAAA.String())
+	})
+
+	// names which are not blocked fall through to the hosts file
+	in = exchange(t, "127.0.0.1:15354", "ads.needle.local", dns.TypeA)
+	is.Len(in.Answer, 1)
+	is.Equal("10.0.0.1", in.Answer[0].(*dns.A).A.String())
+
+	t.Run("Reload", func(_ *testing.T) {
+		is.NoError(srv.Reload(coredns.WithBlockAddresses(func() []net.IP {
+			return []net.IP{net.ParseIP("10.0.0.9")}
+		})))
+
+		in := exchange(t, "127.0.0.1:15354", "tracker.needle.local", dns.TypeAAAA)
+		is.Equal(dns.RcodeSuccess, in.Rcode)
+		is.Empty(in.Answer)
+
+		is.NoError(srv.Reload(coredns.WithBlockAddresses(func() []net.IP { return nil })))
+
+		in = exchange(t, "127.0.0.1:15354", "tracker.needle.local", dns.TypeA)
+		is.Len(in.Answer, 1)
+		is.Equal("0.0.0.0", in.Answer[0].(*dns.A).A.String())
+	})
+
+	t.Run("Parse mode", func(_ *testing.T) {
+		mode, err := coredns.ParseMode("NXDOMAIN")
+		is.NoError(err)
+		is.Equal(coredns.ModeNXDomain, mode)
+
+		_, err = coredns.ParseMode("drop")
+		is.Error(err)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	is.NoError(srv.Shutdown(ctx))
+	is.NoError(<-done)
+}
+
+// upstream answers CNAME chains of shop.example names.
+func upstream(t *testing.T, addr string) {
+	t.Helper()
+
+	chains := map[string][]string{
+		"metrics.shop.example.": {
+			"metrics.shop.example. 300 IN CNAME cdn.shop.example.",
+			"cdn.shop.example. 300 IN CNAME Tracker.needle.local.",
+			"tracker.needle.local. 300 IN A 192.0.2.1",
+		},
+		"pixel.shop.example.": {
+			"pixel.shop.example. 300 IN CNAME malware.needle.local.",
+			"malware.needle.local. 300 IN A 192.0.2.1",
+		},
+		"www.shop.example.": {
+			"www.shop.example. 300 IN CNAME www.cdn.example.",
+			"www.cdn.example. 300 IN A 192.0.2.2",
+		},
+	}
+
+	srv := &dns.Server{Addr: addr, Net: "udp", Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		for _, s := range chains[strings.ToLower(r.Question[0].Name)] {
+			rr, err := dns.NewRR(s)
+			if err == nil {
+				m.Answer = append(m.Answer, rr)
+			}
+		}
+		_ = w.WriteMsg(m)
+	})}
+
+	started := make(chan struct{})
+	srv.NotifyStartedFunc = func() { close(started) }
+	go func() {
+		_ = srv.ListenAndServe()
+	}()
+	<-started
+	t.Cleanup(func() {
+		_ = srv.Shutdown()
+	})
+}
+
+func Test_BlockCNAMEs(t *testing.T) {
+	is := require.New(t)
+
+	upstream(t, "127.0.0.1:15357")
+
+	recorded := &queries{}
+	srv := coredns.NewCoreDNSServer(
+		coredns.WithLogger(log.New()),
+		coredns.WithPort(15356),
+		coredns.WithHostsFile(filepath.Join(t.TempDir(), "hosts")),
+		coredns.WithUpstreams([]string{"127.0.0.1:15357"}),
+		coredns.WithCoreFile(""),
+		coredns.WithMetricsAddr("127.0.0.1:19156"),
+		coredns.WithQueryLog(recorded),
+		coredns.WithBlocker(blocker{}),
+		coredns.WithBlockAddresses(func() []net.IP { return []net.IP{net.ParseIP("10.0.0.9")} }),
+		coredns.WithBlockMode(coredns.ModeAddress, map[string]coredns.Mode{"malware": coredns.ModeNXDomain}),
+		coredns.WithCNAMECheck(true),
+	)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Run()
+	}()
+
+	// names aliasing a blocked name are answered like it
+	in := exchange(t, "127.0.0.1:15356", "metrics.shop.example", dns.TypeA)
+	is.Equal(dns.RcodeSuccess, in.Rcode)
+	is.Len(in.Answer, 1)
+	is.Equal("metrics.shop.example.", in.Answer[0].Header().Name)
+	is.Equal("10.0.0.9", in.Answer[0].(*dns.A).A.String())
+
+	in = exchange(t, "127.0.0.1:15356", "pixel.shop.example", dns.TypeA)
+	is.Equal(dns.RcodeNameError, in.Rcode)
+
+	in = exchange(t, "127.0.0.1:15356", "www.shop.example", dns.TypeA)
+	is.Len(in.Answer, 2)
+	is.Equal("192.0.2.2", in.Answer[1].(*dns.A).A.String())
+
+	t.Run("Query log", func(_ *testing.T) {
+		recorded.mu.Lock()
+		defer recorded.mu.Unlock()
+
+		chains := map[string][]string{}
+		for _, e := range recorded.entries {
+			chains[e.Domain] = e.CNAMEs
+		}
+		is.Equal([]string{"cdn.shop.example", "tracker.needle.local"}, chains["metrics.shop.example"])
+		is.Equal([]string{"malware.needle.local"}, chains["pixel.shop.example"])
+		is.Contains(chains, "www.shop.example")
+		is.Nil(chains["www.shop.example"])
+	})
+
+	t.Run("Disabled", func(_ *testing.T) {
+		is.NoError(srv.Reload(coredns.WithCNAMECheck(false)))
+
+		in := exchange(t, "127.0.0.1:15356", "metrics.shop.example", dns.TypeA)
+		is.Len(in.Answer, 3)
+		is.Equal("192.0.2.1", in.Answer[2].(*dns.A).A.String())
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	is.NoError(srv.Shutdown(ctx))
+	is.NoError(<-done)
+}
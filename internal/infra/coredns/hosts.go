@@ -0,0 +1,180 @@
+package coredns
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// defaultBlockAddress is used to block a name when the hosts file has no entry to copy.
+const defaultBlockAddress = "0.0.0.0"
+
+// Hosts edits the hosts file served by the hosts plugin, which reloads it
+// within 5 seconds.
+type Hosts struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewHosts returns Hosts editing path.
+func NewHosts(path string) *Hosts {
+	return &Hosts{path: path}
+}
+
+// Block adds name to the hosts file with the address of its first entry,
+// so that it resolves to needle like the other blocked names.
+func (h *Hosts) Block(ctx context.Context, name string) error {
+	return h.BlockAddress(ctx, name, "")
+}
+
+// BlockAddress adds name to the hosts file with address, or with the
+// address of its first entry when address is empty.
+func (h *Hosts) BlockAddress(_ context.Context, name, address string) error {
+	name = normalizeHost(name)
+	if name == "" || strings.ContainsAny(name+address, " \t\r\n#") {
+		return errors.Errorf("coredns.Hosts.Block: invalid name %q", name)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	lines, err := h.read()
+	if err != nil {
+		return errors.Wrap(err, "coredns.Hosts.Block")
+	}
+
+	for _, line := range lines {
+		f := hostsFields(line)
+		if len(f) < 2 {
+			continue
+		}
+		if address == "" {
+			address = f[0]
+		}
+		for _, host := range f[1:] {
+			if normalizeHost(host) == name {
+				return nil
+			}
+		}
+	}
+	if address == "" {
+		address = defaultBlockAddress
+	}
+
+	lines = append(lines, address+" "+name)
+	return errors.Wrap(h.write(lines), "coredns.Hosts.Block")
+}
+
+// Lookup returns the address of name in the hosts file, ok is false when
+// name is not blocked.
+func (h *Hosts) Lookup(_ context.Context, name string) (string, bool, error) {
+	name = normalizeHost(name)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	lines, err := h.read()
+	if err != nil {
+		return "", false, errors.Wrap(err, "coredns.Hosts.Lookup")
+	}
+
+	for _, line := range lines {
+		f := hostsFields(line)
+		for i := 1; i < len(f); i++ {
+			if normalizeHost(f[i]) == name {
+				return f[0], true, nil
+			}
+		}
+	}
+	return "", false, nil
+}
+
+// Path returns the hosts file path.
+func (h *Hosts) Path() string {
+	return h.path
+}
+
+// Allow removes name from the hosts file, it is then resolved by upstreams.
+func (h *Hosts) Allow(_ context.Context, name string) error {
+	name = normalizeHost(name)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	lines, err := h.read()
+	if err != nil {
+		return errors.Wrap(err, "coredns.Hosts.Allow")
+	}
+
+	result := make([]string, 0, len(lines))
+	changed := false
+	for _, line := range lines {
+		f := hostsFields(line)
+		if len(f) < 2 {
+			result = append(result, line)
+			continue
+		}
+
+		hosts := make([]string, 0, len(f)-1)
+		for _, host := range f[1:] {
+			if normalizeHost(host) != name {
+				hosts = append(hosts, host)
+			}
+		}
+		switch {
+		case len(hosts) == len(f)-1:
+			result = append(result, line)
+		case len(hosts) > 0:
+			changed = true
+			result = append(result, f[0]+" "+strings.Join(hosts, " "))
+		default:
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+	return errors.Wrap(h.write(result), "coredns.Hosts.Allow")
+}
+
+func (h *Hosts) read() ([]string, error) {
+	data, err := os.ReadFile(h.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Split(strings.TrimRight(string(data), "\n"), "\n"), nil
+}
+
+// write replaces the hosts file atomically, keeping its mode.
+func (h *Hosts) write(lines []string) error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(h.path); err == nil {
+		mode = info.Mode()
+	}
+
+	tmp := h.path + ".tmp"
+	err := os.WriteFile(tmp, []byte(strings.Join(lines, "\n")+"\n"), mode)
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, h.path)
+}
+
+func hostsFields(line string) []string {
+	line, _, _ = strings.Cut(line, "#")
+	return strings.Fields(line)
+}
+
+func normalizeHost(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
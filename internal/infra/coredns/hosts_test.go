@@ -0,0 +1,48 @@
+package coredns_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/infra/coredns"
+)
+
+func Test_Hosts(t *testing.T) {
+	is := require.New(t)
+
+	ctx := context.Background()
+	hostsFile := filepath.Join(t.TempDir(), "hosts")
+	is.NoError(os.WriteFile(hostsFile, []byte("# blocked\n192.168.1.2 ads.needle.local pixel.needle.local\n"), 0o600))
+
+	hosts := coredns.NewHosts(hostsFile)
+
+	is.NoError(hosts.Block(ctx, "Tracker.needle.local."))
+	is.NoError(hosts.Block(ctx, "ads.needle.local"))
+	data, err := os.ReadFile(hostsFile)
+	is.NoError(err)
+	is.Equal("# blocked\n192.168.1.2 ads.needle.local pixel.needle.local\n192.168.1.2 tracker.needle.local\n", string(data))
+
+	is.NoError(hosts.Allow(ctx, "ads.needle.local"))
+	is.NoError(hosts.Allow(ctx, "tracker.needle.local"))
+	data, err = os.ReadFile(hostsFile)
+	is.NoError(err)
+	is.Equal("# blocked\n192.168.1.2 pixel.needle.local\n", string(data))
+
+	info, err := os.Stat(hostsFile)
+	is.NoError(err)
+	is.Equal(os.FileMode(0o600), info.Mode())
+
+	t.Run("Missing file", func(_ *testing.T) {
+		missing := filepath.Join(t.TempDir(), "hosts")
+		hosts := coredns.NewHosts(missing)
+		is.NoError(hosts.Allow(ctx, "ads.needle.local"))
+		is.NoError(hosts.Block(ctx, "ads.needle.local"))
+
+		count, err := coredns.CountHosts(missing)
+		is.NoError(err)
+		is.Equal(1, count)
+	})
+}
@@ -0,0 +1,104 @@
+package coredns
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+
+	"go.pixelfactory.io/needle/internal/infra/querylog"
+)
+
+// queryLogDirective is the Corefile directive recording queries in the query log.
+const queryLogDirective = "needle_querylog"
+
+// QueryRecorder records DNS queries.
+type QueryRecorder interface {
+	Record(e querylog.Entry)
+}
+
+// queryRecorders holds the QueryRecorder of each server by port, plugins
+// are configured from the Corefile and cannot be handed one directly.
+var queryRecorders sync.Map
+
+func init() {
+	plugin.Register(queryLogDirective, setupQueryLog)
+
+	// record queries answered from the cache too
+	i := slices.Index(dnsserver.Directives, "log")
+	dnsserver.Directives = slices.Insert(dnsserver.Directives, i+1, queryLogDirective)
+}
+
+func setupQueryLog(c *caddy.Controller) error {
+	config := dnsserver.GetConfig(c)
+	c.Next() // directive name
+	if c.NextArg() {
+		return plugin.Error(queryLogDirective, c.ArgErr())
+	}
+
+	recorder, ok := queryRecorders.Load(config.Port)
+	if !ok {
+		return plugin.Error(queryLogDirective, c.Errf("no query log for port %s", config.Port))
+	}
+
+	config.AddPlugin(func(next plugin.Handler) plugin.Handler {
+		rec, _ := recorder.(QueryRecorder)
+		return queryLog{Next: next, recorder: rec}
+	})
+	return nil
+}
+
+// cnamesKey is the context key of the CNAME chain of a query blocked by one
+// of its CNAMEs, set by the needle plugin.
+type cnamesKey struct{}
+
+// queryLog records answered queries.
+type queryLog struct {
+	Next     plugin.Handler
+	recorder QueryRecorder
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (q queryLog) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	start := time.Now()
+	rw := dnstest.NewRecorder(w)
+	var cnames []string
+	ctx = context.WithValue(ctx, cnamesKey{}, &cnames)
+	rc, err := plugin.NextOrFailure(q.Name(), q.Next, ctx, rw, r)
+
+	state := request.Request{W: w, Req: r}
+	name := strings.ToLower(strings.TrimSuffix(state.Name(), "."))
+
+	// skip health checks
+	if ip := net.ParseIP(state.IP()); name == "localhost" && ip != nil && ip.IsLoopback() {
+		return rc, err
+	}
+
+	rcode := rw.Rcode
+	if !plugin.ClientWrite(rc) {
+		rcode = rc
+	}
+
+	q.recorder.Record(querylog.Entry{
+		Time:      start,
+		Kind:      querylog.KindDNS,
+		Client:    state.IP(),
+		Domain:    name,
+		QueryType: state.Type(),
+		RCode:     dns.RcodeToString[rcode],
+		CNAMEs:    cnames,
+	})
+	return rc, err
+}
+
+// Name implements the Handler interface.
+func (q queryLog) Name() string { return queryLogDirective }
@@ -3,15 +3,25 @@ package coredns
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"text/template"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/miekg/dns"
 	"go.pixelfactory.io/pkg/observability/log"
 	"go.pixelfactory.io/pkg/observability/log/fields"
 )
 
+// ErrNotRunning the DNS server is not running.
+var ErrNotRunning = errors.New("DNS server is not running")
+
 // DNSServer holds dns server.
 type DNSServer struct {
 	name      string
@@ -20,6 +30,20 @@ type DNSServer struct {
 	corefile  string
 	upsteams  []string
 	logger    log.Logger
+	// metricsAddr is where the prometheus plugin listens, its metrics are
+	// also registered with prometheus.DefaultGatherer.
+	metricsAddr string
+	queryLog    QueryRecorder
+	blocker     Blocker
+	// blockAddresses returns the answers to A and AAAA queries for blocked names
+	blockAddresses func() []net.IP
+	blockMode      Mode
+	listModes      map[string]Mode
+	cnameCheck     bool
+
+	mu       sync.Mutex
+	instance *caddy.Instance
+	stopped  bool
 }
 
 // Option type.
@@ -60,13 +84,67 @@ func WithCoreFile(cf string) Option {
 	}
 }
 
+// WithMetricsAddr set the listen address of the CoreDNS metrics endpoint.
+func WithMetricsAddr(a string) Option {
+	return func(s *DNSServer) {
+		s.metricsAddr = a
+	}
+}
+
+// WithQueryLog records queries in r, a custom Corefile needs the
+// needle_querylog directive.
+func WithQueryLog(r QueryRecorder) Option {
+	return func(s *DNSServer) {
+		s.queryLog = r
+	}
+}
+
+// WithBlocker answers queries for names blocked by b, a custom Corefile
+// needs the needle directive.
+func WithBlocker(b Blocker) Option {
+	return func(s *DNSServer) {
+		s.blocker = b
+	}
+}
+
+// WithBlockAddresses set the function returning the addresses blocked
+// names resolve to in ModeAddress, 0.0.0.0 and :: when they are empty.
+// It is called for each blocked query.
+func WithBlockAddresses(fn func() []net.IP) Option {
+	return func(s *DNSServer) {
+		s.blockAddresses = fn
+	}
+}
+
+// WithBlockMode set how queries for blocked names are answered, modes
+// overrides it for the names blocked by a list.
+func WithBlockMode(mode Mode, modes map[string]Mode) Option {
+	return func(s *DNSServer) {
+		s.blockMode = mode
+		s.listModes = modes
+	}
+}
+
+// WithCNAMECheck set whether queries answered with a CNAME of a blocked
+// name are blocked, to defeat CNAME cloaking.
+func WithCNAMECheck(enabled bool) Option {
+	return func(s *DNSServer) {
+		s.cnameCheck = enabled
+	}
+}
+
 // NewCoreDNSServer create new DNSServer with default values.
 func NewCoreDNSServer(opts ...Option) *DNSServer {
 	srv := &DNSServer{
-		name:      "coredns",
-		port:      53,
-		hostsfile: "hosts",
-		upsteams:  []string{"/etc/resolv.conf"},
+		name:        "coredns",
+		port:        53,
+		hostsfile:   "hosts",
+		upsteams:    []string{"/etc/resolv.conf"},
+		metricsAddr: "localhost:9153",
+		blockMode:   ModeAddress,
+		blockAddresses: func() []net.IP {
+			return nil
+		},
 	}
 
 	for _, opt := range opts {
@@ -88,6 +166,8 @@ func NewCoreDNSServer(opts ...Option) *DNSServer {
 
 // Run CoreDNS.
 func (s *DNSServer) Run() error {
+	s.register()
+
 	corefile, err := caddy.LoadCaddyfile("dns")
 	if err != nil {
 		s.logger.Error("failed to initialize Corefile", fields.Error(err))
@@ -100,10 +180,134 @@ func (s *DNSServer) Run() error {
 		return err
 	}
 
+	s.mu.Lock()
+	if s.stopped {
+		// Shutdown was called while starting
+		s.mu.Unlock()
+		instance.ShutdownCallbacks()
+		return instance.Stop()
+	}
+	s.instance = instance
+	s.mu.Unlock()
+
+	// restarted instances share the wait group of the first one
 	instance.Wait()
 	return nil
 }
 
+// Reload applies opts, re-renders the Corefile and restarts CoreDNS.
+// Listeners are handed over to the new instance, if the restart fails the
+// previous instance keeps serving.
+func (s *DNSServer) Reload(opts ...Option) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.instance == nil || s.stopped {
+		return ErrNotRunning
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.register()
+
+	corefile, err := s.defaultLoader("dns")
+	if err != nil {
+		return err
+	}
+
+	instance, err := s.instance.Restart(corefile)
+	if err != nil {
+		s.logger.Error("failed to reload CoreDNS", fields.Error(err))
+		return err
+	}
+
+	s.instance = instance
+	return nil
+}
+
+// register hands the query log and blocker to the plugins of the server port.
+func (s *DNSServer) register() {
+	port := strconv.Itoa(s.port)
+	if s.queryLog != nil {
+		queryRecorders.Store(port, s.queryLog)
+	}
+
+	if s.blocker != nil {
+		blockers.Store(port, blocking{
+			blocker:    s.blocker,
+			addresses:  s.blockAddresses,
+			mode:       s.blockMode,
+			listModes:  s.listModes,
+			cnameCheck: s.cnameCheck,
+		})
+	}
+}
+
+// Shutdown gracefully stops CoreDNS, Run returns once it is stopped.
+func (s *DNSServer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.stopped = true
+	instance := s.instance
+	s.mu.Unlock()
+
+	if instance == nil {
+		return nil
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		instance.ShutdownCallbacks()
+		done <- instance.Stop()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Check sends a test query to CoreDNS, any response means it is answering.
+// It only applies to the generated Corefile, which listens on all interfaces.
+func (s *DNSServer) Check(ctx context.Context) error {
+	s.mu.Lock()
+	running := s.instance != nil && !s.stopped
+	port := s.port
+	s.mu.Unlock()
+
+	if !running {
+		return ErrNotRunning
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("localhost.", dns.TypeA)
+
+	var client dns.Client
+	_, _, err := client.ExchangeContext(ctx, m, net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	return err
+}
+
+// CountHosts returns the number of names in a hosts file.
+func CountHosts(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		f := strings.Fields(line)
+		if len(f) > 1 {
+			count += len(f) - 1
+		}
+	}
+
+	return count, nil
+}
+
 // defaultLoader loads the CoreDNS configuration.
 func (s *DNSServer) defaultLoader(serverType string) (caddy.Input, error) {
 	cf, err := s.renderCorefile()
@@ -128,24 +332,40 @@ func (s *DNSServer) renderCorefile() ([]byte, error) {
 		return cf, nil
 	}
 
+	// a short hosts TTL lets clients resolve allowed names soon after the
+	// hosts file changes
 	corefileTpl := `
 	.:{{.Port}} {
+		{{- if .Block}}
+		needle
+		{{- end}}
 		hosts {{.Hosts}} {
+			ttl 10
 			fallthrough
 		}
 		forward . {{range $server := .Upstreams}} {{$server}} {{end}}
 		cache
 		loop
+		prometheus {{.MetricsAddr}}
+		{{- if .QueryLog}}
+		needle_querylog
+		{{- end}}
 	}`
 
 	values := struct {
-		Port      int
-		Hosts     string
-		Upstreams []string
+		Port        int
+		Hosts       string
+		Upstreams   []string
+		MetricsAddr string
+		QueryLog    bool
+		Block       bool
 	}{
-		Port:      s.port,
-		Hosts:     s.hostsfile,
-		Upstreams: s.upsteams,
+		Port:        s.port,
+		Hosts:       s.hostsfile,
+		Upstreams:   s.upsteams,
+		MetricsAddr: s.metricsAddr,
+		QueryLog:    s.queryLog != nil,
+		Block:       s.blocker != nil,
 	}
 
 	tmpl, err := template.New("corefile").Parse(corefileTpl)
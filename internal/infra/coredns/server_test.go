@@ -0,0 +1,142 @@
+package coredns_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/infra/coredns"
+	"go.pixelfactory.io/needle/internal/infra/querylog"
+	"go.pixelfactory.io/pkg/observability/log"
+
+	_ "github.com/coredns/coredns/plugin/cache"
+	_ "github.com/coredns/coredns/plugin/forward"
+	_ "github.com/coredns/coredns/plugin/hosts"
+	_ "github.com/coredns/coredns/plugin/loop"
+	_ "github.com/coredns/coredns/plugin/metrics"
+)
+
+type queries struct {
+	mu      sync.Mutex
+	entries []querylog.Entry
+}
+
+func (q *queries) Record(e querylog.Entry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.entries = append(q.entries, e)
+}
+
+func (q *queries) domains() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var domains []string
+	for _, e := range q.entries {
+		domains = append(domains, e.Domain)
+	}
+	return domains
+}
+
+// lookup queries the A record of name on addr.
+func lookup(t *testing.T, addr, name string) []dns.RR {
+	t.Helper()
+
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), dns.TypeA)
+
+	var in *dns.Msg
+	var err error
+	for i := 0; i < 20; i++ {
+		in, err = dns.Exchange(m, addr)
+		if err == nil {
+			return in.Answer
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	require.NoError(t, err)
+	return nil
+}
+
+func Test_DNSServer(t *testing.T) {
+	is := require.New(t)
+
+	dir := t.TempDir()
+	hostsFile := filepath.Join(dir, "hosts")
+	is.NoError(os.WriteFile(hostsFile, []byte("10.0.0.1 ads.needle.local\n127.0.0.1 localhost\n"), 0o600))
+
+	recorded := &queries{}
+	srv := coredns.NewCoreDNSServer(
+		coredns.WithLogger(log.New()),
+		coredns.WithPort(15353),
+		coredns.WithHostsFile(hostsFile),
+		coredns.WithUpstreams([]string{"127.0.0.1:1"}),
+		coredns.WithCoreFile(""),
+		coredns.WithMetricsAddr("127.0.0.1:19153"),
+		coredns.WithQueryLog(recorded),
+	)
+
+	count, err := coredns.CountHosts(hostsFile)
+	is.NoError(err)
+	is.Equal(2, count)
+
+	is.ErrorIs(srv.Reload(), coredns.ErrNotRunning)
+	is.ErrorIs(srv.Check(context.Background()), coredns.ErrNotRunning)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Run()
+	}()
+
+	answer := lookup(t, "127.0.0.1:15353", "ads.needle.local")
+	is.Len(answer, 1)
+	is.Equal("10.0.0.1", answer[0].(*dns.A).A.String())
+
+	t.Run("Check", func(_ *testing.T) {
+		is.Eventually(func() bool {
+			return srv.Check(context.Background()) == nil
+		}, 5*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("Query log", func(_ *testing.T) {
+		is.Contains(recorded.domains(), "ads.needle.local")
+		is.NotContains(recorded.domains(), "localhost")
+
+		recorded.mu.Lock()
+		defer recorded.mu.Unlock()
+		is.Equal(querylog.KindDNS, recorded.entries[0].Kind)
+		is.Equal("A", recorded.entries[0].QueryType)
+		is.Equal("NOERROR", recorded.entries[0].RCode)
+		is.Equal("127.0.0.1", recorded.entries[0].Client)
+	})
+
+	t.Run("Reload", func(_ *testing.T) {
+		is.NoError(os.WriteFile(hostsFile, []byte("10.0.0.2 ads.needle.local\n"), 0o600))
+		is.NoError(srv.Reload())
+
+		answer := lookup(t, "127.0.0.1:15353", "ads.needle.local")
+		is.Len(answer, 1)
+		is.Equal("10.0.0.2", answer[0].(*dns.A).A.String())
+	})
+
+	t.Run("Reload error keeps serving", func(_ *testing.T) {
+		is.Error(srv.Reload(coredns.WithCoreFile(filepath.Join(dir, "missing"))))
+
+		answer := lookup(t, "127.0.0.1:15353", "ads.needle.local")
+		is.Len(answer, 1)
+	})
+
+	t.Run("Shutdown", func(_ *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		is.NoError(srv.Shutdown(ctx))
+		is.NoError(<-done)
+		is.ErrorIs(srv.Reload(), coredns.ErrNotRunning)
+	})
+}
@@ -0,0 +1,76 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"encoding/pem"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// blockType is the PEM type of an encrypted private key.
+	blockType = "NEEDLE ENCRYPTED PRIVATE KEY"
+
+	// keyIDHeader is the PEM header holding the id of the key used for encryption.
+	keyIDHeader = "Key-Id"
+)
+
+// Encrypted reports whether data is an encrypted envelope.
+func Encrypted(data []byte) bool {
+	block, _ := pem.Decode(data)
+	return block != nil && block.Type == blockType
+}
+
+// KeyID returns the key id of an encrypted envelope.
+func KeyID(data []byte) string {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return ""
+	}
+	return block.Headers[keyIDHeader]
+}
+
+// Seal encrypts plaintext with the active key, binding it to name.
+func (k *Keyring) Seal(name string, plaintext []byte) ([]byte, error) {
+	aead, ok := k.keys[k.active]
+	if !ok {
+		return nil, ErrNoKeys
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "encryption.Keyring.Seal")
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:    blockType,
+		Headers: map[string]string{keyIDHeader: k.active},
+		Bytes:   aead.Seal(nonce, nonce, plaintext, []byte(name)),
+	}), nil
+}
+
+// Open decrypts an envelope produced by Seal for name.
+func (k *Keyring) Open(name string, data []byte) ([]byte, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, errors.New("not an encrypted envelope")
+	}
+
+	id := block.Headers[keyIDHeader]
+	aead, ok := k.keys[id]
+	if !ok {
+		return nil, errors.Wrapf(ErrUnknownKey, "%q", id)
+	}
+
+	if len(block.Bytes) < aead.NonceSize() {
+		return nil, errors.New("encrypted envelope too short")
+	}
+
+	nonce, ciphertext := block.Bytes[:aead.NonceSize()], block.Bytes[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return nil, errors.Wrap(err, "encryption.Keyring.Open")
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,127 @@
+// Package encryption provides encryption at rest for certificate private keys.
+package encryption
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// minSecretLength is the minimum accepted length of a secret.
+const minSecretLength = 16
+
+var (
+	// ErrNoKeys no encryption key configured.
+	ErrNoKeys = errors.New("no encryption key configured")
+
+	// ErrUnknownKey the key id used to encrypt an entry is not in the keyring.
+	ErrUnknownKey = errors.New("unknown encryption key id")
+
+	// ErrInvalidKey a configured key is malformed.
+	ErrInvalidKey = errors.New("invalid encryption key")
+)
+
+// Keyring holds AEAD ciphers indexed by key id.
+// The first key added is the active key, used to encrypt new entries,
+// the others are only used to decrypt entries written before a rotation.
+type Keyring struct {
+	active string
+	keys   map[string]cipher.AEAD
+}
+
+// NewKeyring creates an empty Keyring.
+func NewKeyring() *Keyring {
+	return &Keyring{keys: map[string]cipher.AEAD{}}
+}
+
+// Add derives an AES-256-GCM key from secret and registers it under id.
+func (k *Keyring) Add(id, secret string) error {
+	if id == "" || strings.ContainsAny(id, ": \t") {
+		return errors.Wrapf(ErrInvalidKey, "key id %q", id)
+	}
+	if len(secret) < minSecretLength {
+		return errors.Wrapf(ErrInvalidKey, "key %q must be at least %d characters", id, minSecretLength)
+	}
+	if _, ok := k.keys[id]; ok {
+		return errors.Wrapf(ErrInvalidKey, "duplicate key id %q", id)
+	}
+
+	key, err := hkdf.Key(sha256.New, []byte(secret), nil, "needle key encryption "+id, 32)
+	if err != nil {
+		return errors.Wrap(err, "encryption.Keyring.Add")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return errors.Wrap(err, "encryption.Keyring.Add")
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return errors.Wrap(err, "encryption.Keyring.Add")
+	}
+
+	k.keys[id] = aead
+	if k.active == "" {
+		k.active = id
+	}
+
+	return nil
+}
+
+// Parse adds keys from "id:secret" entries.
+func (k *Keyring) Parse(entries ...string) error {
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		id, secret, ok := strings.Cut(entry, ":")
+		if !ok {
+			return errors.Wrap(ErrInvalidKey, "expected id:secret")
+		}
+
+		if err := k.Add(strings.TrimSpace(id), strings.TrimSpace(secret)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ParseFile adds keys from a file holding one "id:secret" entry per line.
+func (k *Keyring) ParseFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "encryption.Keyring.ParseFile")
+	}
+	defer f.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		entries = append(entries, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.Wrap(err, "encryption.Keyring.ParseFile")
+	}
+
+	return k.Parse(entries...)
+}
+
+// Active returns the id of the key used for encryption.
+func (k *Keyring) Active() string {
+	return k.active
+}
+
+// Empty reports whether the keyring holds no key.
+func (k *Keyring) Empty() bool {
+	return len(k.keys) == 0
+}
@@ -0,0 +1,126 @@
+package encryption
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.pixelfactory.io/needle/internal/app/pki"
+)
+
+// Repository wraps a pki.Repository and encrypts KeyPEM before it is stored.
+// Entries stored in plaintext before encryption was enabled are still readable.
+type Repository struct {
+	repo    pki.Repository
+	keyring *Keyring
+}
+
+// NewRepository creates an encrypting Repository.
+func NewRepository(repo pki.Repository, keyring *Keyring) *Repository {
+	return &Repository{
+		repo:    repo,
+		keyring: keyring,
+	}
+}
+
+// Delete deletes a certificate.
+func (r *Repository) Delete(ctx context.Context, name string) error {
+	return r.repo.Delete(ctx, name)
+}
+
+// Get retrieves and decrypts a certificate.
+func (r *Repository) Get(ctx context.Context, name string) (*pki.InternalCert, error) {
+	cert, err := r.repo.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.decrypt(cert)
+}
+
+// List retrieves and decrypts all certificates. Entries which do not
+// decrypt are left out and returned as pki.EntryErrors, next to the others.
+func (r *Repository) List(ctx context.Context) ([]*pki.InternalCert, error) {
+	certs, err := r.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*pki.InternalCert, 0, len(certs))
+	unreadable := pki.EntryErrors{}
+	for _, cert := range certs {
+		plain, err := r.decrypt(cert)
+		if err != nil {
+			unreadable[cert.Name] = err
+			continue
+		}
+		result = append(result, plain)
+	}
+
+	if len(unreadable) > 0 {
+		return result, unreadable
+	}
+	return result, nil
+}
+
+// Store encrypts and stores a certificate, certificate is left untouched.
+func (r *Repository) Store(ctx context.Context, certificate *pki.InternalCert) error {
+	keyPEM, err := r.keyring.Seal(certificate.Name, certificate.KeyPEM)
+	if err != nil {
+		return errors.Wrap(err, "encryption.Repository.Store")
+	}
+
+	encrypted := *certificate
+	encrypted.KeyPEM = keyPEM
+
+	return r.repo.Store(ctx, &encrypted)
+}
+
+// Rekey re-encrypts every entry which is in plaintext or not encrypted with the active key.
+// It returns the number of updated entries, entries which do not decrypt are
+// skipped and returned as pki.EntryErrors once the others are updated.
+func (r *Repository) Rekey(ctx context.Context) (int, error) {
+	certs, err := r.repo.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	unreadable := pki.EntryErrors{}
+	for _, cert := range certs {
+		if Encrypted(cert.KeyPEM) && KeyID(cert.KeyPEM) == r.keyring.Active() {
+			continue
+		}
+
+		plain, err := r.decrypt(cert)
+		if err != nil {
+			unreadable[cert.Name] = err
+			continue
+		}
+
+		if err := r.Store(ctx, plain); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	if len(unreadable) > 0 {
+		return count, unreadable
+	}
+	return count, nil
+}
+
+func (r *Repository) decrypt(cert *pki.InternalCert) (*pki.InternalCert, error) {
+	if !Encrypted(cert.KeyPEM) {
+		return cert, nil
+	}
+
+	keyPEM, err := r.keyring.Open(cert.Name, cert.KeyPEM)
+	if err != nil {
+		return nil, errors.Wrapf(err, "encryption.Repository.decrypt %s", cert.Name)
+	}
+
+	plain := *cert
+	plain.KeyPEM = keyPEM
+
+	return &plain, nil
+}
@@ -0,0 +1,186 @@
+package encryption_test
+
+import (
+	"context"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/app/pki"
+	"go.pixelfactory.io/needle/internal/infra/encryption"
+	mocks "go.pixelfactory.io/needle/mocks/pki"
+	"go.pixelfactory.io/needle/testdata"
+)
+
+func newKeyring(t *testing.T, entries ...string) *encryption.Keyring {
+	t.Helper()
+
+	keyring := encryption.NewKeyring()
+	require.NoError(t, keyring.Parse(entries...))
+	return keyring
+}
+
+// tamper flips the last byte of the ciphertext of a sealed envelope.
+func tamper(t *testing.T, sealed []byte) []byte {
+	t.Helper()
+
+	block, _ := pem.Decode(sealed)
+	require.NotNil(t, block)
+	block.Bytes[len(block.Bytes)-1] ^= 0xff
+	return pem.EncodeToMemory(block)
+}
+
+func Test_Keyring(t *testing.T) {
+	is := require.New(t)
+
+	t.Run("Parse keys", func(_ *testing.T) {
+		keyring := newKeyring(t, "# comment", "", "new:0123456789abcdef", "old:fedcba9876543210")
+		is.Equal("new", keyring.Active())
+		is.False(keyring.Empty())
+	})
+
+	t.Run("Parse invalid keys", func(_ *testing.T) {
+		is.ErrorIs(encryption.NewKeyring().Parse("nosecret"), encryption.ErrInvalidKey)
+		is.ErrorIs(encryption.NewKeyring().Parse("short:secret"), encryption.ErrInvalidKey)
+		is.ErrorIs(encryption.NewKeyring().Parse("a:0123456789abcdef", "a:0123456789abcdef"), encryption.ErrInvalidKey)
+	})
+
+	t.Run("Seal and open", func(_ *testing.T) {
+		keyring := newKeyring(t, "k1:0123456789abcdef")
+
+		sealed, err := keyring.Seal("test.needle.local", []byte("secret"))
+		is.NoError(err)
+		is.True(encryption.Encrypted(sealed))
+		is.Equal("k1", encryption.KeyID(sealed))
+
+		plain, err := keyring.Open("test.needle.local", sealed)
+		is.NoError(err)
+		is.Equal([]byte("secret"), plain)
+
+		// ciphertext is bound to the certificate name
+		_, err = keyring.Open("other.needle.local", sealed)
+		is.Error(err)
+
+		_, err = newKeyring(t, "k2:0123456789abcdef").Open("test.needle.local", sealed)
+		is.ErrorIs(err, encryption.ErrUnknownKey)
+	})
+
+	t.Run("Seal without key", func(_ *testing.T) {
+		_, err := encryption.NewKeyring().Seal("test.needle.local", []byte("secret"))
+		is.ErrorIs(err, encryption.ErrNoKeys)
+	})
+}
+
+func Test_Repository(t *testing.T) {
+	is := require.New(t)
+
+	_, testCert := testdata.Setup(t)
+	keyring := newKeyring(t, "k1:0123456789abcdef")
+
+	t.Run("Store encrypts key", func(_ *testing.T) {
+		repo := mocks.NewRepository(t)
+		svc := encryption.NewRepository(repo, keyring)
+
+		var stored *pki.InternalCert
+		repo.On("Store", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			stored = args.Get(1).(*pki.InternalCert)
+		}).Return(nil).Once()
+
+		is.NoError(svc.Store(context.Background(), testCert))
+		is.True(encryption.Encrypted(stored.KeyPEM))
+		is.Equal(testCert.CertPEM, stored.CertPEM)
+		is.False(encryption.Encrypted(testCert.KeyPEM))
+
+		repo.On("Get", mock.Anything, "test.needle.local").Return(stored, nil).Once()
+		cert, err := svc.Get(context.Background(), "test.needle.local")
+		is.NoError(err)
+		is.Equal(testCert.KeyPEM, cert.KeyPEM)
+	})
+
+	t.Run("Get plaintext entry", func(_ *testing.T) {
+		repo := mocks.NewRepository(t)
+		svc := encryption.NewRepository(repo, keyring)
+
+		repo.On("Get", mock.Anything, "test.needle.local").Return(testCert, nil).Once()
+		cert, err := svc.Get(context.Background(), "test.needle.local")
+		is.NoError(err)
+		is.Equal(testCert, cert)
+	})
+
+	t.Run("Get not found", func(_ *testing.T) {
+		repo := mocks.NewRepository(t)
+		svc := encryption.NewRepository(repo, keyring)
+
+		repo.On("Get", mock.Anything, "test.needle.local").Return(nil, pki.ErrCertificateNotFound).Once()
+		_, err := svc.Get(context.Background(), "test.needle.local")
+		is.ErrorIs(err, pki.ErrCertificateNotFound)
+	})
+
+	t.Run("List skips entries which do not decrypt", func(_ *testing.T) {
+		sealed, err := keyring.Seal(testCert.Name, testCert.KeyPEM)
+		is.NoError(err)
+		tampered := *testCert
+		tampered.KeyPEM = tamper(t, sealed)
+
+		plainCert := *testCert
+		plainCert.Name = "plain.needle.local"
+
+		// sealed with a key which is no longer in the keyring
+		lost, err := newKeyring(t, "gone:fedcba9876543210").Seal("lost.needle.local", testCert.KeyPEM)
+		is.NoError(err)
+		lostCert := *testCert
+		lostCert.Name = "lost.needle.local"
+		lostCert.KeyPEM = lost
+
+		repo := mocks.NewRepository(t)
+		svc := encryption.NewRepository(repo, keyring)
+		repo.On("List", mock.Anything).Return([]*pki.InternalCert{&tampered, &plainCert, &lostCert}, nil).Twice()
+
+		certs, err := svc.List(context.Background())
+		var unreadable pki.EntryErrors
+		is.ErrorAs(err, &unreadable)
+		is.Equal([]string{"lost.needle.local", testCert.Name}, unreadable.Names())
+		is.Equal([]*pki.InternalCert{&plainCert}, certs)
+
+		// the other entries are re-encrypted
+		repo.On("Store", mock.Anything, mock.MatchedBy(func(c *pki.InternalCert) bool {
+			return c.Name == "plain.needle.local" && encryption.Encrypted(c.KeyPEM)
+		})).Return(nil).Once()
+
+		count, err := svc.Rekey(context.Background())
+		is.ErrorAs(err, &unreadable)
+		is.Equal([]string{"lost.needle.local"}, unreadable.Names())
+		is.ErrorIs(unreadable["lost.needle.local"], encryption.ErrUnknownKey)
+		is.Equal(1, count)
+	})
+
+	t.Run("Rekey", func(_ *testing.T) {
+		oldKeyPEM, err := keyring.Seal(testCert.Name, testCert.KeyPEM)
+		is.NoError(err)
+
+		oldCert := *testCert
+		oldCert.KeyPEM = oldKeyPEM
+		plainCert := *testCert
+		plainCert.Name = "plain.needle.local"
+
+		rotated := newKeyring(t, "k2:fedcba9876543210", "k1:0123456789abcdef")
+		repo := mocks.NewRepository(t)
+		svc := encryption.NewRepository(repo, rotated)
+
+		current, err := rotated.Seal("current.needle.local", testCert.KeyPEM)
+		is.NoError(err)
+		currentCert := *testCert
+		currentCert.Name = "current.needle.local"
+		currentCert.KeyPEM = current
+
+		repo.On("List", mock.Anything).Return([]*pki.InternalCert{&oldCert, &plainCert, &currentCert}, nil).Once()
+		repo.On("Store", mock.Anything, mock.MatchedBy(func(c *pki.InternalCert) bool {
+			return encryption.KeyID(c.KeyPEM) == "k2"
+		})).Return(nil).Twice()
+
+		count, err := svc.Rekey(context.Background())
+		is.NoError(err)
+		is.Equal(2, count)
+	})
+}
@@ -0,0 +1,110 @@
+// Package fetcher opens blocklists from files and HTTP URLs.
+package fetcher
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Fetcher opens file paths and http(s) URLs.
+type Fetcher struct {
+	client  *http.Client
+	maxSize int64
+}
+
+// Option type.
+type Option func(*Fetcher)
+
+// WithClient set the HTTP client.
+func WithClient(c *http.Client) Option {
+	return func(f *Fetcher) {
+		f.client = c
+	}
+}
+
+// WithMaxSize set the size in bytes above which reading a list fails.
+func WithMaxSize(n int64) Option {
+	return func(f *Fetcher) {
+		f.maxSize = n
+	}
+}
+
+// New returns a Fetcher.
+func New(opts ...Option) *Fetcher {
+	f := &Fetcher{
+		client:  &http.Client{Timeout: time.Minute},
+		maxSize: 64 << 20,
+	}
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+// Fetch opens location, an http(s) URL or a file path.
+func (f *Fetcher) Fetch(ctx context.Context, location string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		file, err := os.Open(strings.TrimPrefix(location, "file://"))
+		if err != nil {
+			return nil, errors.Wrap(err, "fetcher.Fetch")
+		}
+		return f.limit(file), nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, http.NoBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetcher.Fetch")
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetcher.Fetch")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, errors.Errorf("fetcher.Fetch: %s returned %s", location, resp.Status)
+	}
+
+	return f.limit(resp.Body), nil
+}
+
+// limit fails reads past maxSize rather than truncating the list.
+func (f *Fetcher) limit(rc io.ReadCloser) io.ReadCloser {
+	return &limitedReader{rc: rc, remaining: f.maxSize}
+}
+
+type limitedReader struct {
+	rc        io.ReadCloser
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		// a list of exactly maxSize bytes is accepted
+		var b [1]byte
+		if n, _ := l.rc.Read(b[:]); n == 0 {
+			return 0, io.EOF
+		}
+		return 0, errors.New("fetcher.Fetch: list is too large")
+	}
+
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.rc.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+func (l *limitedReader) Close() error {
+	return l.rc.Close()
+}
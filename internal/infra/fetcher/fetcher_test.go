@@ -0,0 +1,83 @@
+package fetcher_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/infra/fetcher"
+)
+
+func Test_Fetch(t *testing.T) {
+	is := require.New(t)
+
+	ctx := context.Background()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ads.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = io.WriteString(w, "||ads.needle.local^\n")
+	}))
+	defer srv.Close()
+
+	listFile := filepath.Join(t.TempDir(), "hosts")
+	is.NoError(os.WriteFile(listFile, []byte("0.0.0.0 ads.needle.local\n"), 0o600))
+
+	f := fetcher.New(fetcher.WithClient(srv.Client()))
+
+	read := func(location string) (string, error) {
+		rc, err := f.Fetch(ctx, location)
+		if err != nil {
+			return "", err
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		return string(data), err
+	}
+
+	t.Run("URL", func(_ *testing.T) {
+		list, err := read(srv.URL + "/ads.txt")
+		is.NoError(err)
+		is.Equal("||ads.needle.local^\n", list)
+
+		_, err = read(srv.URL + "/missing.txt")
+		is.ErrorContains(err, "404")
+	})
+
+	t.Run("File", func(_ *testing.T) {
+		list, err := read(listFile)
+		is.NoError(err)
+		is.Equal("0.0.0.0 ads.needle.local\n", list)
+
+		list, err = read("file://" + listFile)
+		is.NoError(err)
+		is.Equal("0.0.0.0 ads.needle.local\n", list)
+
+		_, err = read(listFile + ".missing")
+		is.Error(err)
+	})
+
+	t.Run("Max size", func(_ *testing.T) {
+		small := fetcher.New(fetcher.WithMaxSize(int64(len("0.0.0.0 ads.needle.local\n"))))
+		rc, err := small.Fetch(ctx, listFile)
+		is.NoError(err)
+		_, err = io.ReadAll(rc)
+		is.NoError(err)
+		is.NoError(rc.Close())
+
+		small = fetcher.New(fetcher.WithMaxSize(10))
+		rc, err = small.Fetch(ctx, listFile)
+		is.NoError(err)
+		data, err := io.ReadAll(rc)
+		is.ErrorContains(err, "too large")
+		is.True(strings.HasPrefix("0.0.0.0 ads.needle.local\n", string(data)))
+		is.NoError(rc.Close())
+	})
+}
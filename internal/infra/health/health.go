@@ -0,0 +1,132 @@
+// Package health runs named checks and reports their status.
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status values reported by checks.
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+// CheckFunc returns an error when the checked component is unhealthy.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker holds checks.
+type Checker struct {
+	checks  []check
+	timeout time.Duration
+}
+
+// Result holds the outcome of a check.
+type Result struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report holds the outcome of all checks, Status is ok when every check passed.
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// OK returns true when every check passed.
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+// Option type.
+type Option func(*Checker)
+
+// WithCheck add a named check.
+func WithCheck(name string, fn CheckFunc) Option {
+	return func(c *Checker) {
+		c.checks = append(c.checks, check{name: name, fn: fn})
+	}
+}
+
+// WithTimeout set the time allowed to each check.
+func WithTimeout(t time.Duration) Option {
+	return func(c *Checker) {
+		c.timeout = t
+	}
+}
+
+// New create new Checker with default values.
+func New(opts ...Option) *Checker {
+	c := &Checker{
+		timeout: 2 * time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Run runs all checks concurrently and reports their results sorted by name.
+func (c *Checker) Run(ctx context.Context) Report {
+	results := make([]Result, len(c.checks))
+
+	var wg sync.WaitGroup
+	for i, chk := range c.checks {
+		wg.Add(1)
+		go func(i int, chk check) {
+			defer wg.Done()
+			results[i] = c.run(ctx, chk)
+		}(i, chk)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+
+	report := Report{Status: StatusOK, Checks: results}
+	for _, result := range results {
+		if result.Status != StatusOK {
+			report.Status = StatusError
+		}
+	}
+
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, chk check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- chk.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{Name: chk.name, Status: StatusOK, Duration: time.Since(start).String()}
+	if err != nil {
+		result.Status = StatusError
+		result.Error = err.Error()
+	}
+
+	return result
+}
@@ -0,0 +1,56 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/infra/health"
+)
+
+func Test_Checker(t *testing.T) {
+	is := require.New(t)
+
+	ok := func(_ context.Context) error { return nil }
+
+	t.Run("All checks pass", func(_ *testing.T) {
+		report := health.New(
+			health.WithCheck("repository", ok),
+			health.WithCheck("ca", ok),
+		).Run(context.Background())
+
+		is.True(report.OK())
+		is.Len(report.Checks, 2)
+		is.Equal("ca", report.Checks[0].Name)
+		is.Equal(health.StatusOK, report.Checks[0].Status)
+	})
+
+	t.Run("Failed check", func(_ *testing.T) {
+		report := health.New(
+			health.WithCheck("ca", ok),
+			health.WithCheck("dns", func(_ context.Context) error { return errors.New("no answer") }),
+		).Run(context.Background())
+
+		is.False(report.OK())
+		is.Equal(health.StatusError, report.Checks[1].Status)
+		is.Equal("no answer", report.Checks[1].Error)
+	})
+
+	t.Run("Timeout", func(_ *testing.T) {
+		block := make(chan struct{})
+		defer close(block)
+
+		report := health.New(
+			health.WithTimeout(10*time.Millisecond),
+			health.WithCheck("https", func(_ context.Context) error {
+				<-block
+				return nil
+			}),
+		).Run(context.Background())
+
+		is.False(report.OK())
+		is.Equal(context.DeadlineExceeded.Error(), report.Checks[0].Error)
+	})
+}
@@ -0,0 +1,312 @@
+// Package admin provides the admin REST API, served apart from the public router.
+package admin
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.pixelfactory.io/pkg/observability/log"
+	"go.pixelfactory.io/pkg/observability/log/fields"
+
+	"go.pixelfactory.io/needle/internal/app/pki"
+	"go.pixelfactory.io/needle/internal/infra/http/handlers"
+)
+
+// OpenAPI is the OpenAPI description of the admin API.
+//
+//go:embed openapi.yaml
+var OpenAPI []byte
+
+// Certificates gives access to stored certificates.
+type Certificates interface {
+	Delete(ctx context.Context, name string) error
+	Get(ctx context.Context, name string) (*pki.InternalCert, error)
+	List(ctx context.Context) ([]*pki.InternalCert, error)
+}
+
+// Blocklist describes a source of blocked domains.
+type Blocklist struct {
+	Name      string     `json:"name"`
+	Source    string     `json:"source"`
+	Entries   int        `json:"entries"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	// Error is set when the last update failed.
+	Error string `json:"error,omitempty"`
+}
+
+// BlocklistsFunc returns the configured blocklists.
+type BlocklistsFunc func(ctx context.Context) ([]Blocklist, error)
+
+// Schedule describes a schedule, whether it is active and what it applies
+// to.
+type Schedule struct {
+	Name     string   `json:"name"`
+	Windows  []string `json:"windows"`
+	Timezone string   `json:"timezone"`
+	Active   bool     `json:"active"`
+	// NextChange is unset when the schedule never changes.
+	NextChange *time.Time `json:"next_change,omitempty"`
+	Groups     []string   `json:"groups"`
+	Blocklists []string   `json:"blocklists"`
+}
+
+// SchedulesFunc returns the configured schedules.
+type SchedulesFunc func(ctx context.Context) ([]Schedule, error)
+
+// StatsFunc returns counters by name.
+type StatsFunc func(ctx context.Context) (map[string]float64, error)
+
+// ConfigFunc returns the effective configuration, secrets masked.
+type ConfigFunc func() map[string]any
+
+// API holds admin API dependencies.
+type API struct {
+	logger       log.Logger
+	certificates Certificates
+	ca           CAFunc
+	domains      Domains
+	traffic      Traffic
+	queryLog     QueryLog
+	blocklists   BlocklistsFunc
+	updates      BlocklistUpdates
+	schedules    SchedulesFunc
+	stats        StatsFunc
+	config       ConfigFunc
+	reload       handlers.ReloadFunc
+	backup       handlers.BackupFunc
+	auth         *Auth
+}
+
+// Option type.
+type Option func(*API)
+
+// WithLogger set API logger.
+func WithLogger(l log.Logger) Option {
+	return func(a *API) {
+		a.logger = l
+	}
+}
+
+// WithCertificates set the certificates store.
+func WithCertificates(c Certificates) Option {
+	return func(a *API) {
+		a.certificates = c
+	}
+}
+
+// WithCA set the CA certificates source.
+func WithCA(ca CAFunc) Option {
+	return func(a *API) {
+		a.ca = ca
+	}
+}
+
+// WithDomains set how domains are blocked and allowed.
+func WithDomains(d Domains) Option {
+	return func(a *API) {
+		a.domains = d
+	}
+}
+
+// WithTraffic set the blocked traffic statistics.
+func WithTraffic(t Traffic) Option {
+	return func(a *API) {
+		a.traffic = t
+	}
+}
+
+// WithQueryLog set the query log.
+func WithQueryLog(q QueryLog) Option {
+	return func(a *API) {
+		a.queryLog = q
+	}
+}
+
+// WithBlocklists set the blocklists source.
+func WithBlocklists(b BlocklistsFunc) Option {
+	return func(a *API) {
+		a.blocklists = b
+	}
+}
+
+// WithBlocklistUpdates set how blocklist updates are reported and rolled
+// back.
+func WithBlocklistUpdates(u BlocklistUpdates) Option {
+	return func(a *API) {
+		a.updates = u
+	}
+}
+
+// WithSchedules set the schedules source.
+func WithSchedules(s SchedulesFunc) Option {
+	return func(a *API) {
+		a.schedules = s
+	}
+}
+
+// WithStats set the stats source.
+func WithStats(s StatsFunc) Option {
+	return func(a *API) {
+		a.stats = s
+	}
+}
+
+// WithConfig set the configuration source.
+func WithConfig(c ConfigFunc) Option {
+	return func(a *API) {
+		a.config = c
+	}
+}
+
+// WithReload set the reload function.
+func WithReload(r handlers.ReloadFunc) Option {
+	return func(a *API) {
+		a.reload = r
+	}
+}
+
+// WithBackup set the backup function.
+func WithBackup(b handlers.BackupFunc) Option {
+	return func(a *API) {
+		a.backup = b
+	}
+}
+
+// WithAuth set how requests are authenticated.
+func WithAuth(auth *Auth) Option {
+	return func(a *API) {
+		a.auth = auth
+	}
+}
+
+// New create admin API.
+func New(opts ...Option) *API {
+	a := &API{
+		auth: NewAuth(),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	// setup default logger
+	if a.logger == nil {
+		a.logger = log.New()
+		a.logger.Info("Using default logger")
+	}
+
+	return a
+}
+
+// Handler returns the API handler, every route is authenticated.
+func (a *API) Handler() http.Handler {
+	router := mux.NewRouter()
+
+	api := router.PathPrefix("/api/v1").Subrouter()
+	api.Use(a.auth.Middleware(a.logger))
+	api.HandleFunc("/openapi.yaml", a.openAPI).Methods(http.MethodGet)
+
+	if a.certificates != nil {
+		api.HandleFunc("/certificates", a.listCertificates).Methods(http.MethodGet)
+		api.HandleFunc("/certificates/{name}", a.getCertificate).Methods(http.MethodGet)
+		api.HandleFunc("/certificates/{name}", a.deleteCertificate).Methods(http.MethodDelete)
+	}
+	if a.ca != nil {
+		api.HandleFunc("/ca", a.listCA).Methods(http.MethodGet)
+	}
+	if a.domains != nil {
+		api.HandleFunc("/domains/{name}/block", a.blockDomain).Methods(http.MethodPost)
+		api.HandleFunc("/domains/{name}/allow", a.allowDomain).Methods(http.MethodPost)
+	}
+	if a.traffic != nil {
+		api.HandleFunc("/stats/top-domains", a.topDomains).Methods(http.MethodGet)
+		api.HandleFunc("/stats/top-clients", a.topClients).Methods(http.MethodGet)
+		api.HandleFunc("/stats/top-entities", a.topEntities).Methods(http.MethodGet)
+		api.HandleFunc("/stats/volume", a.volume).Methods(http.MethodGet)
+	}
+	if a.queryLog != nil {
+		api.HandleFunc("/querylog", a.searchQueryLog).Methods(http.MethodGet)
+	}
+	if a.blocklists != nil {
+		api.HandleFunc("/blocklists", a.listBlocklists).Methods(http.MethodGet)
+	}
+	if a.updates != nil {
+		api.HandleFunc("/blocklists/diff", a.blocklistsDiff).Methods(http.MethodGet)
+		api.HandleFunc("/blocklists/rollback", a.rollbackBlocklists).Methods(http.MethodPost)
+	}
+	if a.schedules != nil {
+		api.HandleFunc("/schedules", a.listSchedules).Methods(http.MethodGet)
+	}
+	if a.stats != nil {
+		api.HandleFunc("/stats", a.getStats).Methods(http.MethodGet)
+	}
+	if a.config != nil {
+		api.HandleFunc("/config", a.getConfig).Methods(http.MethodGet)
+	}
+	if a.reload != nil {
+		api.Handle("/reload", handlers.NewReloadHandler(a.logger, a.reload))
+	}
+	if a.backup != nil {
+		api.Handle("/db/backup", handlers.NewBackupHandler(a.logger, a.backup)).Methods(http.MethodGet)
+	}
+
+	return router
+}
+
+func (a *API) openAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	if _, err := w.Write(OpenAPI); err != nil {
+		a.logger.Error("Unable to write OpenAPI description", fields.Error(err))
+	}
+}
+
+func (a *API) listBlocklists(w http.ResponseWriter, r *http.Request) {
+	blocklists, err := a.blocklists(r.Context())
+	if err != nil {
+		a.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	a.writeJSON(w, http.StatusOK, blocklists)
+}
+
+func (a *API) listSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := a.schedules(r.Context())
+	if err != nil {
+		a.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	a.writeJSON(w, http.StatusOK, schedules)
+}
+
+func (a *API) getStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := a.stats(r.Context())
+	if err != nil {
+		a.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	a.writeJSON(w, http.StatusOK, stats)
+}
+
+func (a *API) getConfig(w http.ResponseWriter, _ *http.Request) {
+	a.writeJSON(w, http.StatusOK, a.config())
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (a *API) writeError(w http.ResponseWriter, status int, err error) {
+	a.writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func (a *API) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		a.logger.Error("Unable to write admin API response", fields.Error(err))
+	}
+}
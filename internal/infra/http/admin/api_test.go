@@ -0,0 +1,344 @@
+package admin_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/app/blocklist"
+	"go.pixelfactory.io/needle/internal/app/pki"
+	"go.pixelfactory.io/needle/internal/infra/coredns"
+	"go.pixelfactory.io/needle/internal/infra/http/admin"
+	"go.pixelfactory.io/needle/internal/infra/querylog"
+	"go.pixelfactory.io/needle/internal/infra/stats"
+	mocks "go.pixelfactory.io/needle/mocks/pki"
+	"go.pixelfactory.io/needle/testdata"
+	"go.pixelfactory.io/pkg/observability/log"
+)
+
+func request(t *testing.T, h http.Handler, method, path, token string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(context.Background(), method, path, http.NoBody)
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+// updates reports a fixed diff and rolls back once.
+type updates struct {
+	rolledBack bool
+}
+
+func (u *updates) LastDiff() (blocklist.Diff, bool) {
+	return blocklist.Diff{Added: []string{"*.ads.needle.local"}, AddedCount: 1}, true
+}
+
+func (u *updates) Rollback(_ context.Context) error {
+	if u.rolledBack {
+		return blocklist.ErrNoRollback
+	}
+	u.rolledBack = true
+	return nil
+}
+
+func Test_API(t *testing.T) {
+	is := require.New(t)
+
+	rootCA, testCert := testdata.Setup(t)
+	repo := mocks.NewRepository(t)
+
+	traffic := stats.New(stats.WithEntities(func(string) string { return "Needle Ads" }))
+	traffic.Record("ads.needle.local", "192.168.1.10")
+
+	hostsFile := filepath.Join(t.TempDir(), "hosts")
+
+	queryLog, err := querylog.Open(querylog.WithDir(t.TempDir()), querylog.WithLogger(log.New()))
+	is.NoError(err)
+	queryLog.Record(querylog.Entry{Kind: querylog.KindDNS, Client: "192.168.1.10", Domain: "ads.needle.local"})
+	queryLog.Record(querylog.Entry{
+		Kind: querylog.KindHTTP, Client: "192.168.1.11", Domain: "ads.needle.local",
+		Entity: "Needle Ads", Categories: []string{"ads"},
+	})
+
+	api := admin.New(
+		admin.WithLogger(log.New()),
+		admin.WithAuth(admin.NewAuth(admin.WithTokens("s3cr3t"))),
+		admin.WithCertificates(repo),
+		admin.WithCA(func() ([]*x509.Certificate, error) {
+			return []*x509.Certificate{rootCA.Leaf}, nil
+		}),
+		admin.WithDomains(coredns.NewHosts(hostsFile)),
+		admin.WithTraffic(traffic),
+		admin.WithQueryLog(queryLog),
+		admin.WithBlocklists(func(_ context.Context) ([]admin.Blocklist, error) {
+			return []admin.Blocklist{{Name: "hosts", Source: "data/hosts", Entries: 2}}, nil
+		}),
+		admin.WithBlocklistUpdates(&updates{}),
+		admin.WithSchedules(func(_ context.Context) ([]admin.Schedule, error) {
+			return []admin.Schedule{{
+				Name:       "school-nights",
+				Windows:    []string{"sun-thu 21:00-07:00"},
+				Timezone:   "Europe/Paris",
+				Active:     true,
+				Groups:     []string{"kids"},
+				Blocklists: []string{},
+			}}, nil
+		}),
+		admin.WithStats(func(_ context.Context) (map[string]float64, error) {
+			return map[string]float64{"needle_certificates_stored": 1}, nil
+		}),
+		admin.WithConfig(func() map[string]any {
+			return map[string]any{"log-level": "info"}
+		}),
+		admin.WithReload(func() error { return nil }),
+	)
+	h := api.Handler()
+
+	t.Run("OpenAPI", func(_ *testing.T) {
+		rr := request(t, h, http.MethodGet, "/api/v1/openapi.yaml", "")
+		is.Equal(http.StatusUnauthorized, rr.Code)
+
+		rr = request(t, h, http.MethodGet, "/api/v1/openapi.yaml", "s3cr3t")
+		is.Equal(http.StatusOK, rr.Code)
+		is.Contains(rr.Body.String(), "openapi: 3.1.0")
+	})
+
+	t.Run("Unauthenticated", func(_ *testing.T) {
+		rr := request(t, h, http.MethodGet, "/api/v1/certificates", "")
+		is.Equal(http.StatusUnauthorized, rr.Code)
+		is.Contains(rr.Header().Get("WWW-Authenticate"), "Bearer")
+
+		rr = request(t, h, http.MethodGet, "/api/v1/certificates", "wrong")
+		is.Equal(http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("Basic credentials", func(_ *testing.T) {
+		rr := request(t, h, http.MethodGet, "/api/v1/openapi.yaml", "")
+		is.Contains(rr.Header().Values("WWW-Authenticate"), `Basic realm="needle", charset="UTF-8"`)
+
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/api/v1/openapi.yaml", http.NoBody)
+		is.NoError(err)
+		req.SetBasicAuth("admin", "wrong")
+		rr = httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		is.Equal(http.StatusUnauthorized, rr.Code)
+
+		req.SetBasicAuth("admin", "s3cr3t")
+		rr = httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		is.Equal(http.StatusOK, rr.Code)
+	})
+
+	t.Run("Cross-origin requests", func(_ *testing.T) {
+		post := func(headers map[string]string) int {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, "http://127.0.0.1:8081/api/v1/reload", http.NoBody)
+			is.NoError(err)
+			req.SetBasicAuth("admin", "s3cr3t")
+			for k, v := range headers {
+				req.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			return rr.Code
+		}
+
+		is.Equal(http.StatusForbidden, post(map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "https://evil.example"}))
+		is.Equal(http.StatusForbidden, post(map[string]string{"Sec-Fetch-Site": "same-site"}))
+		is.Equal(http.StatusForbidden, post(map[string]string{"Origin": "https://evil.example"}))
+		is.Equal(http.StatusForbidden, post(map[string]string{"Origin": "null"}))
+
+		is.Equal(http.StatusOK, post(map[string]string{"Sec-Fetch-Site": "same-origin", "Origin": "http://127.0.0.1:8081"}))
+		is.Equal(http.StatusOK, post(map[string]string{"Origin": "http://127.0.0.1:8081"}))
+		is.Equal(http.StatusOK, post(nil))
+	})
+
+	t.Run("List certificates", func(_ *testing.T) {
+		repo.On("List", mock.Anything).Return([]*pki.InternalCert{testCert}, nil).Once()
+
+		rr := request(t, h, http.MethodGet, "/api/v1/certificates", "s3cr3t")
+		is.Equal(http.StatusOK, rr.Code)
+		is.NotContains(rr.Body.String(), "PRIVATE KEY")
+
+		var certs []admin.Certificate
+		is.NoError(json.NewDecoder(rr.Body).Decode(&certs))
+		is.Len(certs, 1)
+		is.Equal("test.needle.local", certs[0].Name)
+		is.Empty(certs[0].Error)
+		is.False(certs[0].NotAfter.IsZero())
+	})
+
+	t.Run("Get certificate", func(_ *testing.T) {
+		repo.On("Get", mock.Anything, "missing.needle.local").Return(nil, pki.ErrCertificateNotFound).Once()
+
+		rr := request(t, h, http.MethodGet, "/api/v1/certificates/missing.needle.local", "s3cr3t")
+		is.Equal(http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("Delete certificate", func(_ *testing.T) {
+		repo.On("Delete", mock.Anything, "test.needle.local").Return(nil).Once()
+
+		rr := request(t, h, http.MethodDelete, "/api/v1/certificates/test.needle.local", "s3cr3t")
+		is.Equal(http.StatusNoContent, rr.Code)
+	})
+
+	t.Run("Blocklists, stats and config", func(_ *testing.T) {
+		rr := request(t, h, http.MethodGet, "/api/v1/blocklists", "s3cr3t")
+		is.Equal(http.StatusOK, rr.Code)
+		is.JSONEq(`[{"name":"hosts","source":"data/hosts","entries":2}]`, rr.Body.String())
+
+		rr = request(t, h, http.MethodGet, "/api/v1/stats", "s3cr3t")
+		is.Equal(http.StatusOK, rr.Code)
+		is.JSONEq(`{"needle_certificates_stored":1}`, rr.Body.String())
+
+		rr = request(t, h, http.MethodGet, "/api/v1/config", "s3cr3t")
+		is.Equal(http.StatusOK, rr.Code)
+		is.JSONEq(`{"log-level":"info"}`, rr.Body.String())
+	})
+
+	t.Run("Schedules", func(_ *testing.T) {
+		rr := request(t, h, http.MethodGet, "/api/v1/schedules", "s3cr3t")
+		is.Equal(http.StatusOK, rr.Code)
+		is.JSONEq(`[{"name":"school-nights","windows":["sun-thu 21:00-07:00"],"timezone":"Europe/Paris",`+
+			`"active":true,"groups":["kids"],"blocklists":[]}]`, rr.Body.String())
+	})
+
+	t.Run("Blocklist updates", func(_ *testing.T) {
+		rr := request(t, h, http.MethodGet, "/api/v1/blocklists/diff", "s3cr3t")
+		is.Equal(http.StatusOK, rr.Code)
+
+		var diff admin.BlocklistDiff
+		is.NoError(json.NewDecoder(rr.Body).Decode(&diff))
+		is.Equal([]string{"*.ads.needle.local"}, diff.Added)
+		is.Empty(diff.Removed)
+
+		rr = request(t, h, http.MethodPost, "/api/v1/blocklists/rollback", "s3cr3t")
+		is.Equal(http.StatusNoContent, rr.Code)
+
+		rr = request(t, h, http.MethodPost, "/api/v1/blocklists/rollback", "s3cr3t")
+		is.Equal(http.StatusConflict, rr.Code)
+	})
+
+	t.Run("CA", func(_ *testing.T) {
+		rr := request(t, h, http.MethodGet, "/api/v1/ca", "s3cr3t")
+		is.Equal(http.StatusOK, rr.Code)
+
+		var ca []admin.CA
+		is.NoError(json.NewDecoder(rr.Body).Decode(&ca))
+		is.Len(ca, 1)
+		is.True(ca[0].Active)
+		is.Equal(rootCA.Leaf.NotAfter.UTC(), ca[0].NotAfter.UTC())
+	})
+
+	t.Run("Traffic", func(_ *testing.T) {
+		rr := request(t, h, http.MethodGet, "/api/v1/stats/top-domains?limit=5", "s3cr3t")
+		is.Equal(http.StatusOK, rr.Code)
+		is.JSONEq(`[{"key":"ads.needle.local","count":1}]`, rr.Body.String())
+
+		rr = request(t, h, http.MethodGet, "/api/v1/stats/top-clients", "s3cr3t")
+		is.Equal(http.StatusOK, rr.Code)
+		is.JSONEq(`[{"key":"192.168.1.10","count":1}]`, rr.Body.String())
+
+		rr = request(t, h, http.MethodGet, "/api/v1/stats/top-entities", "s3cr3t")
+		is.Equal(http.StatusOK, rr.Code)
+		is.JSONEq(`[{"key":"Needle Ads","count":1}]`, rr.Body.String())
+
+		rr = request(t, h, http.MethodGet, "/api/v1/stats/volume?window=1h&step=5m", "s3cr3t")
+		is.Equal(http.StatusOK, rr.Code)
+
+		var points []stats.Point
+		is.NoError(json.NewDecoder(rr.Body).Decode(&points))
+		is.GreaterOrEqual(len(points), 12)
+
+		rr = request(t, h, http.MethodGet, "/api/v1/stats/top-domains?limit=0", "s3cr3t")
+		is.Equal(http.StatusBadRequest, rr.Code)
+
+		rr = request(t, h, http.MethodGet, "/api/v1/stats/volume?step=1s", "s3cr3t")
+		is.Equal(http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("Query log", func(_ *testing.T) {
+		rr := request(t, h, http.MethodGet, "/api/v1/querylog?domain=needle.local&client=192.168.1.10", "s3cr3t")
+		is.Equal(http.StatusOK, rr.Code)
+
+		var entries []querylog.Entry
+		is.NoError(json.NewDecoder(rr.Body).Decode(&entries))
+		is.Len(entries, 1)
+		is.Equal(querylog.KindDNS, entries[0].Kind)
+
+		rr = request(t, h, http.MethodGet, "/api/v1/querylog?entity=needle+ads&category=ads", "s3cr3t")
+		is.Equal(http.StatusOK, rr.Code)
+		is.NoError(json.NewDecoder(rr.Body).Decode(&entries))
+		is.Len(entries, 1)
+		is.Equal(querylog.KindHTTP, entries[0].Kind)
+
+		rr = request(t, h, http.MethodGet, "/api/v1/querylog?since=yesterday", "s3cr3t")
+		is.Equal(http.StatusBadRequest, rr.Code)
+
+		rr = request(t, h, http.MethodGet, "/api/v1/querylog?kind=smtp", "s3cr3t")
+		is.Equal(http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("Domains", func(_ *testing.T) {
+		rr := request(t, h, http.MethodPost, "/api/v1/domains/ads.needle.local/block", "s3cr3t")
+		is.Equal(http.StatusNoContent, rr.Code)
+
+		count, err := coredns.CountHosts(hostsFile)
+		is.NoError(err)
+		is.Equal(1, count)
+
+		rr = request(t, h, http.MethodPost, "/api/v1/domains/ads.needle.local/allow", "s3cr3t")
+		is.Equal(http.StatusNoContent, rr.Code)
+
+		count, err = coredns.CountHosts(hostsFile)
+		is.NoError(err)
+		is.Equal(0, count)
+
+		rr = request(t, h, http.MethodPost, "/api/v1/domains/ads%0Aneedle.local/block", "s3cr3t")
+		is.Equal(http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("Reload", func(_ *testing.T) {
+		rr := request(t, h, http.MethodPost, "/api/v1/reload", "s3cr3t")
+		is.Equal(http.StatusOK, rr.Code)
+	})
+
+	t.Run("Unconfigured route", func(_ *testing.T) {
+		rr := request(t, h, http.MethodGet, "/api/v1/db/backup", "s3cr3t")
+		is.Equal(http.StatusNotFound, rr.Code)
+	})
+}
+
+func Test_ClientCertificates(t *testing.T) {
+	is := require.New(t)
+
+	handler := admin.NewAuth(admin.WithClientCertificates()).Middleware(log.New())(
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/api/v1/stats", http.NoBody)
+	is.NoError(err)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	is.Equal(http.StatusUnauthorized, rr.Code)
+
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	is.Equal(http.StatusOK, rr.Code)
+}
@@ -0,0 +1,161 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.pixelfactory.io/pkg/observability/log"
+	"go.pixelfactory.io/pkg/observability/log/fields"
+)
+
+// Auth authenticates admin API requests with bearer tokens or verified client certificates.
+// Tokens are also accepted as the password of basic credentials, which
+// browsers ask for when they load the dashboard.
+type Auth struct {
+	tokens      [][]byte
+	clientCerts bool
+}
+
+// AuthOption type.
+type AuthOption func(*Auth)
+
+// WithTokens accept requests with an "Authorization: Bearer <token>" header matching one of tokens.
+func WithTokens(tokens ...string) AuthOption {
+	return func(a *Auth) {
+		for _, token := range tokens {
+			if token != "" {
+				a.tokens = append(a.tokens, []byte(token))
+			}
+		}
+	}
+}
+
+// WithClientCertificates accept requests with a client certificate verified by the TLS listener.
+func WithClientCertificates() AuthOption {
+	return func(a *Auth) {
+		a.clientCerts = true
+	}
+}
+
+// NewAuth create Auth, without options every request is accepted.
+func NewAuth(opts ...AuthOption) *Auth {
+	a := &Auth{}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Enabled returns true when requests must be authenticated.
+func (a *Auth) Enabled() bool {
+	return len(a.tokens) > 0 || a.clientCerts
+}
+
+// Middleware rejects unauthenticated requests with 401, and state-changing
+// requests sent by pages of another origin with 403.
+func (a *Auth) Middleware(logger log.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if crossOrigin(r) {
+				logger.Info("Cross-origin admin API request",
+					fields.String("remote_addr", r.RemoteAddr), fields.String("path", r.URL.Path))
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			if !a.Enabled() || a.authenticated(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			logger.Info("Unauthenticated admin API request",
+				fields.String("remote_addr", r.RemoteAddr), fields.String("path", r.URL.Path))
+			w.Header().Set("WWW-Authenticate", `Bearer realm="needle"`)
+			w.Header().Add("WWW-Authenticate", `Basic realm="needle", charset="UTF-8"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// crossOrigin reports whether r changes state and was sent by a page of
+// another origin. Browsers send such requests with the basic credentials
+// or the client certificate of the dashboard, clients such as curl send
+// neither Sec-Fetch-Site nor Origin.
+func crossOrigin(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site != "same-origin" && site != "none"
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	return err != nil || u.Host != r.Host
+}
+
+func (a *Auth) authenticated(r *http.Request) bool {
+	if a.clientCerts && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return true
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		// the user name of basic credentials is ignored
+		if _, token, ok = r.BasicAuth(); !ok {
+			return false
+		}
+	}
+
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), t) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// TLSConfig returns the admin listener tls.Config, client certificates
+// signed by clientCAFile are requested and verified when it is set.
+func TLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "admin.TLSConfig")
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if clientCAFile != "" {
+		pemCerts, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "admin.TLSConfig")
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemCerts) {
+			return nil, errors.Errorf("admin.TLSConfig: no certificate found in %s", clientCAFile)
+		}
+
+		// token authenticated clients connect without a certificate
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		config.ClientCAs = pool
+	}
+
+	return config, nil
+}
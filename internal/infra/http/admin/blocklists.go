@@ -0,0 +1,69 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.pixelfactory.io/needle/internal/app/blocklist"
+)
+
+// BlocklistUpdates reports and rolls back blocklist updates.
+type BlocklistUpdates interface {
+	LastDiff() (blocklist.Diff, bool)
+	Rollback(ctx context.Context) error
+}
+
+// BlocklistDiff describes the domains added and removed by the last
+// blocklists update.
+type BlocklistDiff struct {
+	Time         time.Time `json:"time"`
+	Added        []string  `json:"added"`
+	Removed      []string  `json:"removed"`
+	AddedCount   int       `json:"added_count"`
+	RemovedCount int       `json:"removed_count"`
+	Rollback     bool      `json:"rollback"`
+}
+
+// errNoBlocklistUpdate no blocklists were loaded yet.
+var errNoBlocklistUpdate = errors.New("no blocklists update")
+
+func (a *API) blocklistsDiff(w http.ResponseWriter, _ *http.Request) {
+	diff, ok := a.updates.LastDiff()
+	if !ok {
+		a.writeError(w, http.StatusNotFound, errNoBlocklistUpdate)
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, BlocklistDiff{
+		Time:         diff.Time,
+		Added:        nonNil(diff.Added),
+		Removed:      nonNil(diff.Removed),
+		AddedCount:   diff.AddedCount,
+		RemovedCount: diff.RemovedCount,
+		Rollback:     diff.Rollback,
+	})
+}
+
+func (a *API) rollbackBlocklists(w http.ResponseWriter, r *http.Request) {
+	err := a.updates.Rollback(r.Context())
+	switch {
+	case errors.Is(err, blocklist.ErrNoRollback):
+		a.writeError(w, http.StatusConflict, err)
+		return
+	case err != nil:
+		a.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// nonNil encodes empty lists as [] rather than null.
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
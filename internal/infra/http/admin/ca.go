@@ -0,0 +1,39 @@
+package admin
+
+import (
+	"crypto/x509"
+	"net/http"
+	"time"
+)
+
+// CAFunc returns the active CA certificate followed by the trusted ones.
+type CAFunc func() ([]*x509.Certificate, error)
+
+// CA describes a CA certificate.
+type CA struct {
+	Subject      string    `json:"subject"`
+	SerialNumber string    `json:"serial_number"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	Active       bool      `json:"active"`
+}
+
+func (a *API) listCA(w http.ResponseWriter, _ *http.Request) {
+	certs, err := a.ca()
+	if err != nil {
+		a.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := make([]CA, 0, len(certs))
+	for i, cert := range certs {
+		resp = append(resp, CA{
+			Subject:      cert.Subject.String(),
+			SerialNumber: cert.SerialNumber.Text(16),
+			NotBefore:    cert.NotBefore,
+			NotAfter:     cert.NotAfter,
+			Active:       i == 0,
+		})
+	}
+	a.writeJSON(w, http.StatusOK, resp)
+}
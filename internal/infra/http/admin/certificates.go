@@ -0,0 +1,99 @@
+package admin
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"go.pixelfactory.io/needle/internal/app/pki"
+)
+
+// Certificate describes a stored certificate, private keys are never exposed.
+type Certificate struct {
+	Name         string    `json:"name"`
+	SerialNumber string    `json:"serial_number,omitempty"`
+	Issuer       string    `json:"issuer,omitempty"`
+	DNSNames     []string  `json:"dns_names,omitempty"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// newCertificate parses cert, a certificate which does not parse is reported with its error.
+func newCertificate(cert *pki.InternalCert) Certificate {
+	c := Certificate{Name: cert.Name}
+
+	block, _ := pem.Decode(cert.CertPEM)
+	if block == nil {
+		c.Error = "invalid PEM"
+		return c
+	}
+
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		c.Error = err.Error()
+		return c
+	}
+
+	c.SerialNumber = leaf.SerialNumber.Text(16)
+	c.Issuer = leaf.Issuer.CommonName
+	c.DNSNames = leaf.DNSNames
+	c.NotBefore = leaf.NotBefore
+	c.NotAfter = leaf.NotAfter
+	return c
+}
+
+func (a *API) listCertificates(w http.ResponseWriter, r *http.Request) {
+	certs, err := a.certificates.List(r.Context())
+	var unreadable pki.EntryErrors
+	if err != nil && !errors.As(err, &unreadable) {
+		a.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := make([]Certificate, 0, len(certs)+len(unreadable))
+	for _, cert := range certs {
+		resp = append(resp, newCertificate(cert))
+	}
+	for name, err := range unreadable {
+		resp = append(resp, Certificate{Name: name, Error: err.Error()})
+	}
+	sort.Slice(resp, func(i, j int) bool {
+		return resp[i].Name < resp[j].Name
+	})
+
+	a.writeJSON(w, http.StatusOK, resp)
+}
+
+func (a *API) getCertificate(w http.ResponseWriter, r *http.Request) {
+	cert, err := a.certificates.Get(r.Context(), mux.Vars(r)["name"])
+	if errors.Is(err, pki.ErrCertificateNotFound) {
+		a.writeError(w, http.StatusNotFound, pki.ErrCertificateNotFound)
+		return
+	}
+	if err != nil {
+		a.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, newCertificate(cert))
+}
+
+func (a *API) deleteCertificate(w http.ResponseWriter, r *http.Request) {
+	err := a.certificates.Delete(r.Context(), mux.Vars(r)["name"])
+	if errors.Is(err, pki.ErrCertificateNotFound) {
+		a.writeError(w, http.StatusNotFound, pki.ErrCertificateNotFound)
+		return
+	}
+	if err != nil {
+		a.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
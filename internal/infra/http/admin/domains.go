@@ -0,0 +1,49 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+var domainRegexp = regexp.MustCompile(`^(?i)[a-z0-9_]([a-z0-9_-]{0,61}[a-z0-9_])?(\.[a-z0-9_]([a-z0-9_-]{0,61}[a-z0-9_])?)*\.?$`)
+
+// errInvalidDomain the requested name is not a domain name.
+var errInvalidDomain = errors.New("invalid domain name")
+
+// Domains blocks and allows domains.
+type Domains interface {
+	Block(ctx context.Context, name string) error
+	Allow(ctx context.Context, name string) error
+}
+
+func (a *API) blockDomain(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if len(name) > 253 || !domainRegexp.MatchString(name) {
+		a.writeError(w, http.StatusBadRequest, errInvalidDomain)
+		return
+	}
+
+	if err := a.domains.Block(r.Context(), name); err != nil {
+		a.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) allowDomain(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if len(name) > 253 || !domainRegexp.MatchString(name) {
+		a.writeError(w, http.StatusBadRequest, errInvalidDomain)
+		return
+	}
+
+	if err := a.domains.Allow(r.Context(), name); err != nil {
+		a.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
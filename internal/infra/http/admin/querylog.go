@@ -0,0 +1,73 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.pixelfactory.io/needle/internal/infra/querylog"
+)
+
+// QueryLog searches logged requests and queries.
+type QueryLog interface {
+	Query(ctx context.Context, f querylog.Filter) ([]querylog.Entry, error)
+}
+
+const (
+	defaultQueryLogLimit = 100
+	maxQueryLogLimit     = 1000
+)
+
+func (a *API) searchQueryLog(w http.ResponseWriter, r *http.Request) {
+	f, err := queryLogFilter(r)
+	if err != nil {
+		a.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	entries, err := a.queryLog.Query(r.Context(), f)
+	if err != nil {
+		a.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	a.writeJSON(w, http.StatusOK, entries)
+}
+
+func queryLogFilter(r *http.Request) (querylog.Filter, error) {
+	q := r.URL.Query()
+	f := querylog.Filter{
+		Kind:     querylog.Kind(q.Get("kind")),
+		Client:   q.Get("client"),
+		Domain:   q.Get("domain"),
+		Entity:   q.Get("entity"),
+		Category: q.Get("category"),
+		Limit:    defaultQueryLogLimit,
+	}
+
+	if f.Kind != "" && f.Kind != querylog.KindHTTP && f.Kind != querylog.KindDNS {
+		return f, errors.Errorf("kind must be %s or %s", querylog.KindHTTP, querylog.KindDNS)
+	}
+
+	var err error
+	if v := q.Get("since"); v != "" {
+		if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, errors.Wrap(err, "since")
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if f.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, errors.Wrap(err, "until")
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		f.Limit, err = strconv.Atoi(v)
+		if err != nil || f.Limit < 1 || f.Limit > maxQueryLogLimit {
+			return f, errors.Errorf("limit must be between 1 and %d", maxQueryLogLimit)
+		}
+	}
+
+	return f, nil
+}
@@ -0,0 +1,99 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.pixelfactory.io/needle/internal/infra/stats"
+)
+
+// Traffic gives access to blocked traffic statistics.
+type Traffic interface {
+	TopDomains(n int) []stats.Count
+	TopClients(n int) []stats.Count
+	TopEntities(n int) []stats.Count
+	Volume(window, step time.Duration) []stats.Point
+}
+
+const (
+	defaultTopLimit = 10
+	maxTopLimit     = 1000
+	defaultWindow   = 24 * time.Hour
+	defaultStep     = time.Hour
+)
+
+func (a *API) topDomains(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryLimit(r)
+	if err != nil {
+		a.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	a.writeJSON(w, http.StatusOK, a.traffic.TopDomains(limit))
+}
+
+func (a *API) topClients(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryLimit(r)
+	if err != nil {
+		a.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	a.writeJSON(w, http.StatusOK, a.traffic.TopClients(limit))
+}
+
+func (a *API) topEntities(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryLimit(r)
+	if err != nil {
+		a.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	a.writeJSON(w, http.StatusOK, a.traffic.TopEntities(limit))
+}
+
+func (a *API) volume(w http.ResponseWriter, r *http.Request) {
+	window, err := queryDuration(r, "window", defaultWindow)
+	if err != nil {
+		a.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	step, err := queryDuration(r, "step", defaultStep)
+	if err != nil {
+		a.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if step < time.Minute {
+		a.writeError(w, http.StatusBadRequest, errors.New("step must be at least 1m"))
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, a.traffic.Volume(window, step))
+}
+
+func queryLimit(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return defaultTopLimit, nil
+	}
+
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 1 || limit > maxTopLimit {
+		return 0, errors.Errorf("limit must be between 1 and %d", maxTopLimit)
+	}
+	return limit, nil
+}
+
+func queryDuration(r *http.Request, name string, def time.Duration) (time.Duration, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, errors.Wrap(err, name)
+	}
+	return d, nil
+}
@@ -0,0 +1,267 @@
+// Package blockpage serves an HTML page to top-level navigations to blocked
+// domains, from which the domain can be allowed for a while.
+package blockpage
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"html/template"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.pixelfactory.io/pkg/observability/log"
+	"go.pixelfactory.io/pkg/observability/log/fields"
+)
+
+// UnblockPath receives the unblock form on the blocked domain itself.
+const UnblockPath = "/.needle/unblock"
+
+//go:embed page.html.tmpl
+var pageTemplate string
+
+var page = template.Must(template.New("page").Parse(pageTemplate))
+
+// contentSecurityPolicy only allows the page's inline styles and posting its form.
+const contentSecurityPolicy = "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'; " +
+	"frame-ancestors 'none'; base-uri 'none'"
+
+// LookupFunc returns the name of the list blocking domain for client, empty
+// when unknown. blocked is false when domain is not blocked for client.
+type LookupFunc func(ctx context.Context, client net.IP, domain string) (list string, blocked bool, err error)
+
+// Unblocker allows a domain for a while.
+type Unblocker interface {
+	Allow(ctx context.Context, name string, d time.Duration) error
+}
+
+// BlockPage holds block page dependencies.
+type BlockPage struct {
+	logger     log.Logger
+	lookup     LookupFunc
+	unblocker  Unblocker
+	passphrase string
+	duration   time.Duration
+	refresh    time.Duration
+
+	// failures serializes wrong passphrases to slow down guessing
+	failures sync.Mutex
+	penalty  time.Duration
+}
+
+// Option type.
+type Option func(*BlockPage)
+
+// WithLogger set logger.
+func WithLogger(l log.Logger) Option {
+	return func(b *BlockPage) {
+		b.logger = l
+	}
+}
+
+// WithLookup set how the list blocking a domain is found.
+func WithLookup(fn LookupFunc) Option {
+	return func(b *BlockPage) {
+		b.lookup = fn
+	}
+}
+
+// WithUnblock lets users knowing passphrase allow a domain for d.
+func WithUnblock(u Unblocker, passphrase string, d time.Duration) Option {
+	return func(b *BlockPage) {
+		b.unblocker = u
+		b.passphrase = passphrase
+		b.duration = d
+	}
+}
+
+// WithRefresh set how long the page waits before reloading an allowed domain.
+func WithRefresh(d time.Duration) Option {
+	return func(b *BlockPage) {
+		b.refresh = d
+	}
+}
+
+// New create BlockPage.
+func New(opts ...Option) *BlockPage {
+	b := &BlockPage{
+		refresh: 15 * time.Second,
+		penalty: time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	// setup default logger
+	if b.logger == nil {
+		b.logger = log.New()
+		b.logger.Info("Using default logger")
+	}
+
+	return b
+}
+
+type pageData struct {
+	Domain         string
+	List           string
+	URL            string
+	UnblockPath    string
+	CanUnblock     bool
+	Allowed        bool
+	NotBlocked     bool
+	Duration       string
+	RefreshSeconds int
+	Error          string
+}
+
+// Handler serves the block page to navigations and the unblock form,
+// other requests are passed to next.
+func (b *BlockPage) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == UnblockPath && b.canUnblock():
+			b.unblock(w, r)
+		case isNavigation(r):
+			b.render(w, r, http.StatusOK, pageData{URL: r.URL.RequestURI()})
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// isNavigation reports whether r loads a top-level page.
+func isNavigation(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	switch r.Header.Get("Sec-Fetch-Dest") {
+	case "document":
+		return true
+	case "":
+		return strings.Contains(r.Header.Get("Accept"), "text/html")
+	default:
+		return false
+	}
+}
+
+func (b *BlockPage) canUnblock() bool {
+	return b.unblocker != nil && b.passphrase != "" && b.duration > 0
+}
+
+func (b *BlockPage) unblock(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 4096)
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	data := pageData{URL: localURL(r.PostFormValue("url"))}
+	passphrase := r.PostFormValue("passphrase")
+	if subtle.ConstantTimeCompare([]byte(passphrase), []byte(b.passphrase)) != 1 {
+		b.failures.Lock()
+		time.Sleep(b.penalty)
+		b.failures.Unlock()
+
+		data.Error = "Wrong passphrase."
+		b.render(w, r, http.StatusForbidden, data)
+		return
+	}
+
+	domain := hostname(r)
+	if err := b.unblocker.Allow(r.Context(), domain, b.duration); err != nil {
+		b.logger.Error("Unable to allow domain", fields.String("domain", domain), fields.Error(err))
+		data.Error = "The domain could not be allowed, see needle logs."
+		b.render(w, r, http.StatusInternalServerError, data)
+		return
+	}
+
+	data.Allowed = true
+	b.render(w, r, http.StatusOK, data)
+}
+
+func (b *BlockPage) render(w http.ResponseWriter, r *http.Request, status int, data pageData) {
+	data.Domain = hostname(r)
+	data.UnblockPath = UnblockPath
+	data.CanUnblock = b.canUnblock()
+	data.Duration = formatDuration(b.duration)
+	data.RefreshSeconds = int(b.refresh.Seconds())
+
+	if b.lookup != nil && !data.Allowed {
+		list, blocked, err := b.lookup(r.Context(), clientIP(r), data.Domain)
+		if err != nil {
+			b.logger.Error("Unable to find the list blocking domain", fields.String("domain", data.Domain), fields.Error(err))
+		}
+		// the client resolved the domain before it was allowed for it
+		data.NotBlocked = err == nil && !blocked
+		data.List = list
+	}
+	if data.List == "" {
+		data.List = "a blocklist"
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", contentSecurityPolicy)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := page.Execute(w, data); err != nil {
+		b.logger.Error("Unable to render block page", fields.Error(err))
+	}
+}
+
+// formatDuration returns d in whole hours or minutes when possible.
+func formatDuration(d time.Duration) string {
+	plural := func(n int64, unit string) string {
+		if n == 1 {
+			return "1 " + unit
+		}
+		return strconv.FormatInt(n, 10) + " " + unit + "s"
+	}
+
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		return plural(int64(d/time.Hour), "hour")
+	case d >= time.Minute && d%time.Minute == 0:
+		return plural(int64(d/time.Minute), "minute")
+	default:
+		return d.String()
+	}
+}
+
+// hostname returns the requested domain, without port.
+func hostname(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// clientIP returns the address of the client sending r, nil when unknown.
+func clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// localURL returns u when it is a path on the same host, "/" otherwise.
+func localURL(u string) string {
+	if !strings.HasPrefix(u, "/") || strings.HasPrefix(u, "//") || strings.HasPrefix(u, "/\\") {
+		return "/"
+	}
+	return u
+}
@@ -0,0 +1,122 @@
+package blockpage_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/infra/http/blockpage"
+	"go.pixelfactory.io/pkg/observability/log"
+)
+
+type unblocker struct {
+	allowed map[string]time.Duration
+}
+
+func (u *unblocker) Allow(_ context.Context, name string, d time.Duration) error {
+	u.allowed[name] = d
+	return nil
+}
+
+func Test_BlockPage(t *testing.T) {
+	is := require.New(t)
+
+	u := &unblocker{allowed: map[string]time.Duration{}}
+	b := blockpage.New(
+		blockpage.WithLogger(log.New()),
+		blockpage.WithLookup(func(_ context.Context, client net.IP, domain string) (string, bool, error) {
+			// the TV is in a group not blocking tv.needle.local
+			if domain == "tv.needle.local" && client.Equal(net.ParseIP("192.0.2.1")) {
+				return "", false, nil
+			}
+			return "hosts file data/hosts", true, nil
+		}),
+		blockpage.WithUnblock(u, "open sesame", 10*time.Minute),
+	)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	h := b.Handler(next)
+
+	serve := func(method, target string, headers map[string]string, form url.Values) *httptest.ResponseRecorder {
+		body := strings.NewReader(form.Encode())
+		req, err := http.NewRequestWithContext(context.Background(), method, target, body)
+		is.NoError(err)
+		req.RemoteAddr = "192.0.2.1:41000"
+		if form != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Navigation", func(_ *testing.T) {
+		rr := serve(http.MethodGet, "http://Ads.Needle.local:8080/landing?id=1", map[string]string{"Sec-Fetch-Dest": "document"}, nil)
+		is.Equal(http.StatusOK, rr.Code)
+		is.Equal("text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+		is.Contains(rr.Body.String(), "<strong>ads.needle.local</strong> is listed in <strong>hosts file data/hosts</strong>")
+		is.Contains(rr.Body.String(), `action="/.needle/unblock"`)
+		is.Contains(rr.Body.String(), `value="/landing?id=1"`)
+		is.Contains(rr.Body.String(), "Allow for 10 minutes")
+
+		rr = serve(http.MethodGet, "http://ads.needle.local/", map[string]string{"Accept": "text/html,*/*"}, nil)
+		is.Equal(http.StatusOK, rr.Code)
+	})
+
+	t.Run("Not blocked for the client", func(_ *testing.T) {
+		rr := serve(http.MethodGet, "http://tv.needle.local/", map[string]string{"Sec-Fetch-Dest": "document"}, nil)
+		is.Equal(http.StatusOK, rr.Code)
+		is.Contains(rr.Body.String(), "tv.needle.local is not blocked for this device")
+		is.Contains(rr.Body.String(), `http-equiv="refresh"`)
+	})
+
+	t.Run("Subresources", func(_ *testing.T) {
+		rr := serve(http.MethodGet, "http://ads.needle.local/frame", map[string]string{"Sec-Fetch-Dest": "iframe", "Accept": "text/html"}, nil)
+		is.Equal(http.StatusTeapot, rr.Code)
+
+		rr = serve(http.MethodGet, "http://ads.needle.local/pixel.gif", map[string]string{"Accept": "image/*"}, nil)
+		is.Equal(http.StatusTeapot, rr.Code)
+	})
+
+	t.Run("Wrong passphrase", func(_ *testing.T) {
+		rr := serve(http.MethodPost, "http://ads.needle.local"+blockpage.UnblockPath, nil, url.Values{"passphrase": {"guess"}, "url": {"/"}})
+		is.Equal(http.StatusForbidden, rr.Code)
+		is.Contains(rr.Body.String(), "Wrong passphrase.")
+		is.Empty(u.allowed)
+	})
+
+	t.Run("Unblock", func(_ *testing.T) {
+		rr := serve(http.MethodPost, "http://ads.needle.local"+blockpage.UnblockPath, nil, url.Values{"passphrase": {"open sesame"}, "url": {"//evil.local/"}})
+		is.Equal(http.StatusOK, rr.Code)
+		is.Equal(10*time.Minute, u.allowed["ads.needle.local"])
+		is.Contains(rr.Body.String(), `content="15;url=/"`)
+
+		rr = serve(http.MethodGet, "http://ads.needle.local"+blockpage.UnblockPath, nil, nil)
+		is.Equal(http.StatusMethodNotAllowed, rr.Code)
+	})
+
+	t.Run("Without passphrase", func(_ *testing.T) {
+		h := blockpage.New(blockpage.WithLogger(log.New()), blockpage.WithUnblock(u, "", time.Minute)).Handler(next)
+
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://ads.needle.local/", http.NoBody)
+		is.NoError(err)
+		req.Header.Set("Sec-Fetch-Dest", "document")
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		is.Contains(rr.Body.String(), "listed in <strong>a blocklist</strong>")
+		is.NotContains(rr.Body.String(), "<form")
+	})
+}
@@ -0,0 +1,32 @@
+package dashboard_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/infra/http/dashboard"
+)
+
+func Test_Handler(t *testing.T) {
+	is := require.New(t)
+
+	h := dashboard.Handler()
+
+	for path, contentType := range map[string]string{
+		"/":          "text/html; charset=utf-8",
+		"/app.js":    "text/javascript; charset=utf-8",
+		"/style.css": "text/css; charset=utf-8",
+	} {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, path, http.NoBody)
+		is.NoError(err)
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		is.Equal(http.StatusOK, rr.Code, path)
+		is.Equal(contentType, rr.Header().Get("Content-Type"), path)
+		is.Contains(rr.Header().Get("Content-Security-Policy"), "script-src 'self'")
+	}
+}
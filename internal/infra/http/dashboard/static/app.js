@@ -0,0 +1,229 @@
+// Needle dashboard, every value is read from and every action goes through
+// the admin API (/api/v1). The browser authenticates the page and its API
+// calls with the credentials it asked for, or with a client certificate.
+"use strict";
+
+const api = "/api/v1";
+const refreshInterval = 30000;
+const expirySoon = 30 * 24 * 3600 * 1000;
+
+class Unauthorized extends Error {}
+
+async function call(method, path) {
+  const resp = await fetch(api + path, { method, credentials: "same-origin" });
+  if (resp.status === 401) {
+    throw new Unauthorized("unauthorized");
+  }
+  if (resp.status === 404 && method === "GET") {
+    // endpoint not enabled on this needle
+    return null;
+  }
+  if (!resp.ok) {
+    let message = resp.statusText;
+    try {
+      message = (await resp.json()).error || message;
+    } catch (e) {
+      // not a JSON error
+    }
+    throw new Error(message);
+  }
+  if (resp.status === 204) {
+    return null;
+  }
+  return resp.json();
+}
+
+// el creates an element, text is always set with textContent.
+function el(tag, text, className) {
+  const e = document.createElement(tag);
+  if (text !== undefined) {
+    e.textContent = text;
+  }
+  if (className) {
+    e.className = className;
+  }
+  return e;
+}
+
+function button(label, action) {
+  const b = el("button", label);
+  b.type = "button";
+  b.addEventListener("click", action);
+  return b;
+}
+
+function row(...cells) {
+  const tr = el("tr");
+  for (const cell of cells) {
+    tr.append(cell instanceof Node ? wrap(cell) : el("td", String(cell)));
+  }
+  return tr;
+}
+
+function wrap(node) {
+  const td = el("td");
+  td.append(node);
+  return td;
+}
+
+function fill(id, rows, empty) {
+  const tbody = document.getElementById(id);
+  tbody.replaceChildren(...rows);
+  if (rows.length === 0) {
+    const td = el("td", empty, "empty");
+    td.colSpan = tbody.closest("table").querySelectorAll("th").length;
+    const tr = el("tr");
+    tr.append(td);
+    tbody.append(tr);
+  }
+}
+
+function expiry(date) {
+  const d = new Date(date);
+  const span = el("span", d.toLocaleDateString());
+  if (d - Date.now() < expirySoon) {
+    span.className = "warn";
+  }
+  return span;
+}
+
+function setStatus(text, error) {
+  const status = document.getElementById("status");
+  status.textContent = text;
+  status.className = error ? "error" : "";
+}
+
+async function action(fn) {
+  try {
+    await fn();
+    await refresh();
+  } catch (e) {
+    handle(e);
+  }
+}
+
+function handle(e) {
+  if (e instanceof Unauthorized) {
+    setStatus("Unauthorized, reload the page to sign in", true);
+    return;
+  }
+  setStatus(e.message, true);
+}
+
+function renderCounters(stats) {
+  const counters = [
+    ["HTTP requests", stats["needle_http_requests_total"]],
+    ["TLS handshakes", stats["needle_tls_handshakes_total"]],
+    ["Certificates stored", stats["needle_certificates_stored"]],
+    ["DNS queries", stats["coredns_dns_requests_total"]],
+  ];
+
+  document.getElementById("counters").replaceChildren(...counters.map(([label, value]) => {
+    const card = el("div", undefined, "card");
+    card.append(el("span", value === undefined ? "–" : Math.round(value).toLocaleString(), "value"));
+    card.append(el("span", label, "label"));
+    return card;
+  }));
+}
+
+function renderVolume(points) {
+  const chart = document.getElementById("volume");
+  if (!points || points.length === 0) {
+    chart.replaceChildren(el("p", "No data", "empty"));
+    return;
+  }
+
+  const ns = "http://www.w3.org/2000/svg";
+  const width = 800;
+  const height = 160;
+  const max = Math.max(1, ...points.map((p) => p.count));
+  const barWidth = width / points.length;
+
+  const svg = document.createElementNS(ns, "svg");
+  svg.setAttribute("viewBox", `0 0 ${width} ${height}`);
+  svg.setAttribute("role", "img");
+  points.forEach((p, i) => {
+    const h = (p.count / max) * (height - 10);
+    const rect = document.createElementNS(ns, "rect");
+    rect.setAttribute("x", i * barWidth + 1);
+    rect.setAttribute("y", height - h);
+    rect.setAttribute("width", Math.max(1, barWidth - 2));
+    rect.setAttribute("height", h);
+    const title = document.createElementNS(ns, "title");
+    title.textContent = `${new Date(p.time).toLocaleString()}: ${p.count}`;
+    rect.append(title);
+    svg.append(rect);
+  });
+
+  const caption = el("p", `max ${max.toLocaleString()} requests per step`, "hint");
+  chart.replaceChildren(svg, caption);
+}
+
+async function refresh() {
+  const [windowParam, step] = document.getElementById("window").value.split("/");
+  const [stats, domains, clients, entities, volume, certificates, ca] = await Promise.all([
+    call("GET", "/stats"),
+    call("GET", "/stats/top-domains?limit=10"),
+    call("GET", "/stats/top-clients?limit=10"),
+    call("GET", "/stats/top-entities?limit=10"),
+    call("GET", `/stats/volume?window=${windowParam}&step=${step}`),
+    call("GET", "/certificates"),
+    call("GET", "/ca"),
+  ]);
+
+  renderCounters(stats || {});
+  renderVolume(volume);
+
+  fill("top-domains", (domains || []).map((d) => row(
+    d.key,
+    d.count.toLocaleString(),
+    d.key !== "(other)"
+      ? button("Allow", () => action(() => call("POST", `/domains/${encodeURIComponent(d.key)}/allow`)))
+      : "",
+  )), "No blocked requests yet");
+
+  fill("top-clients", (clients || []).map((c) => row(c.key, c.count.toLocaleString())), "No clients yet");
+
+  fill("top-entities", (entities || []).map((e) => row(e.key, e.count.toLocaleString())), "No known companies yet");
+
+  fill("ca", (ca || []).map((c) => row(
+    c.subject,
+    c.serial_number,
+    expiry(c.not_after),
+    c.active ? "active" : "trusted",
+  )), "Unavailable");
+
+  fill("certificates", (certificates || []).map((c) => row(
+    c.name,
+    c.error ? el("span", c.error, "warn") : c.issuer,
+    c.error ? "" : expiry(c.not_after),
+    button("Delete", () => {
+      if (confirm(`Delete the certificate of ${c.name}? It is issued again on the next handshake.`)) {
+        action(() => call("DELETE", `/certificates/${encodeURIComponent(c.name)}`));
+      }
+    }),
+  )), "No certificates");
+
+  setStatus(`Updated ${new Date().toLocaleTimeString()}`);
+}
+
+function load() {
+  refresh().catch(handle);
+}
+
+document.getElementById("domain-form").addEventListener("submit", (event) => {
+  event.preventDefault();
+  const verb = event.submitter ? event.submitter.value : "block";
+  const input = document.getElementById("domain");
+  const name = input.value.trim();
+  action(async () => {
+    await call("POST", `/domains/${encodeURIComponent(name)}/${verb}`);
+    input.value = "";
+  });
+});
+
+document.getElementById("refresh").addEventListener("click", load);
+document.getElementById("window").addEventListener("change", load);
+
+load();
+setInterval(load, refreshInterval);
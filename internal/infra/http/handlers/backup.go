@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"go.pixelfactory.io/pkg/observability/log"
+	"go.pixelfactory.io/pkg/observability/log/fields"
+)
+
+// BackupFunc writes a database snapshot to w.
+type BackupFunc func(w io.Writer) (int64, error)
+
+type backupHandler struct {
+	logger log.Logger
+	backup BackupFunc
+}
+
+// NewBackupHandler create database backup handler.
+func NewBackupHandler(logger log.Logger, backup BackupFunc) http.Handler {
+	return &backupHandler{logger: logger, backup: backup}
+}
+
+// ServeHTTP respond with a snapshot of the database.
+func (h *backupHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="cache.db"`)
+
+	n, err := h.backup(w)
+	if err != nil {
+		h.logger.Error("Unable to backup database", fields.Error(err))
+		if n == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.logger.Info("Database backup completed", fields.Int("size", int(n)))
+}
@@ -0,0 +1,44 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/infra/http/handlers"
+	"go.pixelfactory.io/pkg/observability/log"
+)
+
+func Test_BackupHandler(t *testing.T) {
+	is := require.New(t)
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/", http.NoBody)
+	is.NoError(err)
+
+	t.Run("Backup", func(_ *testing.T) {
+		rr := httptest.NewRecorder()
+		handler := handlers.NewBackupHandler(log.New(), func(w io.Writer) (int64, error) {
+			n, err := w.Write([]byte("snapshot"))
+			return int64(n), err
+		})
+		handler.ServeHTTP(rr, req)
+
+		is.Equal(http.StatusOK, rr.Code)
+		is.Equal("snapshot", rr.Body.String())
+		is.Equal("application/octet-stream", rr.Header().Get("Content-Type"))
+	})
+
+	t.Run("Backup error", func(_ *testing.T) {
+		rr := httptest.NewRecorder()
+		handler := handlers.NewBackupHandler(log.New(), func(_ io.Writer) (int64, error) {
+			return 0, errors.New("unable to backup")
+		})
+		handler.ServeHTTP(rr, req)
+
+		is.Equal(http.StatusInternalServerError, rr.Code)
+	})
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.pixelfactory.io/needle/internal/infra/health"
+	"go.pixelfactory.io/pkg/observability/log"
+	"go.pixelfactory.io/pkg/observability/log/fields"
+)
+
+type healthHandler struct {
+	logger  log.Logger
+	checker *health.Checker
+}
+
+// NewHealthHandler create health handler, it responds 503 when a check fails.
+func NewHealthHandler(logger log.Logger, checker *health.Checker) http.Handler {
+	return &healthHandler{logger: logger, checker: checker}
+}
+
+// ServeHTTP runs checks and respond with the JSON report.
+func (h *healthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := h.checker.Run(r.Context())
+
+	status := http.StatusOK
+	if !report.OK() {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		h.logger.Error("Unable to write health report", fields.Error(err))
+	}
+}
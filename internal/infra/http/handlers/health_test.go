@@ -0,0 +1,51 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/infra/health"
+	"go.pixelfactory.io/needle/internal/infra/http/handlers"
+	"go.pixelfactory.io/pkg/observability/log"
+)
+
+func Test_HealthHandler(t *testing.T) {
+	is := require.New(t)
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/readyz", http.NoBody)
+	is.NoError(err)
+
+	t.Run("Healthy", func(_ *testing.T) {
+		rr := httptest.NewRecorder()
+		checker := health.New(health.WithCheck("ca", func(_ context.Context) error { return nil }))
+		handlers.NewHealthHandler(log.New(), checker).ServeHTTP(rr, req)
+
+		is.Equal(http.StatusOK, rr.Code)
+		is.Equal("application/json", rr.Header().Get("Content-Type"))
+
+		var report health.Report
+		is.NoError(json.NewDecoder(rr.Body).Decode(&report))
+		is.Equal(health.StatusOK, report.Status)
+		is.Equal("ca", report.Checks[0].Name)
+	})
+
+	t.Run("Unhealthy", func(_ *testing.T) {
+		rr := httptest.NewRecorder()
+		checker := health.New(health.WithCheck("repository", func(_ context.Context) error {
+			return errors.New("database not open")
+		}))
+		handlers.NewHealthHandler(log.New(), checker).ServeHTTP(rr, req)
+
+		is.Equal(http.StatusServiceUnavailable, rr.Code)
+
+		var report health.Report
+		is.NoError(json.NewDecoder(rr.Body).Decode(&report))
+		is.Equal(health.StatusError, report.Status)
+		is.Equal("database not open", report.Checks[0].Error)
+	})
+}
@@ -2,8 +2,11 @@
 package handlers
 
 import (
-	"fmt"
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
 	"net/http"
+	"time"
 )
 
 // Pixel 1x1 transparent pixel.
@@ -12,36 +15,61 @@ var Pixel = []byte{
 	249, 4, 1, 0, 0, 0, 0, 44, 0, 0, 0, 0, 1, 0, 1, 0, 0, 2, 2, 68, 1, 0, 59,
 }
 
-type httpHandler struct{}
+type httpHandler struct {
+	rules map[string]string
+}
 
 type caHandler struct {
-	caFile string
+	roots func() ([]*x509.Certificate, error)
 }
 
-// NewDefaultHandler create default handler.
-func NewDefaultHandler() http.Handler {
-	return &httpHandler{}
+// NewDefaultHandler create default handler, responding with a stub
+// selected by DefaultStubRules and opts.
+func NewDefaultHandler(opts ...StubOption) http.Handler {
+	h := &httpHandler{rules: make(map[string]string, len(DefaultStubRules))}
+	for selector, stub := range DefaultStubRules {
+		h.rules[selector] = stub
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
 }
 
-// ServeHTTP respond with 1x1 transparent gif.
-func (h *httpHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
-	w.Header().Set("Content-Type", "image/gif")
-	w.Header().Set("Content-Length", fmt.Sprint(len(Pixel)))
+// ServeHTTP respond with an empty response of the expected type, a 1x1
+// transparent gif by default.
+func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Accept-Ranges", "bytes")
-	w.WriteHeader(http.StatusOK)
-	_, err := w.Write(Pixel)
-	if err != nil {
+	if err := h.selectStub(r).write(w); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
 
-// NewCAHandler create CA handler.
-func NewCAHandler(ca string) http.Handler {
-	return &caHandler{caFile: ca}
+// NewCAHandler create CA handler, serving the certificates returned by roots
+// on each request so that reloaded CAs are served.
+// During a CA rotation, the active and trusted CAs are served as a single PEM bundle.
+func NewCAHandler(roots func() ([]*x509.Certificate, error)) http.Handler {
+	return &caHandler{roots: roots}
 }
 
 // ServeHTTP respond with public CA certificat.
 func (h *caHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cas, err := h.roots()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var bundle bytes.Buffer
+	for _, ca := range cas {
+		if err := pem.Encode(&bundle, &pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
 	w.Header().Set("Content-type", "application/x-x509-ca-cert")
-	http.ServeFile(w, r, h.caFile)
+	http.ServeContent(w, r, "root-ca.crt", time.Time{}, bytes.NewReader(bundle.Bytes()))
 }
@@ -1,7 +1,11 @@
 package handlers_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -29,6 +33,89 @@ func Test_DefaultHandler(t *testing.T) {
 	is.Equal(rr.Body.Bytes(), handlers.Pixel)
 }
 
+func Test_DefaultHandlerStubs(t *testing.T) {
+	is := require.New(t)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		headers     map[string]string
+		status      int
+		contentType string
+		body        []byte
+	}{
+		{"script destination", http.MethodGet, "/tag", map[string]string{"Sec-Fetch-Dest": "script"}, http.StatusOK, "text/javascript; charset=utf-8", []byte{}},
+		{"stylesheet extension", http.MethodGet, "/ads.CSS", nil, http.StatusOK, "text/css; charset=utf-8", []byte{}},
+		{"json fetch", http.MethodGet, "/config", map[string]string{"Accept": "application/json, text/plain, */*"}, http.StatusOK, "application/json", []byte("{}")},
+		{"iframe", http.MethodGet, "/frame", map[string]string{"Sec-Fetch-Dest": "iframe", "Accept": "text/html"}, http.StatusOK, "text/html; charset=utf-8", nil},
+		{"webp image", http.MethodGet, "/banner", map[string]string{"Sec-Fetch-Dest": "image", "Accept": "image/avif,image/webp,*/*"}, http.StatusOK, "image/webp", handlers.TransparentWebP},
+		{"png extension", http.MethodGet, "/banner.png", nil, http.StatusOK, "image/png", handlers.TransparentPNG},
+		{"vast", http.MethodGet, "/ad.xml", nil, http.StatusOK, "application/xml", nil},
+		{"beacon", http.MethodPost, "/collect", nil, http.StatusNoContent, "", []byte{}},
+		{"hyperlink ping", http.MethodGet, "/ping", map[string]string{"Ping-To": "https://ads.local/"}, http.StatusNoContent, "", []byte{}},
+		{"default", http.MethodGet, "/unknown", nil, http.StatusOK, "image/gif", handlers.Pixel},
+	}
+
+	handler := handlers.NewDefaultHandler()
+	for _, tt := range tests {
+		req, err := http.NewRequestWithContext(context.Background(), tt.method, tt.path, http.NoBody)
+		is.NoError(err)
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		is.Equal(tt.status, rr.Code, tt.name)
+		is.Equal(tt.contentType, rr.Header().Get("Content-Type"), tt.name)
+		if tt.body != nil {
+			is.Equal(string(tt.body), rr.Body.String(), tt.name)
+		}
+	}
+
+	t.Run("Custom rule", func(_ *testing.T) {
+		selector, stub, err := handlers.ParseStubRule("EXT:.JS=empty")
+		is.NoError(err)
+		is.Equal("ext:.js", selector)
+
+		handler := handlers.NewDefaultHandler(handlers.WithStubRule(selector, stub))
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/ads.js", http.NoBody)
+		is.NoError(err)
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		is.Equal(http.StatusNoContent, rr.Code)
+	})
+
+	t.Run("Invalid rules", func(_ *testing.T) {
+		for _, rule := range []string{"ext:.js", "path:/ads=js", "ext:.js=mp4", "dest:=js"} {
+			_, _, err := handlers.ParseStubRule(rule)
+			is.Error(err, rule)
+		}
+	})
+}
+
+// roots returns the certificates of the PEM files at paths.
+func roots(t *testing.T, paths ...string) func() ([]*x509.Certificate, error) {
+	t.Helper()
+
+	var certs []*x509.Certificate
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		block, _ := pem.Decode(data)
+		require.NotNil(t, block)
+		cert, err := x509.ParseCertificate(block.Bytes)
+		require.NoError(t, err)
+		certs = append(certs, cert)
+	}
+	return func() ([]*x509.Certificate, error) {
+		return certs, nil
+	}
+}
+
 func Test_CAHandler(t *testing.T) {
 	is := require.New(t)
 
@@ -37,7 +124,7 @@ func Test_CAHandler(t *testing.T) {
 		t.Fatal(err)
 	}
 	rr := httptest.NewRecorder()
-	handler := handlers.NewCAHandler(testdata.Dir() + "/certs/root-ca.crt")
+	handler := handlers.NewCAHandler(roots(t, testdata.Dir()+"/certs/root-ca.crt"))
 	handler.ServeHTTP(rr, req)
 
 	ca, err := os.ReadFile(testdata.Dir() + "/certs/root-ca.crt")
@@ -46,5 +133,38 @@ func Test_CAHandler(t *testing.T) {
 	}
 
 	is.Equal(rr.Code, http.StatusOK)
-	is.Equal(rr.Body.Bytes(), ca)
+	is.Equal(string(bytes.TrimSpace(ca)), string(bytes.TrimSpace(rr.Body.Bytes())))
+}
+
+func Test_CAHandlerBundle(t *testing.T) {
+	is := require.New(t)
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/", http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler := handlers.NewCAHandler(roots(t,
+		testdata.Dir()+"/certs/root-ca.crt",
+		testdata.Dir()+"/certs/test.needle.local.crt",
+	))
+	handler.ServeHTTP(rr, req)
+
+	is.Equal(rr.Code, http.StatusOK)
+	is.Equal("application/x-x509-ca-cert", rr.Header().Get("Content-Type"))
+	is.Equal(2, bytes.Count(rr.Body.Bytes(), []byte("-----BEGIN CERTIFICATE-----")))
+}
+
+func Test_CAHandlerError(t *testing.T) {
+	is := require.New(t)
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/", http.NoBody)
+	is.NoError(err)
+	rr := httptest.NewRecorder()
+	handler := handlers.NewCAHandler(func() ([]*x509.Certificate, error) {
+		return nil, errors.New("invalid CA")
+	})
+	handler.ServeHTTP(rr, req)
+
+	is.Equal(http.StatusInternalServerError, rr.Code)
 }
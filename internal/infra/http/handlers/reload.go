@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.pixelfactory.io/pkg/observability/log"
+	"go.pixelfactory.io/pkg/observability/log/fields"
+)
+
+// ReloadFunc reloads configuration and CA material.
+type ReloadFunc func() error
+
+type reloadHandler struct {
+	logger log.Logger
+	reload ReloadFunc
+}
+
+type reloadResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// NewReloadHandler create reload handler.
+func NewReloadHandler(logger log.Logger, reload ReloadFunc) http.Handler {
+	return &reloadHandler{logger: logger, reload: reload}
+}
+
+// ServeHTTP triggers a reload and respond with its outcome.
+func (h *reloadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := http.StatusOK
+	resp := reloadResponse{Status: "ok"}
+	if err := h.reload(); err != nil {
+		status = http.StatusInternalServerError
+		resp = reloadResponse{Status: "error", Error: err.Error()}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.logger.Error("Unable to write reload response", fields.Error(err))
+	}
+}
@@ -0,0 +1,43 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/infra/http/handlers"
+	"go.pixelfactory.io/pkg/observability/log"
+)
+
+func Test_ReloadHandler(t *testing.T) {
+	is := require.New(t)
+
+	serve := func(method string, reload handlers.ReloadFunc) *httptest.ResponseRecorder {
+		req, err := http.NewRequestWithContext(context.Background(), method, "/", http.NoBody)
+		is.NoError(err)
+
+		rr := httptest.NewRecorder()
+		handlers.NewReloadHandler(log.New(), reload).ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Reload", func(_ *testing.T) {
+		rr := serve(http.MethodPost, func() error { return nil })
+		is.Equal(http.StatusOK, rr.Code)
+		is.JSONEq(`{"status":"ok"}`, rr.Body.String())
+	})
+
+	t.Run("Reload error", func(_ *testing.T) {
+		rr := serve(http.MethodPost, func() error { return errors.New("invalid CA") })
+		is.Equal(http.StatusInternalServerError, rr.Code)
+		is.JSONEq(`{"status":"error","error":"invalid CA"}`, rr.Body.String())
+	})
+
+	t.Run("Method not allowed", func(_ *testing.T) {
+		rr := serve(http.MethodGet, func() error { return nil })
+		is.Equal(http.StatusMethodNotAllowed, rr.Code)
+	})
+}
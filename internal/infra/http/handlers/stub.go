@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TransparentPNG 1x1 transparent PNG.
+var TransparentPNG = []byte{
+	137, 80, 78, 71, 13, 10, 26, 10, 0, 0, 0, 13, 73, 72, 68, 82, 0, 0, 0, 1, 0,
+	0, 0, 1, 8, 6, 0, 0, 0, 31, 21, 196, 137, 0, 0, 0, 18, 73, 68, 65, 84, 120,
+	156, 0, 5, 0, 250, 255, 2, 0, 0, 0, 0, 3, 0, 0, 15, 0, 3, 66, 167, 245, 14, 0,
+	0, 0, 0, 73, 69, 78, 68, 174, 66, 96, 130,
+}
+
+// TransparentWebP 1x1 transparent lossless WebP.
+var TransparentWebP = []byte{
+	82, 73, 70, 70, 26, 0, 0, 0, 87, 69, 66, 80, 86, 80, 56, 76, 13, 0, 0, 0, 47,
+	0, 0, 0, 16, 7, 16, 17, 17, 136, 136, 254, 7, 0,
+}
+
+// Stub is a response served for blocked requests.
+type Stub struct {
+	ContentType string
+	Status      int
+	Body        []byte
+}
+
+// Stubs by name.
+var Stubs = map[string]Stub{
+	"gif":   {ContentType: "image/gif", Status: http.StatusOK, Body: Pixel},
+	"png":   {ContentType: "image/png", Status: http.StatusOK, Body: TransparentPNG},
+	"webp":  {ContentType: "image/webp", Status: http.StatusOK, Body: TransparentWebP},
+	"js":    {ContentType: "text/javascript; charset=utf-8", Status: http.StatusOK, Body: []byte{}},
+	"css":   {ContentType: "text/css; charset=utf-8", Status: http.StatusOK, Body: []byte{}},
+	"json":  {ContentType: "application/json", Status: http.StatusOK, Body: []byte("{}")},
+	"html":  {ContentType: "text/html; charset=utf-8", Status: http.StatusOK, Body: []byte("<!doctype html><html></html>")},
+	"vast":  {ContentType: "application/xml", Status: http.StatusOK, Body: []byte(`<?xml version="1.0" encoding="UTF-8"?><VAST version="3.0"></VAST>`)},
+	"empty": {Status: http.StatusNoContent},
+}
+
+// defaultStub is served when no rule matches.
+const defaultStub = "gif"
+
+// DefaultStubRules select the stub of a request by Sec-Fetch-Dest, path
+// extension, Accept media type, then method. Keys are prefixed by dest:,
+// ext:, accept: or method:.
+var DefaultStubRules = map[string]string{
+	"dest:script":   "js",
+	"dest:style":    "css",
+	"dest:document": "html",
+	"dest:iframe":   "html",
+	"dest:frame":    "html",
+	"dest:report":   "empty",
+
+	"ext:.js":   "js",
+	"ext:.mjs":  "js",
+	"ext:.css":  "css",
+	"ext:.json": "json",
+	"ext:.gif":  "gif",
+	"ext:.jpg":  "gif",
+	"ext:.jpeg": "gif",
+	"ext:.ico":  "gif",
+	"ext:.png":  "png",
+	"ext:.webp": "webp",
+	"ext:.html": "html",
+	"ext:.htm":  "html",
+	"ext:.xml":  "vast",
+	"ext:.vast": "vast",
+	"ext:.vmap": "vast",
+
+	"accept:application/json":       "json",
+	"accept:text/html":              "html",
+	"accept:text/css":               "css",
+	"accept:text/javascript":        "js",
+	"accept:application/javascript": "js",
+	"accept:image/webp":             "webp",
+	"accept:image/png":              "png",
+	"accept:image/gif":              "gif",
+	"accept:application/xml":        "vast",
+	"accept:text/xml":               "vast",
+
+	// beacons and hyperlink auditing pings
+	"method:POST": "empty",
+}
+
+var stubSelectors = []string{"dest", "ext", "accept", "method"}
+
+// ParseStubRule parses a selector=stub rule, such as ext:.js=js.
+func ParseStubRule(rule string) (string, string, error) {
+	selector, stub, ok := strings.Cut(rule, "=")
+	kind, value, hasKind := strings.Cut(selector, ":")
+	kind = strings.ToLower(kind)
+	switch {
+	case !ok || !hasKind || value == "":
+		return "", "", errors.Errorf("stub rule %q must be selector=stub", rule)
+	case !slices.Contains(stubSelectors, kind):
+		return "", "", errors.Errorf("stub rule %q selector must start with dest:, ext:, accept: or method:", rule)
+	}
+	if _, ok := Stubs[stub]; !ok {
+		return "", "", errors.Errorf("stub rule %q has unknown stub %q", rule, stub)
+	}
+
+	if kind == "method" {
+		value = strings.ToUpper(value)
+	} else {
+		value = strings.ToLower(value)
+	}
+	return kind + ":" + value, stub, nil
+}
+
+// StubOption type.
+type StubOption func(*httpHandler)
+
+// WithStubRule serves stub for requests matching selector, it overrides
+// the default rule with the same selector.
+func WithStubRule(selector, stub string) StubOption {
+	return func(h *httpHandler) {
+		h.rules[selector] = stub
+	}
+}
+
+// selectStub returns the stub of the first matching rule.
+func (h *httpHandler) selectStub(r *http.Request) Stub {
+	if r.Header.Get("Ping-To") != "" {
+		return Stubs["empty"]
+	}
+
+	keys := []string{"dest:" + strings.ToLower(r.Header.Get("Sec-Fetch-Dest"))}
+	if ext := path.Ext(r.URL.Path); ext != "" {
+		keys = append(keys, "ext:"+strings.ToLower(ext))
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(accept); err == nil {
+			keys = append(keys, "accept:"+mediaType)
+		}
+	}
+	keys = append(keys, "method:"+r.Method)
+
+	for _, key := range keys {
+		if name, ok := h.rules[key]; ok {
+			return Stubs[name]
+		}
+	}
+	return Stubs[defaultStub]
+}
+
+func (s Stub) write(w http.ResponseWriter) error {
+	if s.ContentType != "" {
+		w.Header().Set("Content-Type", s.ContentType)
+	}
+	if s.Status == http.StatusNoContent {
+		w.WriteHeader(s.Status)
+		return nil
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(s.Body)))
+	w.WriteHeader(s.Status)
+	_, err := w.Write(s.Body)
+	return err
+}
@@ -1,45 +1,99 @@
 package handlers
 
 import (
+	"context"
 	"crypto/tls"
+	"net"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 	"go.pixelfactory.io/needle/internal/app/pki"
+	"go.pixelfactory.io/needle/internal/infra/tracing"
 	"go.pixelfactory.io/pkg/observability/log"
 	"go.pixelfactory.io/pkg/observability/log/fields"
 )
 
 type PKIService interface {
-	GetOrCreate(name string) (*pki.InternalCert, error)
+	GetOrCreate(ctx context.Context, name string) (*pki.InternalCert, error)
 }
 
 // CertificateHandlerFunc returns a Certificate based on the given ClientHelloInfo.
 type CertificateHandlerFunc func(*tls.ClientHelloInfo) (*tls.Certificate, error)
 
+// ErrServerNameRefused the server name is not blocked, no certificate is issued for it.
+var ErrServerNameRefused = errors.New("server name is not blocked")
+
+// TLSOption type.
+type TLSOption func(*tlsHandler)
+
+type tlsHandler struct {
+	blocked func(client net.IP, name string) bool
+}
+
+// WithServerNames only issues certificates for server names for which
+// blocked returns true, handshakes without server name are not affected.
+// client is nil when the connection address is unknown.
+func WithServerNames(blocked func(client net.IP, name string) bool) TLSOption {
+	return func(h *tlsHandler) {
+		h.blocked = blocked
+	}
+}
+
 // NewTLSHandler creates tlsHandler.
-func NewTLSHandler(logger log.Logger, pkiSvc PKIService) CertificateHandlerFunc {
-	return func(helloInfo *tls.ClientHelloInfo) (*tls.Certificate, error) {
+func NewTLSHandler(logger log.Logger, pkiSvc PKIService, opts ...TLSOption) CertificateHandlerFunc {
+	h := &tlsHandler{}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return func(helloInfo *tls.ClientHelloInfo) (tlsCert *tls.Certificate, err error) {
+		ctx := helloInfo.Context()
+		if ctx == nil {
+			// ClientHelloInfo was not built by crypto/tls
+			ctx = context.Background()
+		}
+
+		ctx, span := tracing.Start(ctx, "handlers.TLSHandler",
+			attribute.String("tls.server_name", helloInfo.ServerName))
+		defer func() { tracing.End(span, err) }()
+
+		logger := tracing.Logger(ctx, logger)
 		logger.Debug("Getting certificate", fields.String("ServerName", helloInfo.ServerName))
 
 		name := "default-needle-certificate"
 		if helloInfo.ServerName != "" {
 			name = helloInfo.ServerName
+			if h.blocked != nil && !h.blocked(remoteIP(helloInfo), name) {
+				logger.Debug("Refusing certificate", fields.String("ServerName", name))
+				return nil, ErrServerNameRefused
+			}
 		}
 
-		certificate, err := pkiSvc.GetOrCreate(name)
+		certificate, err := pkiSvc.GetOrCreate(ctx, name)
 		if err != nil {
 			err := errors.Wrap(err, "api.CertificateHandler.Get")
 			logger.Error("Unable to find certificate", fields.String("CommonName", name), fields.Error(err))
 			return nil, err
 		}
 
-		tlsCert, err := tls.X509KeyPair(certificate.CertPEM, certificate.KeyPEM)
+		cert, err := tls.X509KeyPair(certificate.CertPEM, certificate.KeyPEM)
 		if err != nil {
 			err := errors.Wrap(err, "api.CertificateHandler.Get")
 			logger.Error("Error creating certificate", fields.String("CommonName", name), fields.Error(err))
 			return nil, err
 		}
 
-		return &tlsCert, nil
+		return &cert, nil
+	}
+}
+
+// remoteIP returns the address of the client sending hello, nil when unknown.
+func remoteIP(hello *tls.ClientHelloInfo) net.IP {
+	if hello.Conn == nil {
+		return nil
+	}
+	if addr, ok := hello.Conn.RemoteAddr().(*net.TCPAddr); ok {
+		return addr.IP
 	}
+	return nil
 }
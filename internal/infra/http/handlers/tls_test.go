@@ -4,8 +4,10 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
+	"net"
 	"testing"
 
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"go.pixelfactory.io/needle/internal/app/pki"
 	"go.pixelfactory.io/needle/internal/infra/http/handlers"
@@ -14,6 +16,16 @@ import (
 	"go.pixelfactory.io/pkg/observability/log"
 )
 
+// conn is a connection from addr.
+type conn struct {
+	net.Conn
+	addr net.Addr
+}
+
+func (c conn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
 func Test_TLSHandler(t *testing.T) {
 	is := require.New(t)
 	logger := log.New()
@@ -33,7 +45,7 @@ func Test_TLSHandler(t *testing.T) {
 	is.IsType(handlers.CertificateHandlerFunc(nil), tlsHandler)
 
 	t.Run("Create certificate", func(_ *testing.T) {
-		svc.On("GetOrCreate", "test.needle.local").Return(testCert, nil).Once()
+		svc.On("GetOrCreate", mock.Anything, "test.needle.local").Return(testCert, nil).Once()
 
 		tlsCert, err := tlsHandler(&tls.ClientHelloInfo{ServerName: "test.needle.local"})
 		is.NoError(err)
@@ -47,15 +59,40 @@ func Test_TLSHandler(t *testing.T) {
 	})
 
 	t.Run("Create certificate error", func(_ *testing.T) {
-		svc.On("GetOrCreate", "test.needle.local").Return(nil, errors.New("unable to create certificate")).Once()
+		svc.On("GetOrCreate", mock.Anything, "test.needle.local").Return(nil, errors.New("unable to create certificate")).Once()
 
 		tlsCert, err := tlsHandler(&tls.ClientHelloInfo{ServerName: "test.needle.local"})
 		is.Error(err)
 		is.Empty(tlsCert)
 	})
 
+	t.Run("Refuse server names which are not blocked", func(_ *testing.T) {
+		kids := net.ParseIP("192.168.1.2")
+		tlsHandler := handlers.NewTLSHandler(logger, svc, handlers.WithServerNames(func(client net.IP, name string) bool {
+			return name == "test.needle.local" || (client.Equal(kids) && name == "social.needle.local")
+		}))
+
+		_, err := tlsHandler(&tls.ClientHelloInfo{ServerName: "bank.needle.local"})
+		is.ErrorIs(err, handlers.ErrServerNameRefused)
+
+		_, err = tlsHandler(&tls.ClientHelloInfo{ServerName: "social.needle.local"})
+		is.ErrorIs(err, handlers.ErrServerNameRefused)
+
+		svc.On("GetOrCreate", mock.Anything, "social.needle.local").Return(testCert, nil).Once()
+		_, err = tlsHandler(&tls.ClientHelloInfo{
+			ServerName: "social.needle.local",
+			Conn:       conn{addr: &net.TCPAddr{IP: kids, Port: 41000}},
+		})
+		is.NoError(err)
+
+		svc.On("GetOrCreate", mock.Anything, "test.needle.local").Return(testCert, nil).Once()
+		tlsCert, err := tlsHandler(&tls.ClientHelloInfo{ServerName: "test.needle.local"})
+		is.NoError(err)
+		is.NotEmpty(tlsCert)
+	})
+
 	t.Run("Create certificate error empty certificate", func(_ *testing.T) {
-		svc.On("GetOrCreate", "test.needle.local").Return(&pki.InternalCert{}, nil).Once()
+		svc.On("GetOrCreate", mock.Anything, "test.needle.local").Return(&pki.InternalCert{}, nil).Once()
 
 		tlsCert, err := tlsHandler(&tls.ClientHelloInfo{ServerName: "test.needle.local"})
 		is.Error(err)
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"time"
 
+	"go.pixelfactory.io/needle/internal/infra/tracing"
 	"go.pixelfactory.io/pkg/observability/log"
 	"go.pixelfactory.io/pkg/observability/log/fields"
 )
@@ -62,7 +63,7 @@ func Logging(logger log.Logger) func(http.Handler) http.Handler {
 				logger.Error("Unable to parse remote port", fields.String("remote_port", portStr), fields.Error(err))
 			}
 
-			logger.Debug(
+			tracing.Logger(r.Context(), logger).Debug(
 				"Request",
 				fields.HTTPRequest(r),
 				fields.Source(ip, port),
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+)
+
+// RequestObserver records served HTTP requests.
+type RequestObserver interface {
+	ObserveRequest(r *http.Request, status int, duration time.Duration)
+}
+
+// Metrics reports every request with its status code and duration to observer.
+func Metrics(observer RequestObserver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			wrapped := wrapResponseWriter(w)
+			next.ServeHTTP(wrapped, r)
+
+			status := wrapped.Status()
+			if status == 0 {
+				// handler wrote the body without calling WriteHeader
+				status = http.StatusOK
+			}
+
+			observer.ObserveRequest(r, status, time.Since(start))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -19,6 +19,7 @@ type Route struct {
 // NewRouter create router, setup routes and middlewares.
 func NewRouter(logger log.Logger, routes ...Route) *mux.Router {
 	router := mux.NewRouter()
+	router.Use(middleware.Tracing())
 	router.Use(middleware.Logging(logger))
 
 	for _, r := range routes {
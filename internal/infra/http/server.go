@@ -0,0 +1,164 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"go.pixelfactory.io/pkg/observability/log"
+	"go.pixelfactory.io/pkg/observability/log/fields"
+)
+
+// Server holds an http server which can be gracefully shut down.
+type Server struct {
+	name      string
+	addr      string
+	timeout   time.Duration
+	handler   http.Handler
+	tlsConfig *tls.Config
+	logger    log.Logger
+	srv       *http.Server
+}
+
+// Option type.
+type Option func(*Server)
+
+// WithName set server name.
+func WithName(n string) Option {
+	return func(s *Server) {
+		s.name = n
+	}
+}
+
+// WithLogger set server logger.
+func WithLogger(l log.Logger) Option {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+// WithRouter set server handler.
+func WithRouter(h http.Handler) Option {
+	return func(s *Server) {
+		s.handler = h
+	}
+}
+
+// WithPort set server port, listening on all interfaces.
+func WithPort(p string) Option {
+	return func(s *Server) {
+		s.addr = net.JoinHostPort("", p)
+	}
+}
+
+// WithAddr set server listen address.
+func WithAddr(a string) Option {
+	return func(s *Server) {
+		s.addr = a
+	}
+}
+
+// WithHTTPServerTimeout set server read and write timeout.
+func WithHTTPServerTimeout(t time.Duration) Option {
+	return func(s *Server) {
+		s.timeout = t
+	}
+}
+
+// WithTLSConfig set server tls.Config, the server serves HTTPS when set.
+func WithTLSConfig(c *tls.Config) Option {
+	return func(s *Server) {
+		s.tlsConfig = c
+	}
+}
+
+// NewServer create new Server with default values.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		name:    "needle",
+		addr:    ":8080",
+		timeout: 60 * time.Second,
+		handler: http.NotFoundHandler(),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	// setup default logger
+	if s.logger == nil {
+		s.logger = log.New()
+		s.logger.Info("Using default logger")
+	}
+
+	s.srv = &http.Server{
+		Addr:              s.addr,
+		Handler:           s.handler,
+		TLSConfig:         s.tlsConfig,
+		ReadHeaderTimeout: s.timeout,
+		ReadTimeout:       s.timeout,
+		WriteTimeout:      s.timeout,
+	}
+
+	return s
+}
+
+// ListenAndServe starts the server, it blocks until the server is shut down.
+func (s *Server) ListenAndServe() error {
+	s.logger.Info("Server listening", fields.String("name", s.name), fields.String("addr", s.addr))
+
+	var err error
+	if s.tlsConfig != nil {
+		// certificates are provided by tlsConfig
+		err = s.srv.ListenAndServeTLS("", "")
+	} else {
+		err = s.srv.ListenAndServe()
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown gracefully shuts down the server.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.logger.Info("Server shutting down", fields.String("name", s.name))
+	return s.srv.Shutdown(ctx)
+}
+
+// Check connects to the server listener, completing a TLS handshake when the
+// server uses TLS. The served certificate is not verified, and no server name
+// is sent so that servers refusing some server names complete the handshake.
+func (s *Server) Check(ctx context.Context) error {
+	host, port, err := net.SplitHostPort(s.addr)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		host = "localhost"
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return err
+	}
+
+	if s.tlsConfig != nil {
+		tlsConn := tls.Client(conn, &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: true, //nolint:gosec // only the listener is checked
+		})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return err
+		}
+		conn = tlsConn
+	}
+
+	return conn.Close()
+}
@@ -0,0 +1,96 @@
+package http_test
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	router "go.pixelfactory.io/needle/internal/infra/http"
+	"go.pixelfactory.io/needle/internal/infra/http/handlers"
+	mocks "go.pixelfactory.io/needle/mocks/handlers"
+	"go.pixelfactory.io/needle/testdata"
+	"go.pixelfactory.io/pkg/observability/log"
+)
+
+func TestServer(t *testing.T) {
+	is := require.New(t)
+
+	srv := router.NewServer(
+		router.WithName("needle-test"),
+		router.WithLogger(log.New()),
+		router.WithAddr("127.0.0.1:18080"),
+		router.WithRouter(handlers.NewDefaultHandler()),
+	)
+	is.NotEmpty(srv)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.ListenAndServe()
+	}()
+
+	var resp *http.Response
+	var err error
+	for i := 0; i < 20; i++ {
+		var req *http.Request
+		req, err = http.NewRequestWithContext(context.Background(), http.MethodGet, "http://127.0.0.1:18080/", http.NoBody)
+		is.NoError(err)
+		resp, err = http.DefaultClient.Do(req)
+		if err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	is.NoError(err)
+	is.NoError(resp.Body.Close())
+	is.Equal(http.StatusOK, resp.StatusCode)
+	is.NoError(srv.Check(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	is.NoError(srv.Shutdown(ctx))
+	is.NoError(<-done)
+	is.Error(srv.Check(context.Background()))
+}
+
+func TestServerCheckBlockedOnly(t *testing.T) {
+	is := require.New(t)
+
+	_, testCert := testdata.Setup(t)
+	svc := mocks.NewPKIService(t)
+	svc.On("GetOrCreate", mock.Anything, "default-needle-certificate").Return(testCert, nil)
+
+	// as with --tls-blocked-only and no blocked name
+	certHandler := handlers.NewTLSHandler(log.New(), svc, handlers.WithServerNames(func(net.IP, string) bool {
+		return false
+	}))
+
+	srv := router.NewServer(
+		router.WithName("needle-test-tls"),
+		router.WithLogger(log.New()),
+		router.WithPort("18444"),
+		router.WithRouter(handlers.NewDefaultHandler()),
+		router.WithTLSConfig(&tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certHandler,
+		}),
+	)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.ListenAndServe()
+	}()
+
+	is.Eventually(func() bool {
+		return srv.Check(context.Background()) == nil
+	}, 5*time.Second, 50*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	is.NoError(srv.Shutdown(ctx))
+	is.NoError(<-done)
+}
@@ -0,0 +1,64 @@
+// Needle surrogate for google-analytics.com/analytics.js: a ga() command
+// queue which records nothing but runs hit callbacks.
+(function () {
+  "use strict";
+  var noop = function () {};
+  var name = window.GoogleAnalyticsObject || "ga";
+  var queue = (window[name] && window[name].q) || [];
+
+  function callback(args) {
+    for (var i = args.length - 1; i >= 0; i--) {
+      var arg = args[i];
+      if (arg && typeof arg === "object" && typeof arg.hitCallback === "function") {
+        try {
+          arg.hitCallback();
+        } catch (e) {}
+        return;
+      }
+    }
+  }
+
+  function Tracker() {}
+  Tracker.prototype.get = noop;
+  Tracker.prototype.set = noop;
+  Tracker.prototype.send = function () {
+    callback(arguments);
+  };
+
+  var tracker = new Tracker();
+
+  function ga() {
+    var args = Array.prototype.slice.call(arguments);
+    if (typeof args[0] === "function") {
+      try {
+        args[0](tracker);
+      } catch (e) {}
+      return;
+    }
+    callback(args);
+  }
+  ga.create = function () {
+    return tracker;
+  };
+  ga.getByName = function () {
+    return tracker;
+  };
+  ga.getAll = function () {
+    return [tracker];
+  };
+  ga.remove = noop;
+  ga.loaded = true;
+  ga.l = Date.now();
+  window[name] = ga;
+
+  for (var i = 0; i < queue.length; i++) {
+    ga.apply(window, queue[i]);
+  }
+
+  // anti-flicker snippet
+  var dataLayer = window.dataLayer;
+  if (dataLayer && dataLayer.hide && typeof dataLayer.hide.end === "function") {
+    dataLayer.hide.end();
+    dataLayer.hide.end = noop;
+  }
+})();
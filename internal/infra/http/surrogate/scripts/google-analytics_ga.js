@@ -0,0 +1,58 @@
+// Needle surrogate for google-analytics.com/ga.js: the legacy _gaq queue
+// and _gat trackers, recording nothing.
+(function () {
+  "use strict";
+  var noop = function () {};
+  var tracker = new Proxy({}, {
+    get: function (target, prop) {
+      if (prop === "_getName") {
+        return function () {
+          return "";
+        };
+      }
+      return noop;
+    },
+  });
+
+  var gaq = {
+    push: function () {
+      for (var i = 0; i < arguments.length; i++) {
+        var cmd = arguments[i];
+        if (typeof cmd === "function") {
+          try {
+            cmd();
+          } catch (e) {}
+          continue;
+        }
+        if (Array.isArray(cmd) && cmd[0] === "_set" && cmd[1] === "hitCallback" && typeof cmd[2] === "function") {
+          try {
+            cmd[2]();
+          } catch (e) {}
+        }
+      }
+      return 0;
+    },
+  };
+
+  var queue = Array.isArray(window._gaq) ? window._gaq : [];
+  window._gaq = gaq;
+  gaq.push.apply(gaq, queue);
+
+  window._gat = {
+    _anonymizeIp: noop,
+    _createTracker: function () {
+      return tracker;
+    },
+    _forceSSL: noop,
+    _getPlugin: noop,
+    _getTracker: function () {
+      return tracker;
+    },
+    _getTrackerByName: function () {
+      return tracker;
+    },
+    _getTrackers: function () {
+      return [tracker];
+    },
+  };
+})();
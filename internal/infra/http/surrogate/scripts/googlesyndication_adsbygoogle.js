@@ -0,0 +1,20 @@
+// Needle surrogate for pagead2.googlesyndication.com adsbygoogle.js: ad
+// slots are accepted and left empty.
+(function () {
+  "use strict";
+  var queue = Array.isArray(window.adsbygoogle) ? window.adsbygoogle : [];
+  window.adsbygoogle = {
+    loaded: true,
+    push: function () {
+      for (var i = 0; i < arguments.length; i++) {
+        var params = arguments[i];
+        if (params && typeof params.callback === "function") {
+          try {
+            params.callback();
+          } catch (e) {}
+        }
+      }
+    },
+  };
+  window.adsbygoogle.push.apply(window.adsbygoogle, queue);
+})();
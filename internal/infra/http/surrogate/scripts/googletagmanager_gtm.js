@@ -0,0 +1,46 @@
+// Needle surrogate for googletagmanager.com/gtm.js and gtag/js: dataLayer
+// entries are accepted and their event callbacks run, nothing is recorded.
+(function () {
+  "use strict";
+  var dataLayer = (window.dataLayer = window.dataLayer || []);
+
+  function run(entry) {
+    if (!entry || typeof entry !== "object") {
+      return;
+    }
+    // gtag() pushes its arguments object
+    var params = entry.length === undefined ? entry : entry[2];
+    var callback = params && (params.eventCallback || params.event_callback);
+    if (typeof callback === "function") {
+      setTimeout(function () {
+        try {
+          callback();
+        } catch (e) {}
+      }, 0);
+    }
+  }
+
+  for (var i = 0; i < dataLayer.length; i++) {
+    run(dataLayer[i]);
+  }
+
+  var push = dataLayer.push;
+  dataLayer.push = function () {
+    for (var i = 0; i < arguments.length; i++) {
+      run(arguments[i]);
+    }
+    return push.apply(dataLayer, arguments);
+  };
+
+  if (dataLayer.hide && typeof dataLayer.hide.end === "function") {
+    dataLayer.hide.end();
+    dataLayer.hide.end = function () {};
+  }
+
+  window.google_tag_manager = window.google_tag_manager || {};
+  if (typeof window.gtag !== "function") {
+    window.gtag = function () {
+      dataLayer.push(arguments);
+    };
+  }
+})();
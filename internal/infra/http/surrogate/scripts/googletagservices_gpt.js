@@ -0,0 +1,115 @@
+// Needle surrogate for Google Publisher Tag gpt.js: slots and services
+// accept every call and never display an ad.
+(function () {
+  "use strict";
+  var noop = function () {};
+
+  function chainable(methods, values) {
+    var obj = {};
+    methods.forEach(function (m) {
+      obj[m] = function () {
+        return obj;
+      };
+    });
+    Object.keys(values || {}).forEach(function (m) {
+      obj[m] = values[m];
+    });
+    return obj;
+  }
+
+  function slot(path, id) {
+    return chainable(
+      ["addService", "clearCategoryExclusions", "clearTargeting", "defineSizeMapping", "set", "setCategoryExclusion",
+        "setClickUrl", "setCollapseEmptyDiv", "setConfig", "setForceSafeFrame", "setSafeFrameConfig", "setTargeting",
+        "updateTargetingFromMap"],
+      {
+        getAdUnitPath: function () {
+          return path || "";
+        },
+        getSlotElementId: function () {
+          return id || "";
+        },
+        getTargeting: function () {
+          return [];
+        },
+        getTargetingKeys: function () {
+          return [];
+        },
+        getResponseInformation: function () {
+          return null;
+        },
+      },
+    );
+  }
+
+  var pubads = chainable(
+    ["addEventListener", "clearCategoryExclusions", "clearTargeting", "collapseEmptyDivs", "disableInitialLoad",
+      "enableAsyncRendering", "enableLazyLoad", "enableSingleRequest", "enableVideoAds", "removeEventListener",
+      "set", "setCategoryExclusion", "setCentering", "setCookieOptions", "setForceSafeFrame", "setLocation",
+      "setPrivacySettings", "setPublisherProvidedId", "setRequestNonPersonalizedAds", "setSafeFrameConfig",
+      "setTagForChildDirectedTreatment", "setTargeting", "setVideoContent", "updateCorrelator"],
+    {
+      clear: noop,
+      display: noop,
+      refresh: noop,
+      getSlots: function () {
+        return [];
+      },
+      getTargeting: function () {
+        return [];
+      },
+      getTargetingKeys: function () {
+        return [];
+      },
+      isInitialLoadDisabled: function () {
+        return false;
+      },
+    },
+  );
+
+  var cmd = (window.googletag && window.googletag.cmd) || [];
+  var googletag = (window.googletag = {
+    apiReady: true,
+    pubadsReady: true,
+    cmd: {
+      push: function () {
+        for (var i = 0; i < arguments.length; i++) {
+          try {
+            arguments[i]();
+          } catch (e) {}
+        }
+        return 1;
+      },
+    },
+    companionAds: function () {
+      return chainable(["addEventListener", "setRefreshUnfilledSlots"]);
+    },
+    content: function () {
+      return chainable(["addEventListener", "setContent"]);
+    },
+    defineOutOfPageSlot: slot,
+    defineSlot: slot,
+    destroySlots: noop,
+    disablePublisherConsole: noop,
+    display: noop,
+    enableServices: noop,
+    getVersion: function () {
+      return "";
+    },
+    openConsole: noop,
+    pubads: function () {
+      return pubads;
+    },
+    setAdIframeTitle: noop,
+    setConfig: noop,
+    sizeMapping: function () {
+      return chainable(["addSize"], {
+        build: function () {
+          return [];
+        },
+      });
+    },
+  });
+
+  googletag.cmd.push.apply(googletag.cmd, cmd);
+})();
@@ -0,0 +1,16 @@
+// Needle surrogate for scorecardresearch.com beacon.js: comScore calls
+// are accepted and record nothing.
+(function () {
+  "use strict";
+  var noop = function () {};
+  window.COMSCORE = {
+    beacon: noop,
+    purge: function () {
+      window._comscore = [];
+    },
+  };
+  window._comscore = [];
+  window._comscore.push = function () {
+    return 0;
+  };
+})();
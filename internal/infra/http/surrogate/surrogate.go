@@ -0,0 +1,184 @@
+// Package surrogate serves no-op replacements of commonly blocked tracker
+// scripts, so that pages calling them keep working.
+package surrogate
+
+import (
+	"embed"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.pixelfactory.io/pkg/observability/log"
+	"go.pixelfactory.io/pkg/observability/log/fields"
+)
+
+//go:embed scripts/*.js
+var scripts embed.FS
+
+// Rule serves Script for requests to Host, or one of its subdomains, whose
+// path matches the Path pattern.
+type Rule struct {
+	Host   string
+	Path   string
+	Script string
+}
+
+// DefaultRules are the built-in surrogates.
+var DefaultRules = []Rule{
+	{Host: "google-analytics.com", Path: "/analytics.js", Script: "google-analytics_analytics.js"},
+	{Host: "google-analytics.com", Path: "/ga.js", Script: "google-analytics_ga.js"},
+	{Host: "googletagmanager.com", Path: "/gtm.js", Script: "googletagmanager_gtm.js"},
+	{Host: "googletagmanager.com", Path: "/gtag/js", Script: "googletagmanager_gtm.js"},
+	{Host: "googletagservices.com", Path: "/tag/js/gpt.js", Script: "googletagservices_gpt.js"},
+	{Host: "securepubads.g.doubleclick.net", Path: "/tag/js/gpt.js", Script: "googletagservices_gpt.js"},
+	{Host: "pagead2.googlesyndication.com", Path: "/pagead/js/adsbygoogle.js", Script: "googlesyndication_adsbygoogle.js"},
+	{Host: "scorecardresearch.com", Path: "/beacon.js", Script: "scorecardresearch_beacon.js"},
+}
+
+// Surrogates holds rules and scripts.
+type Surrogates struct {
+	logger  log.Logger
+	dir     string
+	rules   []Rule
+	scripts map[string][]byte
+}
+
+// Option type.
+type Option func(*Surrogates)
+
+// WithLogger set logger.
+func WithLogger(l log.Logger) Option {
+	return func(s *Surrogates) {
+		s.logger = l
+	}
+}
+
+// WithDir set a directory whose scripts replace built-in scripts of the same name.
+func WithDir(dir string) Option {
+	return func(s *Surrogates) {
+		s.dir = dir
+	}
+}
+
+// WithRules set the rules, DefaultRules by default.
+func WithRules(rules ...Rule) Option {
+	return func(s *Surrogates) {
+		s.rules = rules
+	}
+}
+
+// New loads built-in scripts, then scripts of the override directory.
+func New(opts ...Option) (*Surrogates, error) {
+	s := &Surrogates{
+		rules:   DefaultRules,
+		scripts: map[string][]byte{},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	// setup default logger
+	if s.logger == nil {
+		s.logger = log.New()
+		s.logger.Info("Using default logger")
+	}
+
+	entries, err := scripts.ReadDir("scripts")
+	if err != nil {
+		return nil, errors.Wrap(err, "surrogate.New")
+	}
+	for _, entry := range entries {
+		script, err := scripts.ReadFile(path.Join("scripts", entry.Name()))
+		if err != nil {
+			return nil, errors.Wrap(err, "surrogate.New")
+		}
+		s.scripts[entry.Name()] = script
+	}
+
+	if s.dir != "" {
+		if err := s.loadDir(); err != nil {
+			return nil, errors.Wrap(err, "surrogate.New")
+		}
+	}
+
+	for _, rule := range s.rules {
+		if _, ok := s.scripts[rule.Script]; !ok {
+			return nil, errors.Errorf("surrogate.New: unknown script %q for %s%s", rule.Script, rule.Host, rule.Path)
+		}
+		if _, err := path.Match(rule.Path, "/"); err != nil {
+			return nil, errors.Wrapf(err, "surrogate.New: path pattern %q", rule.Path)
+		}
+	}
+
+	return s, nil
+}
+
+// loadDir reads the .js files of the override directory.
+func (s *Surrogates) loadDir() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".js" {
+			continue
+		}
+
+		script, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+
+		if _, ok := s.scripts[entry.Name()]; ok {
+			s.logger.Debug("Overriding surrogate script", fields.String("script", entry.Name()))
+		}
+		s.scripts[entry.Name()] = script
+	}
+
+	return nil
+}
+
+// Match returns the script of the first rule matching host and urlPath.
+func (s *Surrogates) Match(host, urlPath string) (string, bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	for _, rule := range s.rules {
+		if host != rule.Host && !strings.HasSuffix(host, "."+rule.Host) {
+			continue
+		}
+		if ok, _ := path.Match(rule.Path, urlPath); ok {
+			return rule.Script, true
+		}
+	}
+	return "", false
+}
+
+// Handler serves surrogate scripts, other requests are passed to next.
+func (s *Surrogates) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := s.Match(r.Host, r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		script := s.scripts[name]
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		w.Header().Set("Content-Length", strconv.Itoa(len(script)))
+		w.Header().Set("Cache-Control", "private, max-age=3600")
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(script); err != nil {
+			s.logger.Error("Unable to write surrogate script", fields.String("script", name), fields.Error(err))
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package surrogate_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/infra/http/surrogate"
+	"go.pixelfactory.io/pkg/observability/log"
+)
+
+func get(t *testing.T, h http.Handler, url string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, http.NoBody)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func Test_Surrogates(t *testing.T) {
+	is := require.New(t)
+
+	dir := t.TempDir()
+	is.NoError(os.WriteFile(filepath.Join(dir, "google-analytics_ga.js"), []byte("/* custom */"), 0o600))
+
+	s, err := surrogate.New(surrogate.WithLogger(log.New()), surrogate.WithDir(dir))
+	is.NoError(err)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	h := s.Handler(next)
+
+	rr := get(t, h, "https://www.google-analytics.com/analytics.js")
+	is.Equal(http.StatusOK, rr.Code)
+	is.Equal("text/javascript; charset=utf-8", rr.Header().Get("Content-Type"))
+	is.Contains(rr.Body.String(), "hitCallback")
+
+	rr = get(t, h, "https://ssl.google-analytics.com:443/ga.js")
+	is.Equal("/* custom */", rr.Body.String())
+
+	rr = get(t, h, "https://www.googletagmanager.com/gtag/js?id=G-XXXX")
+	is.Contains(rr.Body.String(), "dataLayer")
+
+	rr = get(t, h, "https://google-analytics.com/collect")
+	is.Equal(http.StatusTeapot, rr.Code)
+
+	rr = get(t, h, "https://notgoogle-analytics.com/analytics.js")
+	is.Equal(http.StatusTeapot, rr.Code)
+
+	t.Run("Rules", func(_ *testing.T) {
+		_, err := surrogate.New(
+			surrogate.WithLogger(log.New()),
+			surrogate.WithRules(surrogate.Rule{Host: "ads.local", Path: "/*.js", Script: "missing.js"}),
+		)
+		is.Error(err)
+
+		s, err := surrogate.New(
+			surrogate.WithLogger(log.New()),
+			surrogate.WithRules(surrogate.Rule{Host: "ads.local", Path: "/js/*.js", Script: "googletagmanager_gtm.js"}),
+		)
+		is.NoError(err)
+
+		script, ok := s.Match("cdn.ads.local", "/js/tag.js")
+		is.True(ok)
+		is.Equal("googletagmanager_gtm.js", script)
+	})
+
+	t.Run("Missing directory", func(_ *testing.T) {
+		_, err := surrogate.New(surrogate.WithLogger(log.New()), surrogate.WithDir(filepath.Join(dir, "missing")))
+		is.Error(err)
+	})
+}
@@ -0,0 +1,240 @@
+// Package lanaddr discovers the addresses needle is reachable at on the LAN.
+package lanaddr
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.pixelfactory.io/pkg/observability/log"
+	"go.pixelfactory.io/pkg/observability/log/fields"
+)
+
+// Interface is a network interface and its addresses.
+type Interface struct {
+	Name  string
+	Flags net.Flags
+	Addrs []net.Addr
+}
+
+// InterfacesFunc lists the network interfaces.
+type InterfacesFunc func() ([]Interface, error)
+
+// Watcher keeps the addresses of the network interfaces up to date, or
+// fixed addresses when they are set.
+type Watcher struct {
+	logger     log.Logger
+	fixed      []net.IP
+	names      []string
+	interval   time.Duration
+	interfaces InterfacesFunc
+	onChange   func(addresses []net.IP)
+
+	mu        sync.RWMutex
+	addresses []net.IP
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Option type.
+type Option func(*Watcher)
+
+// WithLogger set watcher logger.
+func WithLogger(l log.Logger) Option {
+	return func(w *Watcher) {
+		w.logger = l
+	}
+}
+
+// WithAddresses set fixed addresses, interfaces are then not detected.
+func WithAddresses(addresses ...net.IP) Option {
+	return func(w *Watcher) {
+		w.fixed = addresses
+	}
+}
+
+// WithInterfaceNames only detects the addresses of the named interfaces.
+func WithInterfaceNames(names ...string) Option {
+	return func(w *Watcher) {
+		w.names = names
+	}
+}
+
+// WithInterval set how often addresses are detected again.
+func WithInterval(d time.Duration) Option {
+	return func(w *Watcher) {
+		w.interval = d
+	}
+}
+
+// WithInterfaces set how network interfaces are listed.
+func WithInterfaces(fn InterfacesFunc) Option {
+	return func(w *Watcher) {
+		w.interfaces = fn
+	}
+}
+
+// WithOnChange set the function called with the new addresses once they changed.
+func WithOnChange(fn func(addresses []net.IP)) Option {
+	return func(w *Watcher) {
+		w.onChange = fn
+	}
+}
+
+// New returns a Watcher holding the addresses detected now.
+func New(opts ...Option) (*Watcher, error) {
+	w := &Watcher{
+		interval:   time.Minute,
+		interfaces: systemInterfaces,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	if w.logger == nil {
+		w.logger = log.New()
+		w.logger.Info("Using default logger")
+	}
+
+	addresses, err := w.detect()
+	if err != nil {
+		return nil, err
+	}
+	w.addresses = addresses
+	w.logger.Info("Needle addresses", fields.Strings("addresses", format(addresses)))
+
+	return w, nil
+}
+
+// Addresses returns needle addresses, IPv4 first.
+func (w *Watcher) Addresses() []net.IP {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.addresses
+}
+
+// Serve detects addresses every interval until Shutdown.
+func (w *Watcher) Serve() error {
+	defer close(w.done)
+
+	if w.fixed != nil {
+		<-w.stop
+		return nil
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return nil
+		case <-ticker.C:
+			w.refresh()
+		}
+	}
+}
+
+// Shutdown stops Serve.
+func (w *Watcher) Shutdown(ctx context.Context) error {
+	close(w.stop)
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// refresh detects addresses and reports a change.
+func (w *Watcher) refresh() {
+	addresses, err := w.detect()
+	if err != nil {
+		w.logger.Error("Unable to detect addresses", fields.Error(err))
+		return
+	}
+
+	w.mu.Lock()
+	changed := !slices.EqualFunc(addresses, w.addresses, net.IP.Equal)
+	w.addresses = addresses
+	w.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	w.logger.Info("Needle addresses changed", fields.Strings("addresses", format(addresses)))
+	if w.onChange != nil {
+		w.onChange(addresses)
+	}
+}
+
+// detect returns the fixed addresses, or the unicast addresses of the
+// interfaces which are up, without loopback and link-local addresses.
+func (w *Watcher) detect() ([]net.IP, error) {
+	if w.fixed != nil {
+		return w.fixed, nil
+	}
+
+	interfaces, err := w.interfaces()
+	if err != nil {
+		return nil, errors.Wrap(err, "lanaddr.detect")
+	}
+
+	var v4, v6 []net.IP
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		if len(w.names) > 0 && !slices.Contains(w.names, iface.Name) {
+			continue
+		}
+
+		for _, addr := range iface.Addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || !ipNet.IP.IsGlobalUnicast() {
+				continue
+			}
+			if ip := ipNet.IP.To4(); ip != nil {
+				v4 = append(v4, ip)
+			} else {
+				v6 = append(v6, ipNet.IP)
+			}
+		}
+	}
+
+	return append(v4, v6...), nil
+}
+
+func systemInterfaces() ([]Interface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	interfaces := make([]Interface, 0, len(ifaces))
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		interfaces = append(interfaces, Interface{Name: iface.Name, Flags: iface.Flags, Addrs: addrs})
+	}
+	return interfaces, nil
+}
+
+func format(addresses []net.IP) []string {
+	s := make([]string, len(addresses))
+	for i, ip := range addresses {
+		s[i] = ip.String()
+	}
+	return s
+}
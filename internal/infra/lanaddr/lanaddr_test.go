@@ -0,0 +1,77 @@
+package lanaddr_test
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/infra/lanaddr"
+	"go.pixelfactory.io/pkg/observability/log"
+)
+
+func ipNet(s string) *net.IPNet {
+	ip, n, _ := net.ParseCIDR(s)
+	n.IP = ip
+	return n
+}
+
+func Test_Watcher(t *testing.T) {
+	is := require.New(t)
+
+	var dhcp atomic.Value
+	dhcp.Store("192.168.1.2/24")
+	interfaces := func() ([]lanaddr.Interface, error) {
+		return []lanaddr.Interface{
+			{Name: "lo", Flags: net.FlagUp | net.FlagLoopback, Addrs: []net.Addr{ipNet("127.0.0.1/8")}},
+			{Name: "eth0", Flags: net.FlagUp, Addrs: []net.Addr{
+				ipNet("fe80::1/64"),
+				ipNet("fd00::2/64"),
+				ipNet(dhcp.Load().(string)),
+			}},
+			{Name: "wlan0", Flags: net.FlagUp, Addrs: []net.Addr{ipNet("10.0.0.2/24")}},
+			{Name: "eth1", Addrs: []net.Addr{ipNet("172.16.0.2/24")}},
+		}, nil
+	}
+
+	changes := make(chan []net.IP, 1)
+	w, err := lanaddr.New(
+		lanaddr.WithLogger(log.New()),
+		lanaddr.WithInterfaces(interfaces),
+		lanaddr.WithInterfaceNames("eth0", "eth1"),
+		lanaddr.WithInterval(10*time.Millisecond),
+		lanaddr.WithOnChange(func(addresses []net.IP) { changes <- addresses }),
+	)
+	is.NoError(err)
+	is.Equal([]net.IP{net.ParseIP("192.168.1.2").To4(), net.ParseIP("fd00::2")}, w.Addresses())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Serve()
+	}()
+
+	// a new DHCP lease is picked up
+	dhcp.Store("192.168.1.3/24")
+	select {
+	case addresses := <-changes:
+		is.Equal("192.168.1.3", addresses[0].String())
+	case <-time.After(3 * time.Second):
+		is.Fail("addresses did not change")
+	}
+	is.Equal("192.168.1.3", w.Addresses()[0].String())
+
+	is.NoError(w.Shutdown(context.Background()))
+	is.NoError(<-done)
+
+	t.Run("Fixed addresses", func(_ *testing.T) {
+		w, err := lanaddr.New(
+			lanaddr.WithLogger(log.New()),
+			lanaddr.WithInterfaces(interfaces),
+			lanaddr.WithAddresses(net.ParseIP("10.0.0.53")),
+		)
+		is.NoError(err)
+		is.Equal([]net.IP{net.ParseIP("10.0.0.53")}, w.Addresses())
+	})
+}
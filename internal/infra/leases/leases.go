@@ -0,0 +1,203 @@
+// Package leases reads the MAC addresses of clients from a DHCP lease file.
+package leases
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.pixelfactory.io/pkg/observability/log"
+	"go.pixelfactory.io/pkg/observability/log/fields"
+)
+
+// Watcher keeps the leases of a lease file, read again once it changed.
+type Watcher struct {
+	logger   log.Logger
+	path     string
+	interval time.Duration
+
+	mu      sync.RWMutex
+	macs    Map
+	modTime time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Option type.
+type Option func(*Watcher)
+
+// WithLogger set watcher logger.
+func WithLogger(l log.Logger) Option {
+	return func(w *Watcher) {
+		w.logger = l
+	}
+}
+
+// WithInterval set how often the lease file is checked for changes.
+func WithInterval(d time.Duration) Option {
+	return func(w *Watcher) {
+		w.interval = d
+	}
+}
+
+// New returns a Watcher holding the leases of the dnsmasq or ISC dhcpd
+// lease file at path.
+func New(path string, opts ...Option) (*Watcher, error) {
+	w := &Watcher{
+		path:     path,
+		interval: 10 * time.Second,
+		macs:     Map{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	if w.logger == nil {
+		w.logger = log.New()
+		w.logger.Info("Using default logger")
+	}
+
+	if _, err := w.refresh(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// MAC returns the MAC address ip is leased to.
+func (w *Watcher) MAC(ip net.IP) (net.HardwareAddr, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.macs.MAC(ip)
+}
+
+// Serve reads the lease file again every interval once it changed, until
+// Shutdown.
+func (w *Watcher) Serve() error {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return nil
+		case <-ticker.C:
+			changed, err := w.refresh()
+			if err != nil {
+				w.logger.Error("Unable to read leases, previous leases are kept", fields.Error(err))
+				continue
+			}
+			if changed {
+				w.logger.Debug("Leases changed", fields.String("file", w.path))
+			}
+		}
+	}
+}
+
+// Shutdown stops Serve.
+func (w *Watcher) Shutdown(ctx context.Context) error {
+	close(w.stop)
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// refresh reads the lease file when its modification time changed.
+func (w *Watcher) refresh() (bool, error) {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false, errors.Wrap(err, "leases.Watcher")
+	}
+
+	w.mu.RLock()
+	unchanged := info.ModTime().Equal(w.modTime)
+	w.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	f, err := os.Open(w.path)
+	if err != nil {
+		return false, errors.Wrap(err, "leases.Watcher")
+	}
+	defer f.Close()
+
+	macs, err := Parse(f)
+	if err != nil {
+		return false, errors.Wrap(err, "leases.Watcher")
+	}
+
+	w.mu.Lock()
+	w.macs, w.modTime = macs, info.ModTime()
+	w.mu.Unlock()
+	return true, nil
+}
+
+// Map holds the MAC address of each leased address.
+type Map map[netip.Addr]net.HardwareAddr
+
+// MAC returns the MAC address ip is leased to.
+func (m Map) MAC(ip net.IP) (net.HardwareAddr, bool) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return nil, false
+	}
+	mac, ok := m[addr.Unmap()]
+	return mac, ok
+}
+
+// Parse reads the MAC address of each leased address from a dnsmasq lease
+// file, one "expiry mac ip hostname client-id" lease per line, or from an
+// ISC dhcpd lease file of "lease ip { hardware ethernet mac; }" blocks.
+// Later leases of an address replace earlier ones.
+func Parse(r io.Reader) (Map, error) {
+	macs := Map{}
+
+	// lease is the address of the current ISC dhcpd block
+	var lease netip.Addr
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		f := strings.Fields(strings.TrimSuffix(strings.TrimSpace(scanner.Text()), ";"))
+		switch {
+		case len(f) == 0 || strings.HasPrefix(f[0], "#"):
+		case f[0] == "lease" && len(f) >= 2:
+			lease, _ = netip.ParseAddr(f[1])
+		case f[0] == "}":
+			lease = netip.Addr{}
+		case f[0] == "hardware" && len(f) == 3 && lease.IsValid():
+			if mac, err := net.ParseMAC(f[2]); err == nil {
+				macs[lease.Unmap()] = mac
+			}
+		case len(f) >= 3 && !lease.IsValid():
+			mac, err := net.ParseMAC(f[1])
+			if err != nil {
+				continue
+			}
+			if addr, err := netip.ParseAddr(f[2]); err == nil {
+				macs[addr.Unmap()] = mac
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "leases.Parse")
+	}
+
+	return macs, nil
+}
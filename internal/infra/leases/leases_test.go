@@ -0,0 +1,77 @@
+package leases_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/infra/leases"
+	"go.pixelfactory.io/pkg/observability/log"
+)
+
+func Test_Parse(t *testing.T) {
+	is := require.New(t)
+
+	macs, err := leases.Parse(strings.NewReader(`
+1760000000 aa:bb:cc:dd:ee:01 192.168.1.10 tablet 01:aa:bb:cc:dd:ee:01
+1760000000 1234 fd00::10 laptop 00:01:00:01
+duid 00:01:00:01:2c:aa:bb:cc
+
+# ISC dhcpd
+lease 192.168.1.20 {
+  starts 4 2026/10/15 10:00:00;
+  hardware ethernet aa:bb:cc:dd:ee:02;
+  client-hostname "tv";
+}
+`))
+	is.NoError(err)
+	is.Len(macs, 2)
+
+	for ip, mac := range macs {
+		switch ip.String() {
+		case "192.168.1.10":
+			is.Equal("aa:bb:cc:dd:ee:01", mac.String())
+		case "192.168.1.20":
+			is.Equal("aa:bb:cc:dd:ee:02", mac.String())
+		default:
+			is.Fail("unexpected lease", ip.String())
+		}
+	}
+}
+
+func Test_Watcher(t *testing.T) {
+	is := require.New(t)
+
+	path := filepath.Join(t.TempDir(), "dnsmasq.leases")
+	is.NoError(os.WriteFile(path, []byte("0 aa:bb:cc:dd:ee:01 192.168.1.10 tablet *\n"), 0o600))
+
+	w, err := leases.New(path, leases.WithLogger(log.New()), leases.WithInterval(10*time.Millisecond))
+	is.NoError(err)
+
+	mac, ok := w.MAC(net.ParseIP("192.168.1.10"))
+	is.True(ok)
+	is.Equal("aa:bb:cc:dd:ee:01", mac.String())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Serve()
+	}()
+
+	is.NoError(os.WriteFile(path, []byte("0 aa:bb:cc:dd:ee:03 192.168.1.10 tablet *\n"), 0o600))
+	is.NoError(os.Chtimes(path, time.Now(), time.Now().Add(time.Second)))
+	is.Eventually(func() bool {
+		mac, ok := w.MAC(net.ParseIP("::ffff:192.168.1.10"))
+		return ok && mac.String() == "aa:bb:cc:dd:ee:03"
+	}, 3*time.Second, 10*time.Millisecond)
+
+	is.NoError(w.Shutdown(context.Background()))
+	is.NoError(<-done)
+
+	_, err = leases.New(filepath.Join(t.TempDir(), "missing"), leases.WithLogger(log.New()))
+	is.Error(err)
+}
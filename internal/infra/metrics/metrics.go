@@ -0,0 +1,240 @@
+// Package metrics provides Prometheus metrics for needle.
+package metrics
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "needle"
+
+// Handshake outcomes.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Repository lookup results.
+const (
+	LookupHit   = "hit"
+	LookupMiss  = "miss"
+	LookupError = "error"
+)
+
+// CountFunc returns the number of stored certificates.
+type CountFunc func() (int, error)
+
+// Metrics holds needle collectors and their registry.
+type Metrics struct {
+	registry *prometheus.Registry
+	gatherer prometheus.Gatherer
+
+	handshakes       *prometheus.CounterVec
+	issuanceDuration prometheus.Histogram
+	issuanceFailures prometheus.Counter
+	getOrCreate      prometheus.Histogram
+	getOrCreateFails prometheus.Counter
+	lookups          *prometheus.CounterVec
+	httpRequests     *prometheus.CounterVec
+	httpDuration     *prometheus.HistogramVec
+}
+
+// Option type.
+type Option func(*Metrics)
+
+// WithStoredCount export the number of stored certificates returned by count.
+func WithStoredCount(count CountFunc) Option {
+	return func(m *Metrics) {
+		m.registry.MustRegister(&storedCollector{
+			desc: prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, "", "certificates_stored"),
+				"Number of certificates stored in the cache DB.",
+				nil, nil,
+			),
+			count: count,
+		})
+	}
+}
+
+// WithGatherer merge metrics of g, such as CoreDNS metrics registered with
+// prometheus.DefaultGatherer, into the exported metrics.
+func WithGatherer(g prometheus.Gatherer) Option {
+	return func(m *Metrics) {
+		m.gatherer = prometheus.Gatherers{m.registry, g}
+	}
+}
+
+// dnsStats are the CoreDNS metrics reported by Stats.
+var dnsStats = []string{"coredns_dns_requests_total", "coredns_dns_responses_total"}
+
+// New create Metrics with a dedicated registry.
+func New(opts ...Option) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		handshakes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tls_handshakes_total",
+			Help:      "TLS handshakes by certificate selection outcome.",
+		}, []string{"outcome"}),
+		issuanceDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "certificate_issuance_duration_seconds",
+			Help:      "Time spent issuing a certificate.",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+		}),
+		issuanceFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "certificate_issuance_failures_total",
+			Help:      "Certificates which could not be issued.",
+		}),
+		getOrCreate: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "certificate_get_or_create_duration_seconds",
+			Help:      "Time spent retrieving or issuing the certificate of a handshake.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}),
+		getOrCreateFails: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "certificate_get_or_create_failures_total",
+			Help:      "Certificates which could not be retrieved or issued.",
+		}),
+		lookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "repository_lookups_total",
+			Help:      "Certificate lookups in the cache DB by result.",
+		}, []string{"result"}),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method and status code.",
+		}, []string{"method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request duration by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+	}
+	m.gatherer = m.registry
+
+	m.registry.MustRegister(
+		m.handshakes,
+		m.issuanceDuration,
+		m.issuanceFailures,
+		m.getOrCreate,
+		m.getOrCreateFails,
+		m.lookups,
+		m.httpRequests,
+		m.httpDuration,
+	)
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Registry returns the registry of needle collectors.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler returns the /metrics handler.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.gatherer, promhttp.HandlerOpts{
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
+
+// Stats returns needle metrics summed by name, labelled series are also
+// reported by series, such as needle_tls_handshakes_total{outcome="success"}.
+// Histograms report their observation count. CoreDNS query counters of
+// merged gatherers are included, other merged metrics are not.
+func (m *Metrics) Stats() (map[string]float64, error) {
+	families, err := m.gatherer.Gather()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := map[string]float64{}
+	for _, family := range families {
+		if !strings.HasPrefix(family.GetName(), namespace+"_") && !slices.Contains(dnsStats, family.GetName()) {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			var value float64
+			switch {
+			case metric.GetCounter() != nil:
+				value = metric.GetCounter().GetValue()
+			case metric.GetGauge() != nil:
+				value = metric.GetGauge().GetValue()
+			case metric.GetHistogram() != nil:
+				value = float64(metric.GetHistogram().GetSampleCount())
+			}
+
+			stats[family.GetName()] += value
+			if len(metric.GetLabel()) == 0 {
+				continue
+			}
+
+			labels := make([]string, 0, len(metric.GetLabel()))
+			for _, label := range metric.GetLabel() {
+				labels = append(labels, fmt.Sprintf("%s=%q", label.GetName(), label.GetValue()))
+			}
+			stats[family.GetName()+"{"+strings.Join(labels, ",")+"}"] = value
+		}
+	}
+
+	return stats, nil
+}
+
+// ObserveRequest records an HTTP request, it implements middleware.RequestObserver.
+// Hosts are not a label, every blocked domain would add series.
+func (m *Metrics) ObserveRequest(r *http.Request, status int, duration time.Duration) {
+	m.httpRequests.WithLabelValues(r.Method, strconv.Itoa(status)).Inc()
+	m.httpDuration.WithLabelValues(r.Method).Observe(duration.Seconds())
+}
+
+// InstrumentGetCertificate counts handshakes by the outcome of getCertificate.
+func (m *Metrics) InstrumentGetCertificate(
+	getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error),
+) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		cert, err := getCertificate(hello)
+		outcome := OutcomeSuccess
+		if err != nil {
+			outcome = OutcomeFailure
+		}
+		m.handshakes.WithLabelValues(outcome).Inc()
+		return cert, err
+	}
+}
+
+// storedCollector collects the number of stored certificates on scrape.
+type storedCollector struct {
+	desc  *prometheus.Desc
+	count CountFunc
+}
+
+func (c *storedCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *storedCollector) Collect(ch chan<- prometheus.Metric) {
+	n, err := c.count()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n))
+}
@@ -0,0 +1,148 @@
+package metrics_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/app/pki"
+	"go.pixelfactory.io/needle/internal/infra/http/middleware"
+	"go.pixelfactory.io/needle/internal/infra/metrics"
+	mocks "go.pixelfactory.io/needle/mocks/pki"
+)
+
+func Test_Metrics(t *testing.T) {
+	is := require.New(t)
+
+	t.Run("Repository lookups", func(_ *testing.T) {
+		m := metrics.New()
+		repo := mocks.NewRepository(t)
+		repo.On("Get", mock.Anything, "hit.needle.local").Return(&pki.InternalCert{}, nil)
+		repo.On("Get", mock.Anything, "miss.needle.local").Return(nil, pki.ErrCertificateNotFound)
+
+		instrumented := m.NewRepository(repo)
+		_, _ = instrumented.Get(context.Background(), "hit.needle.local")
+		_, _ = instrumented.Get(context.Background(), "miss.needle.local")
+		_, _ = instrumented.Get(context.Background(), "miss.needle.local")
+
+		is.NoError(testutil.GatherAndCompare(m.Registry(), strings.NewReader(`
+# HELP needle_repository_lookups_total Certificate lookups in the cache DB by result.
+# TYPE needle_repository_lookups_total counter
+needle_repository_lookups_total{result="hit"} 1
+needle_repository_lookups_total{result="miss"} 2
+`), "needle_repository_lookups_total"))
+	})
+
+	t.Run("Issuance", func(_ *testing.T) {
+		m := metrics.New()
+		factory := mocks.NewFactory(t)
+		factory.On("Create", mock.Anything, "ok.needle.local").Return(&pki.InternalCert{}, nil)
+		factory.On("Create", mock.Anything, "ko.needle.local").Return(nil, errors.New("unable to sign"))
+
+		instrumented := m.NewFactory(factory)
+		_, err := instrumented.Create(context.Background(), "ok.needle.local")
+		is.NoError(err)
+		_, err = instrumented.Create(context.Background(), "ko.needle.local")
+		is.Error(err)
+
+		is.Equal(1, testutil.CollectAndCount(m.Registry(), "needle_certificate_issuance_duration_seconds"))
+		is.NoError(testutil.GatherAndCompare(m.Registry(), strings.NewReader(`
+# HELP needle_certificate_issuance_failures_total Certificates which could not be issued.
+# TYPE needle_certificate_issuance_failures_total counter
+needle_certificate_issuance_failures_total 1
+`), "needle_certificate_issuance_failures_total"))
+	})
+
+	t.Run("Stats", func(_ *testing.T) {
+		dns := prometheus.NewRegistry()
+		dnsRequests := prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "coredns_dns_requests_total",
+		}, []string{"type"})
+		dnsRequests.WithLabelValues("A").Add(3)
+		dns.MustRegister(dnsRequests, prometheus.NewGoCollector())
+
+		m := metrics.New(
+			metrics.WithStoredCount(func() (int, error) { return 2, nil }),
+			metrics.WithGatherer(dns),
+		)
+		getCertificate := m.InstrumentGetCertificate(func(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return &tls.Certificate{}, nil
+		})
+		_, _ = getCertificate(&tls.ClientHelloInfo{})
+
+		stats, err := m.Stats()
+		is.NoError(err)
+		is.InDelta(2.0, stats["needle_certificates_stored"], 0)
+		is.InDelta(1.0, stats["needle_tls_handshakes_total"], 0)
+		is.InDelta(1.0, stats[`needle_tls_handshakes_total{outcome="success"}`], 0)
+		is.InDelta(3.0, stats[`coredns_dns_requests_total{type="A"}`], 0)
+		is.NotContains(stats, "go_goroutines")
+	})
+
+	t.Run("Handshakes", func(_ *testing.T) {
+		m := metrics.New()
+		getCertificate := m.InstrumentGetCertificate(func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if hello.ServerName == "" {
+				return nil, errors.New("no server name")
+			}
+			return &tls.Certificate{}, nil
+		})
+
+		_, _ = getCertificate(&tls.ClientHelloInfo{ServerName: "test.needle.local"})
+		_, _ = getCertificate(&tls.ClientHelloInfo{})
+
+		is.NoError(testutil.GatherAndCompare(m.Registry(), strings.NewReader(`
+# HELP needle_tls_handshakes_total TLS handshakes by certificate selection outcome.
+# TYPE needle_tls_handshakes_total counter
+needle_tls_handshakes_total{outcome="failure"} 1
+needle_tls_handshakes_total{outcome="success"} 1
+`), "needle_tls_handshakes_total"))
+	})
+
+	t.Run("HTTP requests", func(_ *testing.T) {
+		m := metrics.New()
+		handler := middleware.Metrics(m)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("ok"))
+		}))
+
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://ads.needle.local:8080/", http.NoBody)
+		is.NoError(err)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		is.NoError(testutil.GatherAndCompare(m.Registry(), strings.NewReader(`
+# HELP needle_http_requests_total HTTP requests by method and status code.
+# TYPE needle_http_requests_total counter
+needle_http_requests_total{method="GET",status="200"} 1
+`), "needle_http_requests_total"))
+	})
+
+	t.Run("Handler merges gatherers", func(_ *testing.T) {
+		other := prometheus.NewRegistry()
+		other.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Name: "coredns_test_total", Help: "Test."}))
+
+		m := metrics.New(
+			metrics.WithGatherer(other),
+			metrics.WithStoredCount(func() (int, error) { return 3, nil }),
+		)
+
+		rr := httptest.NewRecorder()
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/metrics", http.NoBody)
+		is.NoError(err)
+		m.Handler().ServeHTTP(rr, req)
+
+		body, err := io.ReadAll(rr.Body)
+		is.NoError(err)
+		is.Equal(http.StatusOK, rr.Code)
+		is.Contains(string(body), "needle_certificates_stored 3")
+		is.Contains(string(body), "coredns_test_total 0")
+	})
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.pixelfactory.io/needle/internal/app/pki"
+)
+
+// Repository wraps a pki.Repository and counts lookup hits and misses.
+type Repository struct {
+	pki.Repository
+	metrics *Metrics
+}
+
+// NewRepository creates an instrumented Repository.
+func (m *Metrics) NewRepository(repo pki.Repository) *Repository {
+	return &Repository{Repository: repo, metrics: m}
+}
+
+// Get retrieves a certificate.
+func (r *Repository) Get(ctx context.Context, name string) (*pki.InternalCert, error) {
+	cert, err := r.Repository.Get(ctx, name)
+	switch {
+	case err == nil:
+		r.metrics.lookups.WithLabelValues(LookupHit).Inc()
+	case errors.Is(err, pki.ErrCertificateNotFound):
+		r.metrics.lookups.WithLabelValues(LookupMiss).Inc()
+	default:
+		r.metrics.lookups.WithLabelValues(LookupError).Inc()
+	}
+	return cert, err
+}
+
+// Factory wraps a pki.Factory and measures certificate issuance.
+type Factory struct {
+	pki.Factory
+	metrics *Metrics
+}
+
+// NewFactory creates an instrumented Factory.
+func (m *Metrics) NewFactory(factory pki.Factory) *Factory {
+	return &Factory{Factory: factory, metrics: m}
+}
+
+// Create creates a certificate.
+func (f *Factory) Create(ctx context.Context, name string) (*pki.InternalCert, error) {
+	start := time.Now()
+	cert, err := f.Factory.Create(ctx, name)
+	if err != nil {
+		f.metrics.issuanceFailures.Inc()
+		return nil, err
+	}
+	f.metrics.issuanceDuration.Observe(time.Since(start).Seconds())
+	return cert, nil
+}
+
+// CertificateService retrieves or creates certificates.
+type CertificateService interface {
+	GetOrCreate(ctx context.Context, name string) (*pki.InternalCert, error)
+}
+
+// Service wraps a CertificateService and measures GetOrCreate.
+type Service struct {
+	CertificateService
+	metrics *Metrics
+}
+
+// NewService creates an instrumented Service.
+func (m *Metrics) NewService(svc CertificateService) *Service {
+	return &Service{CertificateService: svc, metrics: m}
+}
+
+// GetOrCreate retrieves or creates a certificate.
+func (s *Service) GetOrCreate(ctx context.Context, name string) (*pki.InternalCert, error) {
+	start := time.Now()
+	cert, err := s.CertificateService.GetOrCreate(ctx, name)
+	if err != nil {
+		s.metrics.getOrCreateFails.Inc()
+		return nil, err
+	}
+	s.metrics.getOrCreate.Observe(time.Since(start).Seconds())
+	return cert, nil
+}
@@ -0,0 +1,118 @@
+package querylog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Filter selects entries, zero values match every entry.
+type Filter struct {
+	Since  time.Time
+	Until  time.Time
+	Kind   Kind
+	Client string
+	// Domain matches the domain and its subdomains.
+	Domain string
+	// Entity matches the company owning the domain, ignoring case.
+	Entity   string
+	Category string
+	Limit    int
+}
+
+func (f Filter) match(e Entry) bool {
+	switch {
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !e.Time.Before(f.Until):
+		return false
+	case f.Kind != "" && e.Kind != f.Kind:
+		return false
+	case f.Client != "" && e.Client != f.Client:
+		return false
+	case f.Domain != "" && e.Domain != f.Domain && !strings.HasSuffix(e.Domain, "."+f.Domain):
+		return false
+	case f.Entity != "" && !strings.EqualFold(e.Entity, f.Entity):
+		return false
+	case f.Category != "" && !slices.Contains(e.Categories, f.Category):
+		return false
+	}
+	return true
+}
+
+// Query returns entries matching f, newest first.
+func (l *Log) Query(ctx context.Context, f Filter) ([]Entry, error) {
+	if err := l.Flush(); err != nil {
+		return nil, err
+	}
+
+	segments, err := l.segments()
+	if err != nil {
+		return nil, errors.Wrap(err, "querylog.Query")
+	}
+
+	f.Domain = strings.TrimSuffix(strings.ToLower(f.Domain), ".")
+
+	result := []Entry{}
+	for i := len(segments) - 1; i >= 0; i-- {
+		s := segments[i]
+		if !f.Until.IsZero() && !s.start.Before(f.Until) {
+			continue
+		}
+		if !f.Since.IsZero() && s.start.Add(time.Hour).Before(f.Since) {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		entries, err := readSegment(filepath.Join(l.dir, s.name), f)
+		if err != nil {
+			return nil, errors.Wrap(err, "querylog.Query")
+		}
+
+		slices.Reverse(entries)
+		result = append(result, entries...)
+		if f.Limit > 0 && len(result) >= f.Limit {
+			return result[:f.Limit], nil
+		}
+	}
+
+	return result, nil
+}
+
+// readSegment returns entries of a segment matching f, lines which do not
+// decode, such as a line being written, are skipped.
+func readSegment(path string, f Filter) ([]Entry, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// deleted by prune
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		if f.match(e) {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, scanner.Err()
+}
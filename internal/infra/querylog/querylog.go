@@ -0,0 +1,381 @@
+// Package querylog provides an append-only log of blocked HTTP requests and DNS queries.
+//
+// Entries are appended as JSON lines to hourly segment files, segments are
+// deleted once older than the retention or when the log exceeds its size cap.
+package querylog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.pixelfactory.io/pkg/observability/log"
+	"go.pixelfactory.io/pkg/observability/log/fields"
+)
+
+// Kind of a logged entry.
+type Kind string
+
+const (
+	// KindHTTP is a blocked HTTP or HTTPS request.
+	KindHTTP Kind = "http"
+	// KindDNS is a DNS query answered by the embedded CoreDNS.
+	KindDNS Kind = "dns"
+)
+
+const (
+	segmentExt    = ".jsonl"
+	segmentLayout = "2006-01-02T15"
+)
+
+// Entry is a logged request or query.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Kind      Kind      `json:"kind"`
+	Client    string    `json:"client"`
+	Domain    string    `json:"domain"`
+	Method    string    `json:"method,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Referer   string    `json:"referer,omitempty"`
+	Status    int       `json:"status,omitempty"`
+	QueryType string    `json:"query_type,omitempty"`
+	RCode     string    `json:"rcode,omitempty"`
+	// CNAMEs is the CNAME chain of a query blocked because it aliases a
+	// blocked name, which is the last one.
+	CNAMEs []string `json:"cnames,omitempty"`
+	// Entity is the company owning Domain and Categories its categories.
+	Entity     string   `json:"entity,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+}
+
+// ClassifyFunc returns the company owning domain and its categories.
+type ClassifyFunc func(domain string) (entity string, categories []string)
+
+// Log appends entries to segment files in a directory.
+type Log struct {
+	dir           string
+	retention     time.Duration
+	maxSize       int64
+	flushInterval time.Duration
+	logger        log.Logger
+	classify      ClassifyFunc
+	now           func() time.Time
+
+	mu      sync.Mutex
+	file    *os.File
+	buf     *bufio.Writer
+	segment string
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Option type.
+type Option func(*Log)
+
+// WithDir set the directory holding segment files.
+func WithDir(dir string) Option {
+	return func(l *Log) {
+		l.dir = dir
+	}
+}
+
+// WithRetention set how long entries are kept, with an hour granularity.
+func WithRetention(d time.Duration) Option {
+	return func(l *Log) {
+		l.retention = d
+	}
+}
+
+// WithMaxSize set the size in bytes above which the oldest segments are deleted.
+func WithMaxSize(size int64) Option {
+	return func(l *Log) {
+		l.maxSize = size
+	}
+}
+
+// WithFlushInterval set how often buffered entries are written to disk.
+func WithFlushInterval(d time.Duration) Option {
+	return func(l *Log) {
+		l.flushInterval = d
+	}
+}
+
+// WithLogger set logger.
+func WithLogger(logger log.Logger) Option {
+	return func(l *Log) {
+		l.logger = logger
+	}
+}
+
+// WithClassify set how entries are tagged with the company owning their
+// domain and its categories.
+func WithClassify(fn ClassifyFunc) Option {
+	return func(l *Log) {
+		l.classify = fn
+	}
+}
+
+// WithClock set the time source.
+func WithClock(now func() time.Time) Option {
+	return func(l *Log) {
+		l.now = now
+	}
+}
+
+// Open creates the log directory and returns the Log.
+func Open(opts ...Option) (*Log, error) {
+	l := &Log{
+		dir:           "data/querylog",
+		retention:     7 * 24 * time.Hour,
+		maxSize:       100 << 20,
+		flushInterval: time.Second,
+		now:           time.Now,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	// setup default logger
+	if l.logger == nil {
+		l.logger = log.New()
+		l.logger.Info("Using default logger")
+	}
+
+	if err := os.MkdirAll(l.dir, 0o750); err != nil {
+		return nil, errors.Wrap(err, "querylog.Open")
+	}
+
+	return l, nil
+}
+
+// Record appends e to the log, errors are logged.
+func (l *Log) Record(e Entry) {
+	if e.Time.IsZero() {
+		e.Time = l.now()
+	}
+	if l.classify != nil && e.Entity == "" && len(e.Categories) == 0 {
+		e.Entity, e.Categories = l.classify(e.Domain)
+		// cloaked names are classified after the blocked name they alias
+		if e.Entity == "" && len(e.Categories) == 0 && len(e.CNAMEs) > 0 {
+			e.Entity, e.Categories = l.classify(e.CNAMEs[len(e.CNAMEs)-1])
+		}
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		l.logger.Error("Unable to encode query log entry", fields.Error(err))
+		return
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.rotate(e.Time); err != nil {
+		l.logger.Error("Unable to open query log segment", fields.Error(err))
+		return
+	}
+
+	if _, err := l.buf.Write(line); err != nil {
+		l.logger.Error("Unable to write query log entry", fields.Error(err))
+	}
+}
+
+// ObserveRequest records a blocked HTTP request, it implements middleware.RequestObserver.
+func (l *Log) ObserveRequest(r *http.Request, status int, _ time.Duration) {
+	domain, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		domain = r.Host
+	}
+
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+
+	l.Record(Entry{
+		Kind:      KindHTTP,
+		Client:    client,
+		Domain:    strings.ToLower(domain),
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		UserAgent: r.UserAgent(),
+		Referer:   r.Referer(),
+		Status:    status,
+	})
+}
+
+// rotate opens the segment of t when it is not the current one.
+func (l *Log) rotate(t time.Time) error {
+	segment := t.UTC().Format(segmentLayout)
+	if l.file != nil && segment <= l.segment {
+		return nil
+	}
+
+	if err := l.closeSegment(); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(l.dir, segment+segmentExt), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+
+	l.file = f
+	l.buf = bufio.NewWriter(f)
+	l.segment = segment
+	return nil
+}
+
+func (l *Log) closeSegment() error {
+	if l.file == nil {
+		return nil
+	}
+
+	err := l.buf.Flush()
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+	l.buf = nil
+	return err
+}
+
+// Flush writes buffered entries to disk.
+func (l *Log) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.buf == nil {
+		return nil
+	}
+	return errors.Wrap(l.buf.Flush(), "querylog.Flush")
+}
+
+// Serve flushes buffered entries and enforces retention until Shutdown.
+func (l *Log) Serve() error {
+	defer close(l.done)
+
+	flush := time.NewTicker(l.flushInterval)
+	defer flush.Stop()
+	prune := time.NewTicker(time.Minute)
+	defer prune.Stop()
+
+	l.prune()
+	for {
+		select {
+		case <-l.stop:
+			return nil
+		case <-flush.C:
+			if err := l.Flush(); err != nil {
+				l.logger.Error("Unable to flush query log", fields.Error(err))
+			}
+		case <-prune.C:
+			l.prune()
+		}
+	}
+}
+
+// Shutdown stops Serve, then flushes and closes the current segment.
+func (l *Log) Shutdown(ctx context.Context) error {
+	close(l.stop)
+
+	select {
+	case <-l.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return errors.Wrap(l.closeSegment(), "querylog.Shutdown")
+}
+
+type segmentFile struct {
+	name  string
+	start time.Time
+	size  int64
+}
+
+// segments returns segment files, oldest first.
+func (l *Log) segments() ([]segmentFile, error) {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []segmentFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+
+		start, err := time.Parse(segmentLayout, strings.TrimSuffix(name, segmentExt))
+		if err != nil {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, segmentFile{name: name, start: start, size: info.Size()})
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].start.Before(segments[j].start)
+	})
+	return segments, nil
+}
+
+// prune deletes segments older than the retention, then the oldest ones
+// while the log exceeds its size cap. The current segment is kept.
+func (l *Log) prune() {
+	segments, err := l.segments()
+	if err != nil {
+		l.logger.Error("Unable to list query log segments", fields.Error(err))
+		return
+	}
+
+	l.mu.Lock()
+	current := l.segment + segmentExt
+	l.mu.Unlock()
+
+	var size int64
+	for _, s := range segments {
+		size += s.size
+	}
+
+	expired := l.now().Add(-l.retention)
+	for _, s := range segments {
+		if s.name == current {
+			continue
+		}
+		if !s.start.Add(time.Hour).Before(expired) && size <= l.maxSize {
+			break
+		}
+
+		if err := os.Remove(filepath.Join(l.dir, s.name)); err != nil {
+			l.logger.Error("Unable to delete query log segment", fields.String("segment", s.name), fields.Error(err))
+			continue
+		}
+		size -= s.size
+		l.logger.Debug("Deleted query log segment", fields.String("segment", s.name))
+	}
+}
@@ -0,0 +1,140 @@
+package querylog_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/infra/querylog"
+	"go.pixelfactory.io/pkg/observability/log"
+)
+
+func Test_Log(t *testing.T) {
+	is := require.New(t)
+
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC)
+	// Serve reads the clock while the test moves it
+	var clock atomic.Int64
+	clock.Store(now.UnixNano())
+	l, err := querylog.Open(
+		querylog.WithDir(dir),
+		querylog.WithRetention(2*time.Hour),
+		querylog.WithMaxSize(1<<20),
+		querylog.WithFlushInterval(10*time.Millisecond),
+		querylog.WithLogger(log.New()),
+		querylog.WithClock(func() time.Time { return time.Unix(0, clock.Load()).UTC() }),
+		querylog.WithClassify(func(domain string) (string, []string) {
+			if domain == "tracker.needle.local" {
+				return "Tracker Inc", []string{"analytics"}
+			}
+			return "", nil
+		}),
+	)
+	is.NoError(err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- l.Serve()
+	}()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://Ads.Needle.local/pixel.gif", http.NoBody)
+	is.NoError(err)
+	req.RemoteAddr = "192.168.1.10:51000"
+	req.Header.Set("User-Agent", "test")
+	req.Header.Set("Referer", "https://news.local/")
+	l.ObserveRequest(req, http.StatusOK, time.Millisecond)
+
+	now = now.Add(time.Hour)
+	clock.Store(now.UnixNano())
+	l.Record(querylog.Entry{
+		Kind: querylog.KindDNS, Client: "192.168.1.11", Domain: "metrics.shop.local", QueryType: "A", RCode: "NOERROR",
+		CNAMEs: []string{"cdn.shop.local", "tracker.needle.local"},
+	})
+	l.Record(querylog.Entry{Kind: querylog.KindDNS, Client: "192.168.1.10", Domain: "needle.local", QueryType: "AAAA", RCode: "NOERROR"})
+
+	t.Run("Query", func(_ *testing.T) {
+		entries, err := l.Query(context.Background(), querylog.Filter{})
+		is.NoError(err)
+		is.Len(entries, 3)
+		is.Equal("needle.local", entries[0].Domain)
+		is.Equal(querylog.Entry{
+			Time:      time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC),
+			Kind:      querylog.KindHTTP,
+			Client:    "192.168.1.10",
+			Domain:    "ads.needle.local",
+			Method:    http.MethodGet,
+			Path:      "/pixel.gif",
+			UserAgent: "test",
+			Referer:   "https://news.local/",
+			Status:    http.StatusOK,
+		}, entries[2])
+
+		entries, err = l.Query(context.Background(), querylog.Filter{Client: "192.168.1.10", Domain: "needle.local."})
+		is.NoError(err)
+		is.Len(entries, 2)
+
+		entries, err = l.Query(context.Background(), querylog.Filter{Domain: "ads.needle.local", Kind: querylog.KindHTTP})
+		is.NoError(err)
+		is.Len(entries, 1)
+
+		entries, err = l.Query(context.Background(), querylog.Filter{Entity: "tracker inc", Category: "analytics"})
+		is.NoError(err)
+		is.Len(entries, 1)
+		is.Equal("Tracker Inc", entries[0].Entity)
+		is.Equal([]string{"analytics"}, entries[0].Categories)
+		is.Equal([]string{"cdn.shop.local", "tracker.needle.local"}, entries[0].CNAMEs)
+
+		entries, err = l.Query(context.Background(), querylog.Filter{Since: now.Add(-time.Minute), Limit: 1})
+		is.NoError(err)
+		is.Len(entries, 1)
+		is.Equal("needle.local", entries[0].Domain)
+
+		entries, err = l.Query(context.Background(), querylog.Filter{Until: now.Add(-time.Minute)})
+		is.NoError(err)
+		is.Len(entries, 1)
+		is.Equal(querylog.KindHTTP, entries[0].Kind)
+	})
+
+	t.Run("Retention", func(_ *testing.T) {
+		segments, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+		is.NoError(err)
+		is.Len(segments, 2)
+
+		// the 10h segment expires once the 13h segment is written
+		now = now.Add(2 * time.Hour)
+		clock.Store(now.UnixNano())
+		l.Record(querylog.Entry{Kind: querylog.KindDNS, Client: "192.168.1.10", Domain: "needle.local"})
+
+		is.NoError(l.Shutdown(context.Background()))
+		is.NoError(<-done)
+
+		l, err := querylog.Open(
+			querylog.WithDir(dir),
+			querylog.WithRetention(2*time.Hour),
+			querylog.WithLogger(log.New()),
+			querylog.WithClock(func() time.Time { return now }),
+		)
+		is.NoError(err)
+
+		go func() {
+			done <- l.Serve()
+		}()
+		is.Eventually(func() bool {
+			_, err := os.Stat(filepath.Join(dir, "2024-01-01T10.jsonl"))
+			return os.IsNotExist(err)
+		}, time.Second, 10*time.Millisecond)
+
+		entries, err := l.Query(context.Background(), querylog.Filter{})
+		is.NoError(err)
+		is.Len(entries, 3)
+
+		is.NoError(l.Shutdown(context.Background()))
+		is.NoError(<-done)
+	})
+}
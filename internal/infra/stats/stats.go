@@ -0,0 +1,221 @@
+// Package stats keeps in-memory statistics of blocked traffic for the dashboard.
+package stats
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Other is the key counting requests once maxKeys distinct keys are tracked.
+const Other = "(other)"
+
+// Count holds the number of requests of a domain, client or entity.
+type Count struct {
+	Key   string `json:"key"`
+	Count uint64 `json:"count"`
+}
+
+// Point holds the number of requests of a time step.
+type Point struct {
+	Time  time.Time `json:"time"`
+	Count uint64    `json:"count"`
+}
+
+type bucket struct {
+	minute int64
+	count  uint64
+}
+
+// Recorder counts requests by domain, client, entity and minute.
+type Recorder struct {
+	mu       sync.Mutex
+	domains  map[string]uint64
+	clients  map[string]uint64
+	entities map[string]uint64
+	minutes  []bucket
+	maxKeys  int
+	entity   func(domain string) string
+	now      func() time.Time
+}
+
+// Option type.
+type Option func(*Recorder)
+
+// WithRetention set how long per minute request counts are kept.
+func WithRetention(d time.Duration) Option {
+	return func(r *Recorder) {
+		r.minutes = make([]bucket, int(d/time.Minute))
+	}
+}
+
+// WithEntities set how the company owning a domain is found, requests to
+// domains of no company are not counted by entity.
+func WithEntities(entity func(domain string) string) Option {
+	return func(r *Recorder) {
+		r.entity = entity
+	}
+}
+
+// WithMaxKeys set how many distinct domains, clients and entities are
+// tracked.
+func WithMaxKeys(n int) Option {
+	return func(r *Recorder) {
+		r.maxKeys = n
+	}
+}
+
+// WithClock set the time source.
+func WithClock(now func() time.Time) Option {
+	return func(r *Recorder) {
+		r.now = now
+	}
+}
+
+// New create Recorder with default values.
+func New(opts ...Option) *Recorder {
+	r := &Recorder{
+		domains:  map[string]uint64{},
+		clients:  map[string]uint64{},
+		entities: map[string]uint64{},
+		minutes:  make([]bucket, 24*60),
+		maxKeys:  10000,
+		now:      time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// ObserveRequest records a request, it implements middleware.RequestObserver.
+func (r *Recorder) ObserveRequest(req *http.Request, _ int, _ time.Duration) {
+	domain, _, err := net.SplitHostPort(req.Host)
+	if err != nil {
+		domain = req.Host
+	}
+
+	client, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		client = req.RemoteAddr
+	}
+
+	r.Record(domain, client)
+}
+
+// Record counts a request of client to domain.
+func (r *Recorder) Record(domain, client string) {
+	minute := r.now().Unix() / 60
+
+	var entity string
+	if r.entity != nil {
+		entity = r.entity(domain)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.inc(r.domains, domain)
+	r.inc(r.clients, client)
+	if entity != "" {
+		r.inc(r.entities, entity)
+	}
+
+	b := &r.minutes[minute%int64(len(r.minutes))]
+	if b.minute != minute {
+		b.minute = minute
+		b.count = 0
+	}
+	b.count++
+}
+
+func (r *Recorder) inc(counts map[string]uint64, key string) {
+	if _, ok := counts[key]; !ok && len(counts) >= r.maxKeys {
+		key = Other
+	}
+	counts[key]++
+}
+
+// TopDomains returns the n most requested domains.
+func (r *Recorder) TopDomains(n int) []Count {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return top(r.domains, n)
+}
+
+// TopClients returns the n clients sending the most requests.
+func (r *Recorder) TopClients(n int) []Count {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return top(r.clients, n)
+}
+
+// TopEntities returns the n companies owning the most requested domains.
+func (r *Recorder) TopEntities(n int) []Count {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return top(r.entities, n)
+}
+
+func top(counts map[string]uint64, n int) []Count {
+	result := make([]Count, 0, len(counts))
+	for key, count := range counts {
+		result = append(result, Count{Key: key, Count: count})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Key < result[j].Key
+	})
+
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+// Volume returns request counts over the last window, grouped by step.
+// window is capped to the retention and step is rounded to whole minutes.
+func (r *Recorder) Volume(window, step time.Duration) []Point {
+	steps := int64(step / time.Minute)
+	if steps < 1 {
+		steps = 1
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	minutes := int64(window / time.Minute)
+	if minutes > int64(len(r.minutes)) {
+		minutes = int64(len(r.minutes))
+	}
+
+	now := r.now().Unix() / 60
+	first := now - minutes + 1
+	first -= first % steps
+
+	points := make([]Point, 0, minutes/steps+1)
+	for start := first; start <= now; start += steps {
+		var count uint64
+		for minute := start; minute < start+steps && minute <= now; minute++ {
+			if minute < 0 || now-minute >= int64(len(r.minutes)) {
+				continue
+			}
+			if b := r.minutes[minute%int64(len(r.minutes))]; b.minute == minute {
+				count += b.count
+			}
+		}
+		points = append(points, Point{Time: time.Unix(start*60, 0).UTC(), Count: count})
+	}
+
+	return points
+}
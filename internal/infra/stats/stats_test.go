@@ -0,0 +1,72 @@
+package stats_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/infra/stats"
+)
+
+func Test_Recorder(t *testing.T) {
+	is := require.New(t)
+
+	now := time.Date(2024, 1, 1, 12, 0, 30, 0, time.UTC)
+	r := stats.New(
+		stats.WithRetention(time.Hour),
+		stats.WithMaxKeys(3),
+		stats.WithClock(func() time.Time { return now }),
+		stats.WithEntities(func(domain string) string {
+			return map[string]string{
+				"ads.needle.local":     "Ads Co",
+				"tracker.needle.local": "Tracker Inc",
+				"pixel.needle.local":   "Tracker Inc",
+			}[domain]
+		}),
+	)
+
+	t.Run("Top", func(_ *testing.T) {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://ads.needle.local:8080/", http.NoBody)
+		is.NoError(err)
+		req.RemoteAddr = "192.168.1.10:51000"
+		r.ObserveRequest(req, http.StatusOK, time.Millisecond)
+
+		r.Record("ads.needle.local", "192.168.1.11")
+		r.Record("tracker.needle.local", "192.168.1.10")
+		r.Record("pixel.needle.local", "192.168.1.10")
+		r.Record("beacon.needle.local", "192.168.1.10")
+
+		is.Equal([]stats.Count{
+			{Key: "ads.needle.local", Count: 2},
+			{Key: "(other)", Count: 1},
+		}, r.TopDomains(2))
+		is.Equal([]stats.Count{
+			{Key: "192.168.1.10", Count: 4},
+			{Key: "192.168.1.11", Count: 1},
+		}, r.TopClients(10))
+		is.Equal([]stats.Count{
+			{Key: "Ads Co", Count: 2},
+			{Key: "Tracker Inc", Count: 2},
+		}, r.TopEntities(10))
+	})
+
+	t.Run("Volume", func(_ *testing.T) {
+		now = now.Add(2 * time.Minute)
+		r.Record("ads.needle.local", "192.168.1.10")
+
+		points := r.Volume(5*time.Minute, time.Minute)
+		is.Len(points, 5)
+		is.Equal(time.Date(2024, 1, 1, 11, 58, 0, 0, time.UTC), points[0].Time)
+		is.Equal(uint64(5), points[2].Count)
+		is.Equal(uint64(0), points[3].Count)
+		is.Equal(uint64(1), points[4].Count)
+
+		// past retention, counts are forgotten
+		now = now.Add(2 * time.Hour)
+		for _, p := range r.Volume(time.Hour, 10*time.Minute) {
+			is.Equal(uint64(0), p.Count)
+		}
+	})
+}
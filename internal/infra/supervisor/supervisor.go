@@ -0,0 +1,217 @@
+// Package supervisor runs needle components and stops them together.
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.pixelfactory.io/pkg/observability/log"
+	"go.pixelfactory.io/pkg/observability/log/fields"
+)
+
+// ErrNotRunning the component is not serving.
+var ErrNotRunning = errors.New("component is not running")
+
+// Component is a long running part of needle, such as a listener.
+type Component struct {
+	Name string
+	// Serve blocks until the component is shut down or fails.
+	Serve func() error
+	// Shutdown gracefully stops the component.
+	Shutdown func(ctx context.Context) error
+	// Restart restarts the component when it fails instead of stopping all components.
+	Restart bool
+}
+
+// Supervisor holds components.
+type Supervisor struct {
+	components      []Component
+	shutdownTimeout time.Duration
+	restartDelay    time.Duration
+	maxRestarts     int
+	logger          log.Logger
+
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+// Option type.
+type Option func(*Supervisor)
+
+// WithLogger set supervisor logger.
+func WithLogger(l log.Logger) Option {
+	return func(s *Supervisor) {
+		s.logger = l
+	}
+}
+
+// WithShutdownTimeout set the time allowed to shut down all components.
+func WithShutdownTimeout(t time.Duration) Option {
+	return func(s *Supervisor) {
+		s.shutdownTimeout = t
+	}
+}
+
+// WithRestartDelay set the delay before restarting a failed component, doubled on each restart.
+func WithRestartDelay(d time.Duration) Option {
+	return func(s *Supervisor) {
+		s.restartDelay = d
+	}
+}
+
+// WithMaxRestarts set how many times a component is restarted before giving up.
+func WithMaxRestarts(n int) Option {
+	return func(s *Supervisor) {
+		s.maxRestarts = n
+	}
+}
+
+// WithComponents add components, they are shut down in the order they are added.
+func WithComponents(c ...Component) Option {
+	return func(s *Supervisor) {
+		s.components = append(s.components, c...)
+	}
+}
+
+// New create new Supervisor with default values.
+func New(opts ...Option) *Supervisor {
+	s := &Supervisor{
+		shutdownTimeout: 5 * time.Second,
+		restartDelay:    time.Second,
+		maxRestarts:     5,
+		running:         map[string]bool{},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	// setup default logger
+	if s.logger == nil {
+		s.logger = log.New()
+		s.logger.Info("Using default logger")
+	}
+
+	return s
+}
+
+type exit struct {
+	name string
+	err  error
+}
+
+// Run starts all components and blocks until ctx is done or a component fails
+// for good, then shuts every component down. It returns the failure, if any,
+// and an error when components still serve after shutdownTimeout.
+func (s *Supervisor) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	exits := make(chan exit, len(s.components))
+	running := map[string]bool{}
+	for _, c := range s.components {
+		running[c.Name] = true
+		go func(c Component) {
+			exits <- exit{name: c.Name, err: s.serve(ctx, c)}
+		}(c)
+	}
+
+	var failure error
+	select {
+	case <-ctx.Done():
+		s.logger.Info("Shutting down")
+	case e := <-exits:
+		delete(running, e.name)
+		failure = fmt.Errorf("%s stopped: %w", e.name, e.err)
+		s.logger.Error("Component failed, shutting down", fields.String("component", e.name), fields.Error(e.err))
+	}
+
+	cancel()
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancelShutdown()
+	s.shutdown(shutdownCtx)
+
+	for len(running) > 0 {
+		select {
+		case e := <-exits:
+			delete(running, e.name)
+		case <-shutdownCtx.Done():
+			names := slices.Sorted(maps.Keys(running))
+			return errors.Join(failure, fmt.Errorf("%s still running after shutdown: %w",
+				strings.Join(names, ", "), shutdownCtx.Err()))
+		}
+	}
+
+	return failure
+}
+
+// Running returns ErrNotRunning unless the named component is serving.
+func (s *Supervisor) Running(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running[name] {
+		return fmt.Errorf("%s: %w", name, ErrNotRunning)
+	}
+	return nil
+}
+
+func (s *Supervisor) setRunning(name string, running bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.running[name] = running
+}
+
+// serve runs c until ctx is done, restarting it when allowed.
+func (s *Supervisor) serve(ctx context.Context, c Component) error {
+	delay := s.restartDelay
+	for restarts := 0; ; restarts++ {
+		// Shutdown may already have run, a component served again would
+		// not be stopped
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		s.setRunning(c.Name, true)
+		err := c.Serve()
+		s.setRunning(c.Name, false)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == nil {
+			err = errors.New("exited unexpectedly")
+		}
+
+		if !c.Restart || restarts >= s.maxRestarts {
+			return err
+		}
+
+		s.logger.Error("Component failed, restarting",
+			fields.String("component", c.Name), fields.Duration("delay", delay), fields.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// shutdown stops components in order, sharing the deadline of ctx.
+func (s *Supervisor) shutdown(ctx context.Context) {
+	for _, c := range s.components {
+		if err := c.Shutdown(ctx); err != nil {
+			s.logger.Error("Component shutdown failed", fields.String("component", c.Name), fields.Error(err))
+			continue
+		}
+		s.logger.Info("Component stopped", fields.String("component", c.Name))
+	}
+}
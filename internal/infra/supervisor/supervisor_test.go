@@ -0,0 +1,162 @@
+package supervisor_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.pixelfactory.io/needle/internal/infra/supervisor"
+	"go.pixelfactory.io/pkg/observability/log"
+)
+
+// fakeComponent serves until shut down, or fails with err when fail is closed.
+type fakeComponent struct {
+	name    string
+	stop    chan struct{}
+	once    sync.Once
+	fail    chan error
+	serves  atomic.Int32
+	stopped *[]string
+	mu      *sync.Mutex
+}
+
+func newFakeComponent(name string, stopped *[]string, mu *sync.Mutex) *fakeComponent {
+	return &fakeComponent{name: name, stop: make(chan struct{}), fail: make(chan error, 10), stopped: stopped, mu: mu}
+}
+
+func (f *fakeComponent) component(restart bool) supervisor.Component {
+	return supervisor.Component{
+		Name: f.name,
+		Serve: func() error {
+			f.serves.Add(1)
+			select {
+			case <-f.stop:
+				return nil
+			case err := <-f.fail:
+				return err
+			}
+		},
+		Shutdown: func(_ context.Context) error {
+			f.once.Do(func() { close(f.stop) })
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			*f.stopped = append(*f.stopped, f.name)
+			return nil
+		},
+		Restart: restart,
+	}
+}
+
+func Test_Supervisor(t *testing.T) {
+	is := require.New(t)
+
+	t.Run("Shutdown in order on cancel", func(_ *testing.T) {
+		var stopped []string
+		var mu sync.Mutex
+		httpC := newFakeComponent("http", &stopped, &mu)
+		dnsC := newFakeComponent("dns", &stopped, &mu)
+
+		sup := supervisor.New(
+			supervisor.WithLogger(log.New()),
+			supervisor.WithComponents(httpC.component(false), dnsC.component(false)),
+		)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			done <- sup.Run(ctx)
+		}()
+
+		is.Eventually(func() bool {
+			return sup.Running("http") == nil && sup.Running("dns") == nil
+		}, time.Second, time.Millisecond)
+		is.ErrorIs(sup.Running("admin"), supervisor.ErrNotRunning)
+
+		cancel()
+		is.NoError(<-done)
+		is.Equal([]string{"http", "dns"}, stopped)
+		is.ErrorIs(sup.Running("dns"), supervisor.ErrNotRunning)
+	})
+
+	t.Run("Failure stops all components", func(_ *testing.T) {
+		var stopped []string
+		var mu sync.Mutex
+		httpC := newFakeComponent("http", &stopped, &mu)
+		dnsC := newFakeComponent("dns", &stopped, &mu)
+
+		sup := supervisor.New(
+			supervisor.WithLogger(log.New()),
+			supervisor.WithComponents(httpC.component(false), dnsC.component(false)),
+		)
+
+		dnsC.fail <- errors.New("address already in use")
+		err := sup.Run(context.Background())
+		is.ErrorContains(err, "dns stopped: address already in use")
+		is.Equal([]string{"http", "dns"}, stopped)
+	})
+
+	t.Run("Restart failed component", func(_ *testing.T) {
+		var stopped []string
+		var mu sync.Mutex
+		dnsC := newFakeComponent("dns", &stopped, &mu)
+
+		sup := supervisor.New(
+			supervisor.WithLogger(log.New()),
+			supervisor.WithRestartDelay(time.Millisecond),
+			supervisor.WithMaxRestarts(2),
+			supervisor.WithComponents(dnsC.component(true)),
+		)
+
+		dnsC.fail <- errors.New("crashed")
+		dnsC.fail <- errors.New("crashed")
+		dnsC.fail <- errors.New("crashed")
+		err := sup.Run(context.Background())
+		is.ErrorContains(err, "dns stopped: crashed")
+		is.Equal(int32(3), dnsC.serves.Load())
+	})
+
+	t.Run("Shutdown timeout", func(_ *testing.T) {
+		sup := supervisor.New(
+			supervisor.WithLogger(log.New()),
+			supervisor.WithShutdownTimeout(50*time.Millisecond),
+			supervisor.WithComponents(supervisor.Component{
+				Name:     "dns",
+				Serve:    func() error { select {} },
+				Shutdown: func(_ context.Context) error { return nil },
+			}),
+		)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			done <- sup.Run(ctx)
+		}()
+		is.Eventually(func() bool { return sup.Running("dns") == nil }, time.Second, time.Millisecond)
+
+		cancel()
+		select {
+		case err := <-done:
+			is.ErrorIs(err, context.DeadlineExceeded)
+			is.ErrorContains(err, "dns still running after shutdown")
+		case <-time.After(time.Second):
+			is.Fail("Run did not return after the shutdown timeout")
+		}
+	})
+
+	t.Run("Unexpected exit", func(_ *testing.T) {
+		sup := supervisor.New(
+			supervisor.WithLogger(log.New()),
+			supervisor.WithComponents(supervisor.Component{
+				Name:     "http",
+				Serve:    func() error { return nil },
+				Shutdown: func(_ context.Context) error { return nil },
+			}),
+		)
+
+		is.ErrorContains(sup.Run(context.Background()), "http stopped: exited unexpectedly")
+	})
+}
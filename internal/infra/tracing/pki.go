@@ -0,0 +1,101 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.pixelfactory.io/needle/internal/app/pki"
+)
+
+// attrName is the certificate name attribute of pki spans.
+const attrName = attribute.Key("needle.certificate.name")
+
+// Repository wraps a pki.Repository with spans.
+type Repository struct {
+	repo pki.Repository
+}
+
+// NewRepository creates a traced Repository.
+func NewRepository(repo pki.Repository) *Repository {
+	return &Repository{repo: repo}
+}
+
+// Delete deletes a certificate.
+func (r *Repository) Delete(ctx context.Context, name string) error {
+	ctx, span := Start(ctx, "pki.Repository.Delete", attrName.String(name))
+	err := r.repo.Delete(ctx, name)
+	End(span, err)
+	return err
+}
+
+// Get retrieves a certificate, a missing certificate is not recorded as an error.
+func (r *Repository) Get(ctx context.Context, name string) (*pki.InternalCert, error) {
+	ctx, span := Start(ctx, "pki.Repository.Get", attrName.String(name))
+	cert, err := r.repo.Get(ctx, name)
+	span.SetAttributes(attribute.Bool("needle.cache.hit", err == nil))
+
+	if errors.Is(err, pki.ErrCertificateNotFound) {
+		End(span, nil)
+	} else {
+		End(span, err)
+	}
+	return cert, err
+}
+
+// List retrieves all certificates.
+func (r *Repository) List(ctx context.Context) ([]*pki.InternalCert, error) {
+	ctx, span := Start(ctx, "pki.Repository.List")
+	certs, err := r.repo.List(ctx)
+	End(span, err)
+	return certs, err
+}
+
+// Store stores a certificate.
+func (r *Repository) Store(ctx context.Context, certificate *pki.InternalCert) error {
+	ctx, span := Start(ctx, "pki.Repository.Store", attrName.String(certificate.Name))
+	err := r.repo.Store(ctx, certificate)
+	End(span, err)
+	return err
+}
+
+// Factory wraps a pki.Factory with spans.
+type Factory struct {
+	pki.Factory
+}
+
+// NewFactory creates a traced Factory.
+func NewFactory(factory pki.Factory) *Factory {
+	return &Factory{Factory: factory}
+}
+
+// Create creates a certificate.
+func (f *Factory) Create(ctx context.Context, name string) (*pki.InternalCert, error) {
+	ctx, span := Start(ctx, "pki.Factory.Create", attrName.String(name))
+	cert, err := f.Factory.Create(ctx, name)
+	End(span, err)
+	return cert, err
+}
+
+// CertificateService retrieves or creates certificates.
+type CertificateService interface {
+	GetOrCreate(ctx context.Context, name string) (*pki.InternalCert, error)
+}
+
+// Service wraps a CertificateService with spans.
+type Service struct {
+	svc CertificateService
+}
+
+// NewService creates a traced Service.
+func NewService(svc CertificateService) *Service {
+	return &Service{svc: svc}
+}
+
+// GetOrCreate retrieves or creates a certificate.
+func (s *Service) GetOrCreate(ctx context.Context, name string) (*pki.InternalCert, error) {
+	ctx, span := Start(ctx, "pki.Service.GetOrCreate", attrName.String(name))
+	cert, err := s.svc.GetOrCreate(ctx, name)
+	End(span, err)
+	return cert, err
+}
@@ -0,0 +1,148 @@
+// Package tracing exports OpenTelemetry spans with OTLP.
+package tracing
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.pixelfactory.io/pkg/observability/log"
+	"go.pixelfactory.io/pkg/observability/log/fields"
+)
+
+// TracerName is the instrumentation name of needle spans.
+const TracerName = "go.pixelfactory.io/needle"
+
+// Provider holds the tracer provider.
+type Provider struct {
+	endpoint    string
+	serviceName string
+	version     string
+	sampleRatio float64
+	exporter    sdktrace.SpanExporter
+
+	tp *sdktrace.TracerProvider
+}
+
+// Option type.
+type Option func(*Provider)
+
+// WithEndpoint set the OTLP/HTTP collector URL, such as http://localhost:4318.
+// When empty, OTEL_EXPORTER_OTLP_* environment variables are used.
+func WithEndpoint(e string) Option {
+	return func(p *Provider) {
+		p.endpoint = e
+	}
+}
+
+// WithService set the service name and version of exported spans.
+func WithService(name, version string) Option {
+	return func(p *Provider) {
+		p.serviceName = name
+		p.version = version
+	}
+}
+
+// WithSampleRatio set the ratio of traces sampled, spans follow their parent sampling decision.
+func WithSampleRatio(r float64) Option {
+	return func(p *Provider) {
+		p.sampleRatio = r
+	}
+}
+
+// WithExporter replace the OTLP exporter.
+func WithExporter(e sdktrace.SpanExporter) Option {
+	return func(p *Provider) {
+		p.exporter = e
+	}
+}
+
+// New create the tracer provider and register it, with W3C trace context
+// propagation, as the global provider.
+func New(ctx context.Context, opts ...Option) (*Provider, error) {
+	p := &Provider{
+		serviceName: "needle",
+		sampleRatio: 1,
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if p.exporter == nil {
+		var exporterOpts []otlptracehttp.Option
+		if p.endpoint != "" {
+			exporterOpts = append(exporterOpts, otlptracehttp.WithEndpointURL(p.endpoint))
+		}
+
+		exporter, err := otlptracehttp.New(ctx, exporterOpts...)
+		if err != nil {
+			return nil, errors.Wrap(err, "tracing.New")
+		}
+		p.exporter = exporter
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(p.serviceName),
+		semconv.ServiceVersion(p.version),
+	))
+	if err != nil {
+		return nil, errors.Wrap(err, "tracing.New")
+	}
+
+	p.tp = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(p.exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(p.sampleRatio))),
+	)
+
+	otel.SetTracerProvider(p.tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return p, nil
+}
+
+// Shutdown flushes pending spans and stops the exporter.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	return p.tp.Shutdown(ctx)
+}
+
+// Start starts a span with the global tracer.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on span, then ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Logger returns logger with the trace_id and span_id of the span in ctx,
+// so log lines can be correlated with traces.
+func Logger(ctx context.Context, logger log.Logger) log.Logger {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return logger
+	}
+
+	return logger.With(
+		fields.String("trace_id", sc.TraceID().String()),
+		fields.String("span_id", sc.SpanID().String()),
+	)
+}
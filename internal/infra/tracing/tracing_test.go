@@ -0,0 +1,102 @@
+package tracing_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"go.pixelfactory.io/needle/internal/app/pki"
+	"go.pixelfactory.io/needle/internal/infra/tracing"
+	mocks "go.pixelfactory.io/needle/mocks/pki"
+	"google.golang.org/protobuf/proto"
+)
+
+// collector is an in-process OTLP/HTTP collector.
+type collector struct {
+	mu    sync.Mutex
+	spans []*tracepb.Span
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var req coltracepb.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	for _, rs := range req.GetResourceSpans() {
+		for _, ss := range rs.GetScopeSpans() {
+			c.spans = append(c.spans, ss.GetSpans()...)
+		}
+	}
+	c.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(http.StatusOK)
+}
+
+func Test_Tracing(t *testing.T) {
+	is := require.New(t)
+
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	tp, err := tracing.New(context.Background(),
+		tracing.WithEndpoint(srv.URL),
+		tracing.WithService("needle", "test"),
+	)
+	is.NoError(err)
+
+	testCert := &pki.InternalCert{Name: "test.needle.local"}
+	repo := mocks.NewRepository(t)
+	repo.On("Get", mock.Anything, "test.needle.local").Return(nil, pki.ErrCertificateNotFound).Once()
+	repo.On("Store", mock.Anything, testCert).Return(nil).Once()
+	factory := mocks.NewFactory(t)
+	factory.On("Create", mock.Anything, "test.needle.local").Return(testCert, nil).Once()
+
+	svc := tracing.NewService(pki.New(tracing.NewRepository(repo), tracing.NewFactory(factory)))
+
+	ctx, span := tracing.Start(context.Background(), "handshake")
+	cert, err := svc.GetOrCreate(ctx, "test.needle.local")
+	is.NoError(err)
+	is.Equal(testCert, cert)
+	span.End()
+
+	is.NoError(tp.Shutdown(context.Background()))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	byName := map[string]*tracepb.Span{}
+	for _, s := range c.spans {
+		byName[s.GetName()] = s
+	}
+	is.Len(byName, 5)
+
+	root := byName["handshake"]
+	getOrCreate := byName["pki.Service.GetOrCreate"]
+	is.Equal(root.GetSpanId(), getOrCreate.GetParentSpanId())
+	for _, name := range []string{"pki.Repository.Get", "pki.Factory.Create", "pki.Repository.Store"} {
+		is.Contains(byName, name)
+		is.Equal(getOrCreate.GetSpanId(), byName[name].GetParentSpanId(), name)
+		is.Equal(root.GetTraceId(), byName[name].GetTraceId(), name)
+	}
+
+	// a cache miss is not an error
+	is.Equal(tracepb.Status_STATUS_CODE_UNSET, byName["pki.Repository.Get"].GetStatus().GetCode())
+}
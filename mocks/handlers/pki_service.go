@@ -3,6 +3,8 @@
 package mocks
 
 import (
+	context "context"
+
 	mock "github.com/stretchr/testify/mock"
 	pki "go.pixelfactory.io/needle/internal/app/pki"
 )
@@ -20,9 +22,9 @@ func (_m *PKIService) EXPECT() *PKIService_Expecter {
 	return &PKIService_Expecter{mock: &_m.Mock}
 }
 
-// GetOrCreate provides a mock function with given fields: name
-func (_m *PKIService) GetOrCreate(name string) (*pki.InternalCert, error) {
-	ret := _m.Called(name)
+// GetOrCreate provides a mock function with given fields: ctx, name
+func (_m *PKIService) GetOrCreate(ctx context.Context, name string) (*pki.InternalCert, error) {
+	ret := _m.Called(ctx, name)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetOrCreate")
@@ -30,19 +32,19 @@ func (_m *PKIService) GetOrCreate(name string) (*pki.InternalCert, error) {
 
 	var r0 *pki.InternalCert
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (*pki.InternalCert, error)); ok {
-		return rf(name)
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*pki.InternalCert, error)); ok {
+		return rf(ctx, name)
 	}
-	if rf, ok := ret.Get(0).(func(string) *pki.InternalCert); ok {
-		r0 = rf(name)
+	if rf, ok := ret.Get(0).(func(context.Context, string) *pki.InternalCert); ok {
+		r0 = rf(ctx, name)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*pki.InternalCert)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(name)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, name)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -56,14 +58,15 @@ type PKIService_GetOrCreate_Call struct {
 }
 
 // GetOrCreate is a helper method to define mock.On call
+//   - ctx context.Context
 //   - name string
-func (_e *PKIService_Expecter) GetOrCreate(name interface{}) *PKIService_GetOrCreate_Call {
-	return &PKIService_GetOrCreate_Call{Call: _e.mock.On("GetOrCreate", name)}
+func (_e *PKIService_Expecter) GetOrCreate(ctx interface{}, name interface{}) *PKIService_GetOrCreate_Call {
+	return &PKIService_GetOrCreate_Call{Call: _e.mock.On("GetOrCreate", ctx, name)}
 }
 
-func (_c *PKIService_GetOrCreate_Call) Run(run func(name string)) *PKIService_GetOrCreate_Call {
+func (_c *PKIService_GetOrCreate_Call) Run(run func(ctx context.Context, name string)) *PKIService_GetOrCreate_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(string))
+		run(args[0].(context.Context), args[1].(string))
 	})
 	return _c
 }
@@ -73,7 +76,7 @@ func (_c *PKIService_GetOrCreate_Call) Return(_a0 *pki.InternalCert, _a1 error)
 	return _c
 }
 
-func (_c *PKIService_GetOrCreate_Call) RunAndReturn(run func(string) (*pki.InternalCert, error)) *PKIService_GetOrCreate_Call {
+func (_c *PKIService_GetOrCreate_Call) RunAndReturn(run func(context.Context, string) (*pki.InternalCert, error)) *PKIService_GetOrCreate_Call {
 	_c.Call.Return(run)
 	return _c
 }
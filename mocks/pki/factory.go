@@ -3,6 +3,8 @@
 package mocks
 
 import (
+	context "context"
+
 	mock "github.com/stretchr/testify/mock"
 	pki "go.pixelfactory.io/needle/internal/app/pki"
 )
@@ -20,9 +22,9 @@ func (_m *Factory) EXPECT() *Factory_Expecter {
 	return &Factory_Expecter{mock: &_m.Mock}
 }
 
-// Create provides a mock function with given fields: name
-func (_m *Factory) Create(name string) (*pki.InternalCert, error) {
-	ret := _m.Called(name)
+// Create provides a mock function with given fields: ctx, name
+func (_m *Factory) Create(ctx context.Context, name string) (*pki.InternalCert, error) {
+	ret := _m.Called(ctx, name)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Create")
@@ -30,19 +32,19 @@ func (_m *Factory) Create(name string) (*pki.InternalCert, error) {
 
 	var r0 *pki.InternalCert
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (*pki.InternalCert, error)); ok {
-		return rf(name)
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*pki.InternalCert, error)); ok {
+		return rf(ctx, name)
 	}
-	if rf, ok := ret.Get(0).(func(string) *pki.InternalCert); ok {
-		r0 = rf(name)
+	if rf, ok := ret.Get(0).(func(context.Context, string) *pki.InternalCert); ok {
+		r0 = rf(ctx, name)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*pki.InternalCert)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(name)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, name)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -56,14 +58,15 @@ type Factory_Create_Call struct {
 }
 
 // Create is a helper method to define mock.On call
+//   - ctx context.Context
 //   - name string
-func (_e *Factory_Expecter) Create(name interface{}) *Factory_Create_Call {
-	return &Factory_Create_Call{Call: _e.mock.On("Create", name)}
+func (_e *Factory_Expecter) Create(ctx interface{}, name interface{}) *Factory_Create_Call {
+	return &Factory_Create_Call{Call: _e.mock.On("Create", ctx, name)}
 }
 
-func (_c *Factory_Create_Call) Run(run func(name string)) *Factory_Create_Call {
+func (_c *Factory_Create_Call) Run(run func(ctx context.Context, name string)) *Factory_Create_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(string))
+		run(args[0].(context.Context), args[1].(string))
 	})
 	return _c
 }
@@ -73,7 +76,53 @@ func (_c *Factory_Create_Call) Return(_a0 *pki.InternalCert, _a1 error) *Factory
 	return _c
 }
 
-func (_c *Factory_Create_Call) RunAndReturn(run func(string) (*pki.InternalCert, error)) *Factory_Create_Call {
+func (_c *Factory_Create_Call) RunAndReturn(run func(context.Context, string) (*pki.InternalCert, error)) *Factory_Create_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Verify provides a mock function with given fields: cert
+func (_m *Factory) Verify(cert *pki.InternalCert) error {
+	ret := _m.Called(cert)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Verify")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*pki.InternalCert) error); ok {
+		r0 = rf(cert)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Factory_Verify_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Verify'
+type Factory_Verify_Call struct {
+	*mock.Call
+}
+
+// Verify is a helper method to define mock.On call
+//   - cert *pki.InternalCert
+func (_e *Factory_Expecter) Verify(cert interface{}) *Factory_Verify_Call {
+	return &Factory_Verify_Call{Call: _e.mock.On("Verify", cert)}
+}
+
+func (_c *Factory_Verify_Call) Run(run func(cert *pki.InternalCert)) *Factory_Verify_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(*pki.InternalCert))
+	})
+	return _c
+}
+
+func (_c *Factory_Verify_Call) Return(_a0 error) *Factory_Verify_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *Factory_Verify_Call) RunAndReturn(run func(*pki.InternalCert) error) *Factory_Verify_Call {
 	_c.Call.Return(run)
 	return _c
 }
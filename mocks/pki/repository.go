@@ -3,6 +3,8 @@
 package mocks
 
 import (
+	context "context"
+
 	mock "github.com/stretchr/testify/mock"
 	pki "go.pixelfactory.io/needle/internal/app/pki"
 )
@@ -20,9 +22,56 @@ func (_m *Repository) EXPECT() *Repository_Expecter {
 	return &Repository_Expecter{mock: &_m.Mock}
 }
 
-// Get provides a mock function with given fields: name
-func (_m *Repository) Get(name string) (*pki.InternalCert, error) {
-	ret := _m.Called(name)
+// Delete provides a mock function with given fields: ctx, name
+func (_m *Repository) Delete(ctx context.Context, name string) error {
+	ret := _m.Called(ctx, name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Delete")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Repository_Delete_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Delete'
+type Repository_Delete_Call struct {
+	*mock.Call
+}
+
+// Delete is a helper method to define mock.On call
+//   - ctx context.Context
+//   - name string
+func (_e *Repository_Expecter) Delete(ctx interface{}, name interface{}) *Repository_Delete_Call {
+	return &Repository_Delete_Call{Call: _e.mock.On("Delete", ctx, name)}
+}
+
+func (_c *Repository_Delete_Call) Run(run func(ctx context.Context, name string)) *Repository_Delete_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *Repository_Delete_Call) Return(_a0 error) *Repository_Delete_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *Repository_Delete_Call) RunAndReturn(run func(context.Context, string) error) *Repository_Delete_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Get provides a mock function with given fields: ctx, name
+func (_m *Repository) Get(ctx context.Context, name string) (*pki.InternalCert, error) {
+	ret := _m.Called(ctx, name)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Get")
@@ -30,19 +79,19 @@ func (_m *Repository) Get(name string) (*pki.InternalCert, error) {
 
 	var r0 *pki.InternalCert
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (*pki.InternalCert, error)); ok {
-		return rf(name)
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*pki.InternalCert, error)); ok {
+		return rf(ctx, name)
 	}
-	if rf, ok := ret.Get(0).(func(string) *pki.InternalCert); ok {
-		r0 = rf(name)
+	if rf, ok := ret.Get(0).(func(context.Context, string) *pki.InternalCert); ok {
+		r0 = rf(ctx, name)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*pki.InternalCert)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(name)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, name)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -56,14 +105,15 @@ type Repository_Get_Call struct {
 }
 
 // Get is a helper method to define mock.On call
+//   - ctx context.Context
 //   - name string
-func (_e *Repository_Expecter) Get(name interface{}) *Repository_Get_Call {
-	return &Repository_Get_Call{Call: _e.mock.On("Get", name)}
+func (_e *Repository_Expecter) Get(ctx interface{}, name interface{}) *Repository_Get_Call {
+	return &Repository_Get_Call{Call: _e.mock.On("Get", ctx, name)}
 }
 
-func (_c *Repository_Get_Call) Run(run func(name string)) *Repository_Get_Call {
+func (_c *Repository_Get_Call) Run(run func(ctx context.Context, name string)) *Repository_Get_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(string))
+		run(args[0].(context.Context), args[1].(string))
 	})
 	return _c
 }
@@ -73,22 +123,80 @@ func (_c *Repository_Get_Call) Return(_a0 *pki.InternalCert, _a1 error) *Reposit
 	return _c
 }
 
-func (_c *Repository_Get_Call) RunAndReturn(run func(string) (*pki.InternalCert, error)) *Repository_Get_Call {
+func (_c *Repository_Get_Call) RunAndReturn(run func(context.Context, string) (*pki.InternalCert, error)) *Repository_Get_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// List provides a mock function with given fields: ctx
+func (_m *Repository) List(ctx context.Context) ([]*pki.InternalCert, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for List")
+	}
+
+	var r0 []*pki.InternalCert
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]*pki.InternalCert, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []*pki.InternalCert); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*pki.InternalCert)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Repository_List_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'List'
+type Repository_List_Call struct {
+	*mock.Call
+}
+
+// List is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *Repository_Expecter) List(ctx interface{}) *Repository_List_Call {
+	return &Repository_List_Call{Call: _e.mock.On("List", ctx)}
+}
+
+func (_c *Repository_List_Call) Run(run func(ctx context.Context)) *Repository_List_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *Repository_List_Call) Return(_a0 []*pki.InternalCert, _a1 error) *Repository_List_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *Repository_List_Call) RunAndReturn(run func(context.Context) ([]*pki.InternalCert, error)) *Repository_List_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// Store provides a mock function with given fields: certificate
-func (_m *Repository) Store(certificate *pki.InternalCert) error {
-	ret := _m.Called(certificate)
+// Store provides a mock function with given fields: ctx, certificate
+func (_m *Repository) Store(ctx context.Context, certificate *pki.InternalCert) error {
+	ret := _m.Called(ctx, certificate)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Store")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*pki.InternalCert) error); ok {
-		r0 = rf(certificate)
+	if rf, ok := ret.Get(0).(func(context.Context, *pki.InternalCert) error); ok {
+		r0 = rf(ctx, certificate)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -102,14 +210,15 @@ type Repository_Store_Call struct {
 }
 
 // Store is a helper method to define mock.On call
+//   - ctx context.Context
 //   - certificate *pki.InternalCert
-func (_e *Repository_Expecter) Store(certificate interface{}) *Repository_Store_Call {
-	return &Repository_Store_Call{Call: _e.mock.On("Store", certificate)}
+func (_e *Repository_Expecter) Store(ctx interface{}, certificate interface{}) *Repository_Store_Call {
+	return &Repository_Store_Call{Call: _e.mock.On("Store", ctx, certificate)}
 }
 
-func (_c *Repository_Store_Call) Run(run func(certificate *pki.InternalCert)) *Repository_Store_Call {
+func (_c *Repository_Store_Call) Run(run func(ctx context.Context, certificate *pki.InternalCert)) *Repository_Store_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(*pki.InternalCert))
+		run(args[0].(context.Context), args[1].(*pki.InternalCert))
 	})
 	return _c
 }
@@ -119,7 +228,7 @@ func (_c *Repository_Store_Call) Return(_a0 error) *Repository_Store_Call {
 	return _c
 }
 
-func (_c *Repository_Store_Call) RunAndReturn(run func(*pki.InternalCert) error) *Repository_Store_Call {
+func (_c *Repository_Store_Call) RunAndReturn(run func(context.Context, *pki.InternalCert) error) *Repository_Store_Call {
 	_c.Call.Return(run)
 	return _c
 }